@@ -0,0 +1,57 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+type deps struct {
+	dirs      map[string]string // sessions directory per agent ID
+	backend   string
+	retention session.RetentionPolicy // session.retention from the config
+}
+
+func NewSessionCommand() *cobra.Command {
+	var d deps
+
+	cmd := &cobra.Command{
+		Use:   "session",
+		Short: "Manage conversation sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			d.dirs = agent.SessionsDirs(cfg)
+			d.backend = cfg.Agents.Defaults.SessionStore
+			d.retention = agent.RetentionPolicyFromConfig(cfg.Session.Retention)
+			return nil
+		},
+	}
+
+	depsFn := func() (*deps, error) {
+		if d.dirs == nil {
+			return nil, fmt.Errorf("sessions are not initialized")
+		}
+		return &d, nil
+	}
+
+	cmd.AddCommand(
+		newListCommand(depsFn),
+		newShowCommand(depsFn),
+		newExportCommand(depsFn),
+		newDeleteCommand(depsFn),
+		newPruneCommand(depsFn),
+	)
+
+	return cmd
+}
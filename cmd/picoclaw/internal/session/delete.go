@@ -0,0 +1,25 @@
+package session
+
+import "github.com/spf13/cobra"
+
+func newDeleteCommand(depsFn func() (*deps, error)) *cobra.Command {
+	var agentID string
+
+	cmd := &cobra.Command{
+		Use:     "delete",
+		Short:   "Delete a session",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw session delete telegram:direct:123456`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := depsFn()
+			if err != nil {
+				return err
+			}
+			return sessionDeleteCmd(cmd.Context(), cmd.OutOrStdout(), d, agentID, args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&agentID, "agent", "", "Agent the session belongs to")
+
+	return cmd
+}
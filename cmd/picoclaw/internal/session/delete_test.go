@@ -0,0 +1,22 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeleteSubcommand(t *testing.T) {
+	cmd := newDeleteCommand(nil)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "delete", cmd.Use)
+	assert.Equal(t, "Delete a session", cmd.Short)
+
+	assert.True(t, cmd.HasExample())
+	assert.False(t, cmd.HasSubCommands())
+
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+}
@@ -0,0 +1,27 @@
+package session
+
+import "github.com/spf13/cobra"
+
+func newExportCommand(depsFn func() (*deps, error)) *cobra.Command {
+	var agentID, format, output string
+
+	cmd := &cobra.Command{
+		Use:     "export",
+		Short:   "Export a session as Markdown or JSON",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw session export telegram:direct:123456 --format json -o chat.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := depsFn()
+			if err != nil {
+				return err
+			}
+			return sessionExportCmd(cmd.Context(), cmd.OutOrStdout(), d, agentID, args[0], format, output)
+		},
+	}
+
+	cmd.Flags().StringVar(&agentID, "agent", "", "Agent the session belongs to")
+	cmd.Flags().StringVarP(&format, "format", "f", formatMarkdown, "Export format: markdown or json")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write to (default stdout)")
+
+	return cmd
+}
@@ -0,0 +1,24 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExportSubcommand(t *testing.T) {
+	cmd := newExportCommand(nil)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "export", cmd.Use)
+	assert.Equal(t, "Export a session as Markdown or JSON", cmd.Short)
+
+	assert.True(t, cmd.HasExample())
+	assert.False(t, cmd.HasSubCommands())
+
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("format"))
+	assert.NotNil(t, cmd.Flags().Lookup("output"))
+}
@@ -0,0 +1,419 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// entry is a stored session and the agent whose sessions directory holds it.
+type entry struct {
+	agentID string
+	memory.SessionInfo
+}
+
+// filter selects sessions by agent, channel and time of the last update.
+// Zero fields match everything.
+type filter struct {
+	agent     string
+	channel   string
+	olderThan time.Duration // idle for at least this long
+	newerThan time.Duration // updated within this long
+}
+
+func (f filter) matchAgent(agentID string) bool {
+	return f.agent == "" || routing.NormalizeAgentID(f.agent) == agentID
+}
+
+func (f filter) match(s memory.SessionInfo, now time.Time) bool {
+	if f.channel != "" && !strings.EqualFold(channelOf(s.Key), f.channel) {
+		return false
+	}
+	idle := now.Sub(s.UpdatedAt)
+	if f.olderThan > 0 && idle < f.olderThan {
+		return false
+	}
+	if f.newerThan > 0 && idle > f.newerThan {
+		return false
+	}
+	return true
+}
+
+// agentIDs returns the agents of d matching f, sorted.
+func (d *deps) agentIDs(f filter) []string {
+	ids := make([]string, 0, len(d.dirs))
+	for id := range d.dirs {
+		if f.matchAgent(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// withStore opens the session store of an agent for the duration of fn.
+func (d *deps) withStore(agentID string, fn func(store memory.Store) error) error {
+	backend, err := session.OpenStoreBackend(d.dirs[agentID], d.backend)
+	if err != nil {
+		return fmt.Errorf("open sessions of agent %s: %w", agentID, err)
+	}
+	defer backend.Close()
+	return fn(backend.Store())
+}
+
+// list returns the sessions matching f, grouped by agent and most recently
+// updated first.
+func (d *deps) list(ctx context.Context, f filter, now time.Time) ([]entry, error) {
+	var entries []entry
+	for _, id := range d.agentIDs(f) {
+		err := d.withStore(id, func(store memory.Store) error {
+			sessions, err := store.ListSessions(ctx)
+			if err != nil {
+				return err
+			}
+			for _, s := range sessions {
+				if f.match(s, now) {
+					entries = append(entries, entry{agentID: id, SessionInfo: s})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// find resolves key, either a full session key or the part after
+// "agent:<id>:", to exactly one stored session.
+func (d *deps) find(ctx context.Context, agent, key string) (entry, error) {
+	all, err := d.list(ctx, filter{agent: agent}, time.Now())
+	if err != nil {
+		return entry{}, err
+	}
+	var matches []entry
+	for _, e := range all {
+		if matchKey(e.Key, key) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return entry{}, fmt.Errorf("session %q not found", key)
+	case 1:
+		return matches[0], nil
+	}
+	keys := make([]string, len(matches))
+	for i, m := range matches {
+		keys[i] = m.Key
+	}
+	return entry{}, fmt.Errorf("session %q is ambiguous, use --agent or one of: %s", key, strings.Join(keys, ", "))
+}
+
+func matchKey(stored, key string) bool {
+	if strings.EqualFold(stored, key) {
+		return true
+	}
+	parsed := routing.ParseAgentSessionKey(stored)
+	return parsed != nil && strings.EqualFold(parsed.Rest, key)
+}
+
+// displayKey returns the human-readable part of a session key: the key
+// without its "agent:<id>:" prefix.
+func displayKey(key string) string {
+	if parsed := routing.ParseAgentSessionKey(key); parsed != nil {
+		return parsed.Rest
+	}
+	return key
+}
+
+// channelOf returns the channel a session key was built for, or "" for
+// sessions shared across channels ("main", "direct:<peer>").
+func channelOf(key string) string {
+	channel, _, _ := strings.Cut(displayKey(key), ":")
+	switch channel {
+	case routing.DefaultMainKey, "direct":
+		return ""
+	}
+	return channel
+}
+
+// parseAge parses a duration such as "90m", "12h" or "30d".
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func sessionListCmd(ctx context.Context, w io.Writer, d *deps, f filter) error {
+	entries, err := d.list(ctx, f, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No sessions.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AGENT\tSESSION\tMESSAGES\tUPDATED\tMODEL")
+	for _, e := range entries {
+		model := e.Model
+		if model == "" {
+			model = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", e.agentID, displayKey(e.Key), e.Messages, formatTime(e.UpdatedAt), model)
+	}
+	return tw.Flush()
+}
+
+func sessionShowCmd(ctx context.Context, w io.Writer, d *deps, agent, key string, last int) error {
+	e, err := d.find(ctx, agent, key)
+	if err != nil {
+		return err
+	}
+	return d.withStore(e.agentID, func(store memory.Store) error {
+		history, err := store.GetHistory(ctx, e.Key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Session: %s\n", e.Key)
+		fmt.Fprintf(w, "Agent:   %s\n", e.agentID)
+		fmt.Fprintf(w, "Created: %s\n", formatTime(e.CreatedAt))
+		fmt.Fprintf(w, "Updated: %s\n", formatTime(e.UpdatedAt))
+		if e.Model != "" {
+			fmt.Fprintf(w, "Model:   %s\n", e.Model)
+		}
+		if e.Summary != "" {
+			fmt.Fprintf(w, "\nSummary:\n%s\n", e.Summary)
+		}
+
+		if last > 0 && len(history) > last {
+			fmt.Fprintf(w, "\n(%d earlier messages not shown)\n", len(history)-last)
+			history = history[len(history)-last:]
+		}
+		for _, m := range history {
+			fmt.Fprintf(w, "\n[%s]\n", messageLabel(m))
+			if m.Content != "" {
+				fmt.Fprintln(w, m.Content)
+			}
+			for _, tc := range m.ToolCalls {
+				fmt.Fprintf(w, "→ %s\n", toolCallText(tc))
+			}
+		}
+		return nil
+	})
+}
+
+// Export formats accepted by sessionExportCmd.
+const (
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+)
+
+// exportedSession is the JSON export of a session.
+type exportedSession struct {
+	Key       string              `json:"key"`
+	Agent     string              `json:"agent"`
+	Summary   string              `json:"summary,omitempty"`
+	Model     string              `json:"model,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	Messages  []providers.Message `json:"messages"`
+}
+
+func sessionExportCmd(ctx context.Context, w io.Writer, d *deps, agent, key, format, output string) error {
+	if format != formatMarkdown && format != formatJSON {
+		return fmt.Errorf("unknown format %q, use %s or %s", format, formatMarkdown, formatJSON)
+	}
+	e, err := d.find(ctx, agent, key)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	err = d.withStore(e.agentID, func(store memory.Store) error {
+		history, err := store.GetHistory(ctx, e.Key)
+		if err != nil {
+			return err
+		}
+		if format == formatJSON {
+			data, err = json.MarshalIndent(exportedSession{
+				Key:       e.Key,
+				Agent:     e.agentID,
+				Summary:   e.Summary,
+				Model:     e.Model,
+				CreatedAt: e.CreatedAt,
+				UpdatedAt: e.UpdatedAt,
+				Messages:  history,
+			}, "", "  ")
+			data = append(data, '\n')
+			return err
+		}
+		data = []byte(exportMarkdown(e, history))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if output == "" || output == "-" {
+		_, err = w.Write(data)
+		return err
+	}
+	// Conversations are private, keep the export readable by the owner only.
+	if err := os.WriteFile(output, data, 0o600); err != nil {
+		return fmt.Errorf("write export: %w", err)
+	}
+	fmt.Fprintf(w, "✓ Exported %s to %s\n", displayKey(e.Key), output)
+	return nil
+}
+
+func exportMarkdown(e entry, history []providers.Message) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Session %s\n\n", displayKey(e.Key))
+	fmt.Fprintf(&sb, "- Key: `%s`\n", e.Key)
+	fmt.Fprintf(&sb, "- Agent: %s\n", e.agentID)
+	fmt.Fprintf(&sb, "- Created: %s\n", formatTime(e.CreatedAt))
+	fmt.Fprintf(&sb, "- Updated: %s\n", formatTime(e.UpdatedAt))
+	if e.Model != "" {
+		fmt.Fprintf(&sb, "- Model: %s\n", e.Model)
+	}
+	if e.Summary != "" {
+		fmt.Fprintf(&sb, "\n## Summary\n\n%s\n", e.Summary)
+	}
+
+	sb.WriteString("\n## Conversation\n")
+	for _, m := range history {
+		fmt.Fprintf(&sb, "\n### %s\n\n", messageLabel(m))
+		if m.Content != "" {
+			if m.Role == "tool" {
+				fmt.Fprintf(&sb, "```\n%s\n```\n", strings.TrimRight(m.Content, "\n"))
+			} else {
+				fmt.Fprintf(&sb, "%s\n", m.Content)
+			}
+		}
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&sb, "\n- Tool call: `%s`\n", toolCallText(tc))
+		}
+	}
+	return sb.String()
+}
+
+// messageLabel names the author of a message, e.g. "User" or
+// "Tool (call_1)".
+func messageLabel(m providers.Message) string {
+	label := m.Role
+	if label != "" {
+		label = strings.ToUpper(label[:1]) + label[1:]
+	}
+	if m.ToolCallID != "" {
+		label += " (" + m.ToolCallID + ")"
+	}
+	return label
+}
+
+func toolCallText(tc providers.ToolCall) string {
+	if tc.Function == nil {
+		return tc.Name
+	}
+	return tc.Function.Name + "(" + tc.Function.Arguments + ")"
+}
+
+func sessionDeleteCmd(ctx context.Context, w io.Writer, d *deps, agent, key string) error {
+	e, err := d.find(ctx, agent, key)
+	if err != nil {
+		return err
+	}
+	err = d.withStore(e.agentID, func(store memory.Store) error {
+		return store.DeleteSession(ctx, e.Key)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "✓ Deleted session %s (agent %s)\n", displayKey(e.Key), e.agentID)
+	return nil
+}
+
+// sessionPruneCmd deletes the sessions matching f that policy drops. The
+// session limit applies to each agent separately.
+func sessionPruneCmd(
+	ctx context.Context, w io.Writer, d *deps, f filter, policy session.RetentionPolicy, dryRun bool,
+) error {
+	if policy.IsZero() {
+		return fmt.Errorf("nothing to prune: set --older-than or --keep, or session.retention in the config")
+	}
+
+	now := time.Now()
+	pruned := 0
+	for _, id := range d.agentIDs(f) {
+		err := d.withStore(id, func(store memory.Store) error {
+			sessions, err := store.ListSessions(ctx)
+			if err != nil {
+				return err
+			}
+			var candidates []memory.SessionInfo
+			for _, s := range sessions {
+				if f.match(s, now) {
+					candidates = append(candidates, s)
+				}
+			}
+			for _, s := range policy.Expired(candidates, now) {
+				if !dryRun {
+					if err := store.DeleteSession(ctx, s.Key); err != nil {
+						return err
+					}
+				}
+				fmt.Fprintf(w, "  %s %s (updated %s)\n", id, displayKey(s.Key), formatTime(s.UpdatedAt))
+				pruned++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case pruned == 0:
+		fmt.Fprintln(w, "No sessions to prune.")
+	case dryRun:
+		fmt.Fprintf(w, "%d sessions would be deleted (dry run).\n", pruned)
+	default:
+		fmt.Fprintf(w, "✓ Deleted %d sessions.\n", pruned)
+	}
+	return nil
+}
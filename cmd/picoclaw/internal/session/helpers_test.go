@@ -0,0 +1,195 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// newTestDeps creates the sessions of two agents, main and work.
+func newTestDeps(t *testing.T) *deps {
+	t.Helper()
+	d := &deps{dirs: map[string]string{
+		"main": filepath.Join(t.TempDir(), "sessions"),
+		"work": filepath.Join(t.TempDir(), "sessions"),
+	}}
+	add := func(agentID, key string, msgs ...providers.Message) {
+		store, err := memory.NewJSONLStore(d.dirs[agentID])
+		require.NoError(t, err)
+		defer store.Close()
+		for _, m := range msgs {
+			require.NoError(t, store.AddFullMessage(context.Background(), key, m))
+		}
+	}
+	add("main", "agent:main:telegram:direct:42",
+		providers.Message{Role: "user", Content: "what's the weather?"},
+		providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{{
+			ID: "call_1", Function: &providers.FunctionCall{Name: "web_search", Arguments: `{"query":"weather"}`},
+		}}},
+		providers.Message{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+		providers.Message{Role: "assistant", Content: "It is sunny."})
+	add("main", "agent:main:discord:group:7", providers.Message{Role: "user", Content: "hi"})
+	add("work", "agent:work:telegram:direct:42", providers.Message{Role: "user", Content: "deadline?"})
+	return d
+}
+
+func TestChannelOfAndDisplayKey(t *testing.T) {
+	assert.Equal(t, "telegram:direct:42", displayKey("agent:main:telegram:direct:42"))
+	assert.Equal(t, "cron-job", displayKey("cron-job"))
+
+	assert.Equal(t, "telegram", channelOf("agent:main:telegram:direct:42"))
+	assert.Equal(t, "slack", channelOf("agent:main:slack:acct:direct:u1"))
+	assert.Equal(t, "", channelOf("agent:main:main"))
+	assert.Equal(t, "", channelOf("agent:main:direct:alice"))
+}
+
+func TestParseAge(t *testing.T) {
+	d, err := parseAge("30d")
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, d)
+
+	d, err = parseAge("90m")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	d, err = parseAge("")
+	require.NoError(t, err)
+	assert.Zero(t, d)
+
+	for _, bad := range []string{"xd", "-1d", "soon"} {
+		_, err = parseAge(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Now()
+	s := memory.SessionInfo{Key: "agent:main:telegram:direct:42", UpdatedAt: now.Add(-48 * time.Hour)}
+
+	assert.True(t, filter{}.match(s, now))
+	assert.True(t, filter{channel: "Telegram"}.match(s, now))
+	assert.False(t, filter{channel: "discord"}.match(s, now))
+	assert.True(t, filter{olderThan: 24 * time.Hour}.match(s, now))
+	assert.False(t, filter{olderThan: 72 * time.Hour}.match(s, now))
+	assert.True(t, filter{newerThan: 72 * time.Hour}.match(s, now))
+	assert.False(t, filter{newerThan: 24 * time.Hour}.match(s, now))
+}
+
+func TestSessionListCmd(t *testing.T) {
+	d := newTestDeps(t)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	require.NoError(t, sessionListCmd(ctx, &out, d, filter{}))
+	assert.Contains(t, out.String(), "telegram:direct:42")
+	assert.Contains(t, out.String(), "discord:group:7")
+	assert.NotContains(t, out.String(), "agent:main:")
+
+	out.Reset()
+	require.NoError(t, sessionListCmd(ctx, &out, d, filter{agent: "work"}))
+	assert.Contains(t, out.String(), "telegram:direct:42")
+	assert.NotContains(t, out.String(), "discord")
+
+	out.Reset()
+	require.NoError(t, sessionListCmd(ctx, &out, d, filter{channel: "slack"}))
+	assert.Equal(t, "No sessions.\n", out.String())
+}
+
+func TestSessionShowCmd(t *testing.T) {
+	d := newTestDeps(t)
+	ctx := context.Background()
+
+	// The short key exists for both agents.
+	var out bytes.Buffer
+	err := sessionShowCmd(ctx, &out, d, "", "telegram:direct:42", 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ambiguous")
+
+	require.NoError(t, sessionShowCmd(ctx, &out, d, "main", "telegram:direct:42", 0))
+	assert.Contains(t, out.String(), "what's the weather?")
+	assert.Contains(t, out.String(), `web_search({"query":"weather"})`)
+	assert.Contains(t, out.String(), "[Tool (call_1)]")
+
+	out.Reset()
+	require.NoError(t, sessionShowCmd(ctx, &out, d, "", "agent:main:telegram:direct:42", 1))
+	assert.Contains(t, out.String(), "3 earlier messages not shown")
+	assert.NotContains(t, out.String(), "what's the weather?")
+
+	err = sessionShowCmd(ctx, &out, d, "", "missing", 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestSessionExportCmd(t *testing.T) {
+	d := newTestDeps(t)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	require.NoError(t, sessionExportCmd(ctx, &out, d, "main", "telegram:direct:42", formatMarkdown, ""))
+	md := out.String()
+	assert.Contains(t, md, "# Session telegram:direct:42")
+	assert.Contains(t, md, "### User\n\nwhat's the weather?")
+	assert.Contains(t, md, "```\nsunny\n```")
+
+	path := filepath.Join(t.TempDir(), "chat.json")
+	out.Reset()
+	require.NoError(t, sessionExportCmd(ctx, &out, d, "main", "telegram:direct:42", formatJSON, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var exported exportedSession
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, "agent:main:telegram:direct:42", exported.Key)
+	assert.Equal(t, "main", exported.Agent)
+	assert.Len(t, exported.Messages, 4)
+
+	assert.Error(t, sessionExportCmd(ctx, &out, d, "main", "telegram:direct:42", "pdf", ""))
+}
+
+func TestSessionDeleteCmd(t *testing.T) {
+	d := newTestDeps(t)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	require.NoError(t, sessionDeleteCmd(ctx, &out, d, "work", "telegram:direct:42"))
+
+	entries, err := d.list(ctx, filter{}, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "main", e.agentID)
+	}
+}
+
+func TestSessionPruneCmd(t *testing.T) {
+	d := newTestDeps(t)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	assert.Error(t, sessionPruneCmd(ctx, &out, d, filter{}, session.RetentionPolicy{}, false))
+
+	policy := session.RetentionPolicy{MaxSessions: 1}
+	require.NoError(t, sessionPruneCmd(ctx, &out, d, filter{}, policy, true))
+	assert.Contains(t, out.String(), "1 sessions would be deleted")
+	entries, _ := d.list(ctx, filter{}, time.Now())
+	assert.Len(t, entries, 3)
+
+	out.Reset()
+	require.NoError(t, sessionPruneCmd(ctx, &out, d, filter{}, policy, false))
+	entries, _ = d.list(ctx, filter{}, time.Now())
+	assert.Len(t, entries, 2, "each agent keeps its most recent session")
+
+	out.Reset()
+	require.NoError(t, sessionPruneCmd(ctx, &out, d, filter{}, session.RetentionPolicy{MaxAge: time.Hour}, false))
+	assert.Equal(t, "No sessions to prune.\n", out.String())
+}
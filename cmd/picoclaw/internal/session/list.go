@@ -0,0 +1,35 @@
+package session
+
+import "github.com/spf13/cobra"
+
+func newListCommand(depsFn func() (*deps, error)) *cobra.Command {
+	var f filter
+	var olderThan, newerThan string
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List sessions",
+		Args:    cobra.NoArgs,
+		Example: `picoclaw session list --channel telegram --newer-than 7d`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			d, err := depsFn()
+			if err != nil {
+				return err
+			}
+			if f.olderThan, err = parseAge(olderThan); err != nil {
+				return err
+			}
+			if f.newerThan, err = parseAge(newerThan); err != nil {
+				return err
+			}
+			return sessionListCmd(cmd.Context(), cmd.OutOrStdout(), d, f)
+		},
+	}
+
+	cmd.Flags().StringVar(&f.agent, "agent", "", "Only sessions of this agent")
+	cmd.Flags().StringVar(&f.channel, "channel", "", "Only sessions of this channel")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Only sessions idle for at least this long (e.g. 30d, 12h)")
+	cmd.Flags().StringVar(&newerThan, "newer-than", "", "Only sessions updated within this long (e.g. 7d, 90m)")
+
+	return cmd
+}
@@ -0,0 +1,43 @@
+package session
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newPruneCommand(depsFn func() (*deps, error)) *cobra.Command {
+	var f filter
+	var policy session.RetentionPolicy
+	var olderThan string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "Delete sessions by retention policy",
+		Long:    "Delete sessions by retention policy. Without --older-than or --keep, session.retention from the config applies.",
+		Args:    cobra.NoArgs,
+		Example: `picoclaw session prune --older-than 30d --keep 100 --dry-run`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			d, err := depsFn()
+			if err != nil {
+				return err
+			}
+			if policy.MaxAge, err = parseAge(olderThan); err != nil {
+				return err
+			}
+			if policy.IsZero() {
+				policy = d.retention
+			}
+			return sessionPruneCmd(cmd.Context(), cmd.OutOrStdout(), d, f, policy, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&f.agent, "agent", "", "Only prune sessions of this agent")
+	cmd.Flags().StringVar(&f.channel, "channel", "", "Only prune sessions of this channel")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Delete sessions idle for longer than this (e.g. 30d, 12h)")
+	cmd.Flags().IntVar(&policy.MaxSessions, "keep", 0, "Keep only the most recently updated sessions of each agent")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be deleted without deleting")
+
+	return cmd
+}
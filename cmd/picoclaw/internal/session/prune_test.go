@@ -0,0 +1,26 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPruneSubcommand(t *testing.T) {
+	cmd := newPruneCommand(nil)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "prune", cmd.Use)
+	assert.Equal(t, "Delete sessions by retention policy", cmd.Short)
+
+	assert.True(t, cmd.HasExample())
+	assert.False(t, cmd.HasSubCommands())
+
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("channel"))
+	assert.NotNil(t, cmd.Flags().Lookup("older-than"))
+	assert.NotNil(t, cmd.Flags().Lookup("keep"))
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
+}
@@ -0,0 +1,27 @@
+package session
+
+import "github.com/spf13/cobra"
+
+func newShowCommand(depsFn func() (*deps, error)) *cobra.Command {
+	var agentID string
+	var last int
+
+	cmd := &cobra.Command{
+		Use:     "show",
+		Short:   "Show the messages of a session",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw session show telegram:direct:123456 --last 20`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := depsFn()
+			if err != nil {
+				return err
+			}
+			return sessionShowCmd(cmd.Context(), cmd.OutOrStdout(), d, agentID, args[0], last)
+		},
+	}
+
+	cmd.Flags().StringVar(&agentID, "agent", "", "Agent the session belongs to")
+	cmd.Flags().IntVarP(&last, "last", "n", 0, "Only show the last n messages")
+
+	return cmd
+}
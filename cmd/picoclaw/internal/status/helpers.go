@@ -3,9 +3,12 @@ package status
 import (
 	"fmt"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/usage"
 )
 
 func statusCmd() {
@@ -97,4 +100,47 @@ func statusCmd() {
 			}
 		}
 	}
+
+	printUsage(workspace)
+}
+
+// printUsage prints today's and this month's token usage per agent.
+func printUsage(workspace string) {
+	records, err := usage.Load(usage.DefaultPath(workspace))
+	if err != nil {
+		fmt.Printf("\nUsage: %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	agentIDs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, r := range records {
+		if !seen[r.AgentID] {
+			seen[r.AgentID] = true
+			agentIDs = append(agentIDs, r.AgentID)
+		}
+	}
+	sort.Strings(agentIDs)
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+
+	fmt.Println("\nToken Usage:")
+	for _, id := range agentIDs {
+		day := usage.Sum(records, usage.Filter{AgentID: id, DayPrefix: today})
+		mon := usage.Sum(records, usage.Filter{AgentID: id, DayPrefix: month})
+		fmt.Printf("  %s: today %d tokens", id, day.TotalTokens)
+		if day.Cost > 0 {
+			fmt.Printf(" ($%.4f)", day.Cost)
+		}
+		fmt.Printf(", this month %d tokens", mon.TotalTokens)
+		if mon.Cost > 0 {
+			fmt.Printf(" ($%.4f)", mon.Cost)
+		}
+		fmt.Println()
+	}
 }
@@ -39,6 +39,7 @@ func TestNewPicoclawCommand(t *testing.T) {
 		"gateway",
 		"migrate",
 		"onboard",
+		"session",
 		"skills",
 		"status",
 		"version",
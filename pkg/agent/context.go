@@ -21,6 +21,7 @@ type ContextBuilder struct {
 	workspace    string
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
+	memorySearch bool // memory_search is available; keep memory in the prompt bounded
 
 	// Cache for system prompt to avoid rebuilding on every call.
 	// This fixes issue #607: repeated reprocessing of the entire context.
@@ -118,6 +119,9 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 
 	// Memory context
 	memoryContext := cb.memory.GetMemoryContext()
+	if cb.memorySearch {
+		memoryContext = cb.memory.GetRecallContext()
+	}
 	if memoryContext != "" {
 		parts = append(parts, "# Memory\n\n"+memoryContext)
 	}
@@ -126,6 +130,14 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 	return strings.Join(parts, "\n\n---\n\n")
 }
 
+// SetMemorySearch tells the builder that the agent can recall memory with
+// memory_search, so only the start of long-term memory and today's note are
+// put in the system prompt.
+func (cb *ContextBuilder) SetMemorySearch(enabled bool) {
+	cb.memorySearch = enabled
+	cb.InvalidateCache()
+}
+
 // BuildSystemPromptWithCache returns the cached system prompt if available
 // and source files haven't changed, otherwise builds and caches it.
 // Source file changes are detected via mtime checks (cheap stat calls).
@@ -438,12 +450,30 @@ func (cb *ContextBuilder) buildDynamicContext(channel, chatID string) string {
 	return sb.String()
 }
 
+// maxProfileChars caps the person profile put in the prompt.
+const maxProfileChars = 2000
+
+// personContext renders the profile of the person the turn comes from.
+// It is only built for that person's turns, so one user's profile never
+// reaches another user's prompt.
+func (cb *ContextBuilder) personContext(personID string) string {
+	profile := strings.TrimSpace(cb.memory.ReadProfile(personID))
+	if profile == "" {
+		return ""
+	}
+	if runes := []rune(profile); len(runes) > maxProfileChars {
+		profile = string(runes[:maxProfileChars]) + "\n(truncated)"
+	}
+	return fmt.Sprintf("## About the Person You Are Talking To (%s)\n\n%s", personID, profile)
+}
+
 func (cb *ContextBuilder) BuildMessages(
 	history []providers.Message,
 	summary string,
 	currentMessage string,
 	media []string,
 	channel, chatID string,
+	personID string,
 ) []providers.Message {
 	messages := []providers.Message{}
 
@@ -477,10 +507,16 @@ func (cb *ContextBuilder) BuildMessages(
 		{Type: "text", Text: dynamicCtx},
 	}
 
+	if profile := cb.personContext(personID); profile != "" {
+		stringParts = append(stringParts, profile)
+		contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: profile})
+	}
+
 	if summary != "" {
 		summaryText := fmt.Sprintf(
 			"CONTEXT_SUMMARY: The following is an approximate summary of prior conversation "+
-				"for reference only. It may be incomplete or outdated — always defer to explicit instructions.\n\n%s",
+				"for reference only. It may be incomplete or outdated — always defer to explicit instructions. "+
+				"Pinned facts, if any, were marked as must-keep and stay valid until the user says otherwise.\n\n%s",
 			summary)
 		stringParts = append(stringParts, summaryText)
 		contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: summaryText})
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
@@ -31,12 +34,14 @@ type AgentInstance struct {
 	SummarizeMessageThreshold int
 	SummarizeTokenPercent     int
 	Provider                  providers.LLMProvider
-	Sessions                  *session.SessionManager
+	Sessions                  session.SessionStore
+	Recall                    *memory.Recall // nil when memory_search is disabled
 	ContextBuilder            *ContextBuilder
 	Tools                     *tools.ToolRegistry
 	Subagents                 *config.SubagentsConfig
 	SkillsFilter              []string
 	Candidates                []providers.FallbackCandidate
+	Budget                    *config.BudgetConfig
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -51,6 +56,7 @@ func NewAgentInstance(
 
 	model := resolveAgentModel(agentCfg, defaults)
 	fallbacks := resolveAgentFallbacks(agentCfg, defaults)
+	budget := resolveAgentBudget(agentCfg, defaults)
 
 	restrict := defaults.RestrictToWorkspace
 	readRestrict := restrict && !defaults.AllowReadOutsideWorkspace
@@ -70,12 +76,19 @@ func NewAgentInstance(
 	if cfg.Tools.IsToolEnabled("list_dir") {
 		toolsRegistry.Register(tools.NewListDirTool(workspace, readRestrict, allowReadPaths))
 	}
+	if cfg.Tools.IsToolEnabled("find_files") {
+		toolsRegistry.Register(tools.NewFindFilesTool(workspace, readRestrict, allowReadPaths))
+	}
+	if cfg.Tools.IsToolEnabled("grep_files") {
+		toolsRegistry.Register(tools.NewGrepFilesTool(workspace, readRestrict, allowReadPaths))
+	}
 	if cfg.Tools.IsToolEnabled("exec") {
 		execTool, err := tools.NewExecToolWithConfig(workspace, restrict, cfg)
 		if err != nil {
 			log.Fatalf("Critical error: unable to initialize exec tool: %v", err)
 		}
 		toolsRegistry.Register(execTool)
+		toolsRegistry.Register(tools.NewExecJobTool(execTool.Jobs()))
 	}
 
 	if cfg.Tools.IsToolEnabled("edit_file") {
@@ -84,12 +97,37 @@ func NewAgentInstance(
 	if cfg.Tools.IsToolEnabled("append_file") {
 		toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict, allowWritePaths))
 	}
+	if cfg.Tools.IsToolEnabled("apply_patch") {
+		toolsRegistry.Register(tools.NewApplyPatchTool(workspace, restrict, allowWritePaths))
+	}
 
 	sessionsDir := filepath.Join(workspace, "sessions")
-	sessionsManager := session.NewSessionManager(sessionsDir)
+	sessionsManager := initSessionStore(sessionsDir, defaults.SessionStore)
 
 	contextBuilder := NewContextBuilder(workspace)
 
+	var recall *memory.Recall
+	if cfg.Tools.IsToolEnabled("memory_search") {
+		var sessionStore memory.Store
+		if b, ok := sessionsManager.(*session.StoreBackend); ok {
+			sessionStore = b.Store()
+		}
+		recall = memory.NewRecall(filepath.Join(workspace, "memory"), sessionStore)
+		if name := cfg.Tools.MemorySearch.EmbeddingModel; name != "" {
+			if embedder, err := newEmbedder(cfg, name); err != nil {
+				logger.WarnCF("agent", "Memory search runs without embeddings",
+					map[string]any{"embedding_model": name, "error": err.Error()})
+			} else {
+				recall.SetEmbedder(embedder)
+			}
+		}
+		toolsRegistry.Register(tools.NewMemorySearchTool(recall))
+		contextBuilder.SetMemorySearch(true)
+	}
+	if cfg.Tools.IsToolEnabled("memory_write") {
+		toolsRegistry.Register(tools.NewMemoryWriteTool(contextBuilder.memory))
+	}
+
 	agentID := routing.DefaultAgentID
 	agentName := ""
 	var subagents *config.SubagentsConfig
@@ -112,6 +150,13 @@ func NewAgentInstance(
 		maxTokens = 8192
 	}
 
+	// Without a configured context window, max_tokens doubles as the history
+	// budget for summarization and no request is trimmed up front.
+	contextWindow := defaults.ContextWindow
+	if contextWindow <= maxTokens {
+		contextWindow = maxTokens
+	}
+
 	temperature := 0.7
 	if defaults.Temperature != nil {
 		temperature = *defaults.Temperature
@@ -138,47 +183,7 @@ func NewAgentInstance(
 		Primary:   model,
 		Fallbacks: fallbacks,
 	}
-	resolveFromModelList := func(raw string) (string, bool) {
-		ensureProtocol := func(model string) string {
-			model = strings.TrimSpace(model)
-			if model == "" {
-				return ""
-			}
-			if strings.Contains(model, "/") {
-				return model
-			}
-			return "openai/" + model
-		}
-
-		raw = strings.TrimSpace(raw)
-		if raw == "" {
-			return "", false
-		}
-
-		if cfg != nil {
-			if mc, err := cfg.GetModelConfig(raw); err == nil && mc != nil && strings.TrimSpace(mc.Model) != "" {
-				return ensureProtocol(mc.Model), true
-			}
-
-			for i := range cfg.ModelList {
-				fullModel := strings.TrimSpace(cfg.ModelList[i].Model)
-				if fullModel == "" {
-					continue
-				}
-				if fullModel == raw {
-					return ensureProtocol(fullModel), true
-				}
-				_, modelID := providers.ExtractProtocol(fullModel)
-				if modelID == raw {
-					return ensureProtocol(fullModel), true
-				}
-			}
-		}
-
-		return "", false
-	}
-
-	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, resolveFromModelList)
+	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, modelListLookup(cfg))
 
 	return &AgentInstance{
 		ID:                        agentID,
@@ -190,17 +195,32 @@ func NewAgentInstance(
 		MaxTokens:                 maxTokens,
 		Temperature:               temperature,
 		ThinkingLevel:             thinkingLevel,
-		ContextWindow:             maxTokens,
+		ContextWindow:             contextWindow,
 		SummarizeMessageThreshold: summarizeMessageThreshold,
 		SummarizeTokenPercent:     summarizeTokenPercent,
 		Provider:                  provider,
 		Sessions:                  sessionsManager,
+		Recall:                    recall,
 		ContextBuilder:            contextBuilder,
 		Tools:                     toolsRegistry,
 		Subagents:                 subagents,
 		SkillsFilter:              skillsFilter,
 		Candidates:                candidates,
+		Budget:                    budget,
+	}
+}
+
+// initSessionStore opens the configured session store in dir, migrating
+// legacy JSON sessions on first start. If the store cannot be opened the agent falls
+// back to the in-memory SessionManager so it keeps working.
+func initSessionStore(dir, backend string) session.SessionStore {
+	store, err := session.OpenStoreBackend(dir, backend)
+	if err != nil {
+		logger.ErrorCF("agent", "Failed to open session store, using JSON sessions",
+			map[string]any{"dir": dir, "backend": backend, "error": err.Error()})
+		return session.NewSessionManager(dir)
 	}
+	return store
 }
 
 // resolveAgentWorkspace determines the workspace directory for an agent.
@@ -233,6 +253,14 @@ func resolveAgentFallbacks(agentCfg *config.AgentConfig, defaults *config.AgentD
 	return defaults.ModelFallbacks
 }
 
+// resolveAgentBudget resolves the usage budget for an agent.
+func resolveAgentBudget(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) *config.BudgetConfig {
+	if agentCfg != nil && agentCfg.Budget != nil {
+		return agentCfg.Budget
+	}
+	return defaults.Budget
+}
+
 func compilePatterns(patterns []string) []*regexp.Regexp {
 	compiled := make([]*regexp.Regexp, 0, len(patterns))
 	for _, p := range patterns {
@@ -259,3 +287,74 @@ func expandHome(path string) string {
 	}
 	return path
 }
+
+// modelListLookup returns a candidate lookup that resolves a model_list name,
+// full model reference or bare model ID to its "protocol/model" reference.
+func modelListLookup(cfg *config.Config) func(raw string) (string, bool) {
+	return func(raw string) (string, bool) {
+		ensureProtocol := func(model string) string {
+			model = strings.TrimSpace(model)
+			if model == "" {
+				return ""
+			}
+			if strings.Contains(model, "/") {
+				return model
+			}
+			return "openai/" + model
+		}
+
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			return "", false
+		}
+
+		if cfg != nil {
+			if mc, err := cfg.GetModelConfig(raw); err == nil && mc != nil && strings.TrimSpace(mc.Model) != "" {
+				return ensureProtocol(mc.Model), true
+			}
+
+			for i := range cfg.ModelList {
+				fullModel := strings.TrimSpace(cfg.ModelList[i].Model)
+				if fullModel == "" {
+					continue
+				}
+				if fullModel == raw {
+					return ensureProtocol(fullModel), true
+				}
+				_, modelID := providers.ExtractProtocol(fullModel)
+				if modelID == raw {
+					return ensureProtocol(fullModel), true
+				}
+			}
+		}
+
+		return "", false
+	}
+}
+
+// modelEmbedder adapts a provider's embeddings API to memory.Embedder.
+type modelEmbedder struct {
+	provider providers.EmbeddingProvider
+	model    string
+}
+
+func (e modelEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.provider.Embed(ctx, texts, e.model)
+}
+
+// newEmbedder returns the embedder of the model_list entry name.
+func newEmbedder(cfg *config.Config, name string) (memory.Embedder, error) {
+	mc, err := cfg.GetModelConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	provider, modelID, err := providers.CreateProviderFromConfig(mc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider for %s: %w", name, err)
+	}
+	ep, ok := provider.(providers.EmbeddingProvider)
+	if !ok {
+		return nil, fmt.Errorf("the provider of %s has no embeddings API", name)
+	}
+	return modelEmbedder{provider: ep, model: modelID}, nil
+}
@@ -1,6 +1,10 @@
 package agent
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -160,3 +164,38 @@ func TestNewAgentInstance_ResolveCandidatesFromModelListAlias(t *testing.T) {
 		})
 	}
 }
+
+func TestNewEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/embeddings" || req.Model != "text-embedding-3-small" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[0.5,0.5]}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ModelList: []config.ModelConfig{{
+		ModelName: "embed",
+		Model:     "openai/text-embedding-3-small",
+		APIBase:   server.URL,
+		APIKey:    "key",
+	}}}
+	embedder, err := newEmbedder(cfg, "embed")
+	if err != nil {
+		t.Fatalf("newEmbedder() error = %v", err)
+	}
+	vectors, err := embedder.Embed(context.Background(), []string{"hello"})
+	if err != nil || len(vectors) != 1 || len(vectors[0]) != 2 {
+		t.Fatalf("Embed() = %v, %v", vectors, err)
+	}
+
+	if _, err := newEmbedder(cfg, "missing"); err == nil {
+		t.Error("newEmbedder() should fail for an unknown model")
+	}
+}
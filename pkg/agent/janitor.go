@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// maxExpiredSummaryMessages bounds how much of an expiring session is sent
+// to the model for its memory summary.
+const maxExpiredSummaryMessages = 100
+
+// RetentionPolicyFromConfig converts the session.retention config to a policy.
+func RetentionPolicyFromConfig(cfg config.SessionRetentionConfig) session.RetentionPolicy {
+	return session.RetentionPolicy{
+		MaxAge:      time.Duration(cfg.MaxIdleDays) * 24 * time.Hour,
+		MaxSessions: cfg.MaxSessions,
+		MaxMessages: cfg.MaxMessages,
+	}
+}
+
+// RunSessionJanitor enforces session.retention on the sessions of every
+// agent, once at start and then every interval_minutes, until ctx is done.
+// It returns immediately when no limit is configured.
+func (al *AgentLoop) RunSessionJanitor(ctx context.Context) {
+	if !al.startWorker() {
+		return
+	}
+	defer al.workers.Done()
+	retention := al.cfg.Session.Retention
+	policy := RetentionPolicyFromConfig(retention)
+	if policy.IsZero() && policy.MaxMessages <= 0 {
+		return
+	}
+	interval := time.Duration(retention.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	var janitors []*session.Janitor
+	var agentIDs []string
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok {
+			continue
+		}
+		backend, ok := agent.Sessions.(*session.StoreBackend)
+		if !ok {
+			logger.WarnCF("agent", "Session retention needs a session store, skipping agent",
+				map[string]any{"agent_id": id})
+			continue
+		}
+		var archiveDir string
+		if retention.Archive {
+			archiveDir = filepath.Join(agent.Workspace, "sessions", "archive")
+		}
+		onExpire := func(ctx context.Context, info memory.SessionInfo, history []providers.Message) error {
+			if retention.Summarize {
+				if err := al.rememberExpiredSession(ctx, agent, info, history); err != nil {
+					return err
+				}
+			}
+			al.forgetRoute(agent, info.Key)
+			return nil
+		}
+		janitors = append(janitors, session.NewJanitor(backend.Store(), policy, archiveDir, onExpire))
+		agentIDs = append(agentIDs, id)
+	}
+	if len(janitors) == 0 {
+		return
+	}
+
+	logger.InfoCF("agent", "Session retention enabled", map[string]any{
+		"max_idle_days": retention.MaxIdleDays,
+		"max_sessions":  retention.MaxSessions,
+		"max_messages":  retention.MaxMessages,
+		"interval":      interval.String(),
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for i, j := range janitors {
+			result, err := j.Sweep(ctx)
+			if err != nil {
+				logger.WarnCF("agent", "Session retention sweep failed",
+					map[string]any{"agent_id": agentIDs[i], "error": err.Error()})
+				continue
+			}
+			if result != (session.SweepResult{}) {
+				logger.InfoCF("agent", "Session retention sweep", map[string]any{
+					"agent_id": agentIDs[i],
+					"deleted":  result.Deleted,
+					"trimmed":  result.Trimmed,
+					"archived": result.Archived,
+				})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rememberExpiredSession summarizes a session that is about to be deleted.
+// The summary is private to the conversation, so it never goes to the
+// shared MEMORY.md: it is added to the profile of the person the session
+// belongs to, or else to a conversation note that memory_search recalls
+// only for the same session.
+func (al *AgentLoop) rememberExpiredSession(
+	ctx context.Context,
+	agent *AgentInstance,
+	info memory.SessionInfo,
+	history []providers.Message,
+) error {
+	var conversation []providers.Message
+	for _, m := range history {
+		if (m.Role == "user" || m.Role == "assistant") && m.Content != "" {
+			conversation = append(conversation, m)
+		}
+	}
+	if len(conversation) == 0 {
+		return nil
+	}
+	if len(conversation) > maxExpiredSummaryMessages {
+		conversation = conversation[len(conversation)-maxExpiredSummaryMessages:]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+	// The note covers the whole conversation, so compacted parts are fed in
+	// as messages rather than as context to leave out.
+	rs := parseRollingSummary(info.Summary)
+	if earlier := strings.TrimSpace(rs.Earlier + "\n\n" + strings.Join(rs.Recent, "\n\n")); earlier != "" {
+		conversation = append([]providers.Message{
+			{Role: "system", Content: "Summary of earlier messages: " + earlier},
+		}, conversation...)
+	}
+	summary, pins, err := al.summarizeBatch(ctx, agent, al.summaryModel(agent), conversation,
+		rollingSummary{Pinned: rs.Pinned})
+	if err != nil {
+		return fmt.Errorf("summarize session: %w", err)
+	}
+	if summary == "" {
+		return nil
+	}
+	rs.pin(pins...)
+	note := fmt.Sprintf("Conversation %s (%s to %s): %s",
+		info.Key, info.CreatedAt.Format("2006-01-02"), info.UpdatedAt.Format("2006-01-02"), summary)
+	if len(rs.Pinned) > 0 {
+		note += "\nPinned facts: " + strings.Join(rs.Pinned, "; ")
+	}
+
+	if agent.Recall != nil {
+		person, err := agent.Recall.SessionPerson(info.Key)
+		if err != nil {
+			return fmt.Errorf("find session person: %w", err)
+		}
+		if person != "" {
+			return agent.ContextBuilder.memory.AppendProfile(person, note)
+		}
+	}
+	return agent.ContextBuilder.memory.AppendConversationNote(info.Key, note)
+}
@@ -17,7 +17,6 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
@@ -30,7 +29,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
@@ -40,12 +41,27 @@ type AgentLoop struct {
 	cfg            *config.Config
 	registry       *AgentRegistry
 	state          *state.Manager
+	usage          *usage.Tracker
 	running        atomic.Bool
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
 	mediaStore     media.MediaStore
 	transcriber    voice.Transcriber
+	scheduler      *sessionScheduler
+	activeTurns    sync.Map // scheduling key → *activeTurn
+	approvals      *approvalBroker
+	setupErr       error    // configuration error that keeps the loop from running
+	modelProviders sync.Map // model_list entry → cachedProvider, for session model overrides
+	lastRoute      sync.Map // agentID:sessionKey → routeDecision
+	tokenizers     *tokenizer.Registry
+	newProvider    func(*config.ModelConfig) (providers.LLMProvider, string, error)
+
+	// Run, the session janitor and background summaries; Stop waits for
+	// them before closing the session stores.
+	workersMu sync.Mutex
+	workers   sync.WaitGroup
+	stopped   bool
 }
 
 // processOptions configures how a message is processed
@@ -59,6 +75,8 @@ type processOptions struct {
 	EnableSummary   bool     // Whether to trigger summarization
 	SendResponse    bool     // Whether to send response via bus
 	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	Stream          bool     // Whether to publish partial responses while the LLM generates
+	PersonID        string   // Profile key of the sender, "" for none
 }
 
 const defaultResponse = "I've completed processing but have no response to give. Increase `max_tool_iterations` in config.json."
@@ -80,18 +98,30 @@ func NewAgentLoop(
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
 	var stateManager *state.Manager
+	var usageTracker *usage.Tracker
 	if defaultAgent != nil {
 		stateManager = state.NewManager(defaultAgent.Workspace)
+		usageTracker = usage.NewTracker(usage.DefaultPath(defaultAgent.Workspace))
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
+		usage:       usageTracker,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		tokenizers:  tokenizer.NewRegistry(tokenizerDir()),
 	}
+	al.scheduler = newSessionScheduler(cfg.Agents.Defaults.MaxConcurrentSessions, al.handleInbound)
+	if err := al.setupApprovals(); err != nil {
+		logger.ErrorCF("agent", "Invalid tool approval config, refusing to run",
+			map[string]any{"error": err.Error()})
+		al.setupErr = err
+	}
+
+	return al
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
@@ -101,6 +131,42 @@ func registerSharedTools(
 	registry *AgentRegistry,
 	provider providers.LLMProvider,
 ) {
+	// The search tool is shared so that its result cache and provider
+	// cooldowns cover the searches of all agents.
+	var searchTool *tools.WebSearchTool
+	if cfg.Tools.IsToolEnabled("web") {
+		var err error
+		searchTool, err = tools.NewWebSearchTool(tools.WebSearchToolOptions{
+			BraveAPIKey:          cfg.Tools.Web.Brave.APIKey,
+			BraveMaxResults:      cfg.Tools.Web.Brave.MaxResults,
+			BraveEnabled:         cfg.Tools.Web.Brave.Enabled,
+			TavilyAPIKey:         cfg.Tools.Web.Tavily.APIKey,
+			TavilyBaseURL:        cfg.Tools.Web.Tavily.BaseURL,
+			TavilyMaxResults:     cfg.Tools.Web.Tavily.MaxResults,
+			TavilyEnabled:        cfg.Tools.Web.Tavily.Enabled,
+			DuckDuckGoMaxResults: cfg.Tools.Web.DuckDuckGo.MaxResults,
+			DuckDuckGoEnabled:    cfg.Tools.Web.DuckDuckGo.Enabled,
+			PerplexityAPIKey:     cfg.Tools.Web.Perplexity.APIKey,
+			PerplexityMaxResults: cfg.Tools.Web.Perplexity.MaxResults,
+			PerplexityEnabled:    cfg.Tools.Web.Perplexity.Enabled,
+			SearXNGBaseURL:       cfg.Tools.Web.SearXNG.BaseURL,
+			SearXNGMaxResults:    cfg.Tools.Web.SearXNG.MaxResults,
+			SearXNGEnabled:       cfg.Tools.Web.SearXNG.Enabled,
+			GLMSearchAPIKey:      cfg.Tools.Web.GLMSearch.APIKey,
+			GLMSearchBaseURL:     cfg.Tools.Web.GLMSearch.BaseURL,
+			GLMSearchEngine:      cfg.Tools.Web.GLMSearch.SearchEngine,
+			GLMSearchMaxResults:  cfg.Tools.Web.GLMSearch.MaxResults,
+			GLMSearchEnabled:     cfg.Tools.Web.GLMSearch.Enabled,
+			Proxy:                cfg.Tools.Web.Proxy,
+			Providers:            cfg.Tools.Web.Search.Providers,
+			MergeProviders:       cfg.Tools.Web.Search.MergeProviders,
+			CacheTTL:             time.Duration(cfg.Tools.Web.Search.CacheTTLSeconds) * time.Second,
+		})
+		if err != nil {
+			logger.ErrorCF("agent", "Failed to create web search tool", map[string]any{"error": err.Error()})
+		}
+	}
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -108,44 +174,40 @@ func registerSharedTools(
 		}
 
 		// Web tools
-		if cfg.Tools.IsToolEnabled("web") {
-			searchTool, err := tools.NewWebSearchTool(tools.WebSearchToolOptions{
-				BraveAPIKey:          cfg.Tools.Web.Brave.APIKey,
-				BraveMaxResults:      cfg.Tools.Web.Brave.MaxResults,
-				BraveEnabled:         cfg.Tools.Web.Brave.Enabled,
-				TavilyAPIKey:         cfg.Tools.Web.Tavily.APIKey,
-				TavilyBaseURL:        cfg.Tools.Web.Tavily.BaseURL,
-				TavilyMaxResults:     cfg.Tools.Web.Tavily.MaxResults,
-				TavilyEnabled:        cfg.Tools.Web.Tavily.Enabled,
-				DuckDuckGoMaxResults: cfg.Tools.Web.DuckDuckGo.MaxResults,
-				DuckDuckGoEnabled:    cfg.Tools.Web.DuckDuckGo.Enabled,
-				PerplexityAPIKey:     cfg.Tools.Web.Perplexity.APIKey,
-				PerplexityMaxResults: cfg.Tools.Web.Perplexity.MaxResults,
-				PerplexityEnabled:    cfg.Tools.Web.Perplexity.Enabled,
-				SearXNGBaseURL:       cfg.Tools.Web.SearXNG.BaseURL,
-				SearXNGMaxResults:    cfg.Tools.Web.SearXNG.MaxResults,
-				SearXNGEnabled:       cfg.Tools.Web.SearXNG.Enabled,
-				GLMSearchAPIKey:      cfg.Tools.Web.GLMSearch.APIKey,
-				GLMSearchBaseURL:     cfg.Tools.Web.GLMSearch.BaseURL,
-				GLMSearchEngine:      cfg.Tools.Web.GLMSearch.SearchEngine,
-				GLMSearchMaxResults:  cfg.Tools.Web.GLMSearch.MaxResults,
-				GLMSearchEnabled:     cfg.Tools.Web.GLMSearch.Enabled,
-				Proxy:                cfg.Tools.Web.Proxy,
-			})
-			if err != nil {
-				logger.ErrorCF("agent", "Failed to create web search tool", map[string]any{"error": err.Error()})
-			} else if searchTool != nil {
-				agent.Tools.Register(searchTool)
-			}
+		if searchTool != nil {
+			agent.Tools.Register(searchTool)
 		}
 		if cfg.Tools.IsToolEnabled("web_fetch") {
-			fetchTool, err := tools.NewWebFetchToolWithProxy(50000, cfg.Tools.Web.Proxy, cfg.Tools.Web.FetchLimitBytes)
+			fetchTool, err := tools.NewWebFetchToolWithOptions(tools.WebFetchToolOptions{
+				MaxChars:        50000,
+				Proxy:           cfg.Tools.Web.Proxy,
+				FetchLimitBytes: cfg.Tools.Web.FetchLimitBytes,
+				TrustedHosts:    cfg.Tools.Web.TrustedHosts,
+			})
 			if err != nil {
 				logger.ErrorCF("agent", "Failed to create web fetch tool", map[string]any{"error": err.Error()})
 			} else {
 				agent.Tools.Register(fetchTool)
 			}
 		}
+		if cfg.Tools.IsToolEnabled("http_request") {
+			secrets := make(map[string]tools.HTTPSecret, len(cfg.Tools.HTTPRequest.Secrets))
+			for name, secret := range cfg.Tools.HTTPRequest.Secrets {
+				secrets[name] = tools.HTTPSecret{Value: secret.Value, Hosts: secret.Hosts}
+			}
+			httpTool, err := tools.NewHTTPRequestTool(tools.HTTPRequestToolOptions{
+				Timeout:          time.Duration(cfg.Tools.HTTPRequest.TimeoutSeconds) * time.Second,
+				MaxResponseBytes: cfg.Tools.HTTPRequest.MaxResponseBytes,
+				Proxy:            cfg.Tools.Web.Proxy,
+				Secrets:          secrets,
+				TrustedHosts:     cfg.Tools.Web.TrustedHosts,
+			})
+			if err != nil {
+				logger.ErrorCF("agent", "Failed to create http request tool", map[string]any{"error": err.Error()})
+			} else {
+				agent.Tools.Register(httpTool)
+			}
+		}
 
 		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
 		if cfg.Tools.IsToolEnabled("i2c") {
@@ -212,6 +274,13 @@ func registerSharedTools(
 }
 
 func (al *AgentLoop) Run(ctx context.Context) error {
+	if al.setupErr != nil {
+		return al.setupErr
+	}
+	if !al.startWorker() {
+		return nil
+	}
+	defer al.workers.Done()
 	al.running.Store(true)
 
 	// Initialize MCP servers for all agents
@@ -281,6 +350,10 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 		}
 	}
 
+	// Wait for in-flight turns before the deferred MCP cleanup runs,
+	// since workers may still be executing MCP tools.
+	defer al.scheduler.Wait()
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -291,69 +364,163 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			// Process message
-			func() {
-				// TODO: Re-enable media cleanup after inbound media is properly consumed by the agent.
-				// Currently disabled because files are deleted before the LLM can access their content.
-				// defer func() {
-				// 	if al.mediaStore != nil && msg.MediaScope != "" {
-				// 		if releaseErr := al.mediaStore.ReleaseAll(msg.MediaScope); releaseErr != nil {
-				// 			logger.WarnCF("agent", "Failed to release media", map[string]any{
-				// 				"scope": msg.MediaScope,
-				// 				"error": releaseErr.Error(),
-				// 			})
-				// 		}
-				// 	}
-				// }()
-
-				response, err := al.processMessage(ctx, msg)
-				if err != nil {
-					response = fmt.Sprintf("Error processing message: %v", err)
-				}
+			// Approval replies go straight to the turn waiting for them,
+			// which is blocking its session's worker.
+			if al.approvals != nil && al.approvals.resolve(msg) {
+				continue
+			}
 
-				if response != "" {
-					// Check if the message tool already sent a response during this round.
-					// If so, skip publishing to avoid duplicate messages to the user.
-					// Use default agent's tools to check (message tool is shared).
-					alreadySent := false
-					defaultAgent := al.registry.GetDefaultAgent()
-					if defaultAgent != nil {
-						if tool, ok := defaultAgent.Tools.Get("message"); ok {
-							if mt, ok := tool.(*tools.MessageTool); ok {
-								alreadySent = mt.HasSentInRound()
-							}
-						}
-					}
+			// /stop bypasses the scheduler: the session's worker is busy with
+			// the very turn that should be aborted.
+			if msg.Channel != "system" && isStopCommand(msg.Content) {
+				al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+					Channel: msg.Channel,
+					ChatID:  msg.ChatID,
+					Content: al.stopTurn(msg),
+				})
+				continue
+			}
 
-					if !alreadySent {
-						al.bus.PublishOutbound(ctx, bus.OutboundMessage{
-							Channel: msg.Channel,
-							ChatID:  msg.ChatID,
-							Content: response,
-						})
-						logger.InfoCF("agent", "Published outbound response",
-							map[string]any{
-								"channel":     msg.Channel,
-								"chat_id":     msg.ChatID,
-								"content_len": len(response),
-							})
-					} else {
-						logger.DebugCF(
-							"agent",
-							"Skipped outbound (message tool already sent)",
-							map[string]any{"channel": msg.Channel},
-						)
-					}
-				}
-			}()
+			// Dispatch to the session's worker: turns within a session run in
+			// order, independent sessions run concurrently.
+			al.scheduler.Submit(ctx, al.schedulingKey(msg), msg)
 		}
 	}
 
 	return nil
 }
 
+// handleInbound processes a single inbound message and publishes the response.
+// It is invoked by the session scheduler, one message at a time per session.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	// TODO: Re-enable media cleanup after inbound media is properly consumed by the agent.
+	// Currently disabled because files are deleted before the LLM can access their content.
+	// defer func() {
+	// 	if al.mediaStore != nil && msg.MediaScope != "" {
+	// 		if releaseErr := al.mediaStore.ReleaseAll(msg.MediaScope); releaseErr != nil {
+	// 			logger.WarnCF("agent", "Failed to release media", map[string]any{
+	// 				"scope": msg.MediaScope,
+	// 				"error": releaseErr.Error(),
+	// 			})
+	// 		}
+	// 	}
+	// }()
+
+	ctx, endTurn := al.beginTurn(ctx, al.schedulingKey(msg))
+	defer endTurn()
+	ctx = tools.WithToolSender(ctx, msg.SenderID)
+	ctx = tools.WithToolPerson(ctx, al.personFor(msg))
+
+	response, err := al.processMessage(ctx, msg)
+	if err != nil {
+		response = fmt.Sprintf("Error processing message: %v", err)
+	}
+
+	if response == "" {
+		return
+	}
+
+	// Check if the message tool already sent a response during this round.
+	// If so, skip publishing to avoid duplicate messages to the user.
+	// Use default agent's tools to check (message tool is shared).
+	alreadySent := false
+	defaultAgent := al.registry.GetDefaultAgent()
+	if defaultAgent != nil {
+		if tool, ok := defaultAgent.Tools.Get("message"); ok {
+			if mt, ok := tool.(*tools.MessageTool); ok {
+				alreadySent = mt.HasSentInRound(msg.Channel, msg.ChatID)
+			}
+		}
+	}
+
+	if !alreadySent {
+		al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Content: response,
+		})
+		logger.InfoCF("agent", "Published outbound response",
+			map[string]any{
+				"channel":     msg.Channel,
+				"chat_id":     msg.ChatID,
+				"content_len": len(response),
+			})
+	} else {
+		logger.DebugCF(
+			"agent",
+			"Skipped outbound (message tool already sent)",
+			map[string]any{"channel": msg.Channel},
+		)
+	}
+}
+
+// schedulingKey returns the key used to serialize processing of msg.
+// It mirrors the session resolution in processMessage and
+// processSystemMessage, so turns that share a session history never overlap.
+func (al *AgentLoop) schedulingKey(msg bus.InboundMessage) string {
+	if msg.Channel == "system" {
+		if agent := al.registry.GetDefaultAgent(); agent != nil {
+			return routing.BuildAgentMainSessionKey(agent.ID)
+		}
+		return "system"
+	}
+	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
+		return msg.SessionKey
+	}
+	return al.registry.ResolveRoute(routeInputFor(msg)).SessionKey
+}
+
+// startWorker registers a goroutine that Stop waits for. It returns false,
+// and the goroutine must not run, once Stop has been called.
+func (al *AgentLoop) startWorker() bool {
+	al.workersMu.Lock()
+	defer al.workersMu.Unlock()
+	if al.stopped {
+		return false
+	}
+	al.workers.Add(1)
+	return true
+}
+
+// Stop shuts the loop down. The context passed to Run and RunSessionJanitor
+// must be canceled first: Stop waits for them to return.
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	al.workersMu.Lock()
+	al.stopped = true
+	al.workersMu.Unlock()
+
+	// Background jobs of the exec tool and spawned subagents would
+	// otherwise outlive the gateway.
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if tool, ok := agent.Tools.Get("exec"); ok {
+			if et, ok := tool.(*tools.ExecTool); ok {
+				et.Jobs().Shutdown()
+			}
+		}
+		if tool, ok := agent.Tools.Get("spawn"); ok {
+			if st, ok := tool.(*tools.SpawnTool); ok {
+				st.Shutdown()
+			}
+		}
+	}
+
+	// In-flight turns, summaries and retention sweeps still write to the
+	// sessions and the usage ledger.
+	al.workers.Wait()
+
+	if al.usage != nil {
+		if err := al.usage.Flush(); err != nil {
+			logger.WarnCF("agent", "Failed to save token usage", map[string]any{"error": err.Error()})
+		}
+	}
+
+	// Close the session stores last, once nothing writes to them.
+	al.registry.Close()
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
@@ -524,6 +691,9 @@ func (al *AgentLoop) ProcessHeartbeat(
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	if al.setupErr != nil {
+		return "", al.setupErr
+	}
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -555,14 +725,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	}
 
 	// Route to determine agent and session key
-	route := al.registry.ResolveRoute(routing.RouteInput{
-		Channel:    msg.Channel,
-		AccountID:  msg.Metadata["account_id"],
-		Peer:       extractPeer(msg),
-		ParentPeer: extractParentPeer(msg),
-		GuildID:    msg.Metadata["guild_id"],
-		TeamID:     msg.Metadata["team_id"],
-	})
+	route := al.registry.ResolveRoute(routeInputFor(msg))
 
 	agent, ok := al.registry.GetAgent(route.AgentID)
 	if !ok {
@@ -574,8 +737,8 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 
 	// Reset message-tool state for this round so we don't skip publishing due to a previous round.
 	if tool, ok := agent.Tools.Get("message"); ok {
-		if resetter, ok := tool.(interface{ ResetSentInRound() }); ok {
-			resetter.ResetSentInRound()
+		if resetter, ok := tool.(interface{ ResetSentInRound(channel, chatID string) }); ok {
+			resetter.ResetSentInRound(msg.Channel, msg.ChatID)
 		}
 	}
 
@@ -601,6 +764,8 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		DefaultResponse: defaultResponse,
 		EnableSummary:   true,
 		SendResponse:    false,
+		Stream:          al.cfg.Agents.Defaults.Streaming && !constants.IsInternalChannel(msg.Channel),
+		PersonID:        al.personFor(msg),
 	})
 }
 
@@ -704,6 +869,7 @@ func (al *AgentLoop) runAgentLoop(
 		opts.Media,
 		opts.Channel,
 		opts.ChatID,
+		opts.PersonID,
 	)
 
 	// Resolve media:// refs to base64 data URLs (streaming)
@@ -712,10 +878,33 @@ func (al *AgentLoop) runAgentLoop(
 
 	// 2. Save user message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
+	if agent.Recall != nil {
+		// memory_search only recalls a session for the person who had it.
+		if err := agent.Recall.AddSessionPerson(opts.SessionKey, opts.PersonID); err != nil {
+			logger.WarnCF("agent", "Failed to record session person",
+				map[string]any{
+					"session_key": opts.SessionKey,
+					"error":       err.Error(),
+				})
+		}
+	}
 
 	// 3. Run LLM iteration loop
+	ctx = tools.WithToolSession(ctx, opts.SessionKey)
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
+		if isTurnAborted(ctx) {
+			// Close the turn in history; the /stop reply informs the user.
+			agent.Sessions.AddMessage(opts.SessionKey, "assistant", turnAbortedNote)
+			agent.Sessions.Save(opts.SessionKey)
+			logger.InfoCF("agent", "Turn aborted",
+				map[string]any{
+					"agent_id":    agent.ID,
+					"session_key": opts.SessionKey,
+					"iterations":  iteration,
+				})
+			return "", nil
+		}
 		return "", err
 	}
 
@@ -824,7 +1013,12 @@ func (al *AgentLoop) runLLMIteration(
 	iteration := 0
 	var finalContent string
 
+	model := al.modelForTurn(ctx, agent, opts)
+
 	for iteration < agent.MaxIterations {
+		if err := ctx.Err(); err != nil {
+			return "", iteration, err
+		}
 		iteration++
 
 		logger.DebugCF("agent", "LLM iteration",
@@ -836,13 +1030,14 @@ func (al *AgentLoop) runLLMIteration(
 
 		// Build tool definitions
 		providerToolDefs := agent.Tools.ToProviderDefs()
+		messages = al.fitToContext(agent, model.Model, messages, providerToolDefs)
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
 			map[string]any{
 				"agent_id":          agent.ID,
 				"iteration":         iteration,
-				"model":             agent.Model,
+				"model":             model.Model,
 				"messages_count":    len(messages),
 				"tools_count":       len(providerToolDefs),
 				"max_tokens":        agent.MaxTokens,
@@ -858,6 +1053,21 @@ func (al *AgentLoop) runLLMIteration(
 				"tools_json":    formatToolsForLog(providerToolDefs),
 			})
 
+		// Enforce the agent's usage budget: refuse, or pin a cheaper fallback
+		// of the turn's model.
+		callModel := model
+		if limit := al.budgetExceeded(agent); limit != "" {
+			downgrade, ok := al.downgradeCandidate(agent, model)
+			if !ok {
+				logger.WarnCF("agent", "Usage budget exceeded, refusing LLM call",
+					map[string]any{"agent_id": agent.ID, "limit": limit})
+				return budgetRefusal(limit), iteration, nil
+			}
+			logger.InfoCF("agent", "Usage budget exceeded, downgrading model",
+				map[string]any{"agent_id": agent.ID, "limit": limit, "model": downgrade.Model})
+			callModel = downgrade
+		}
+
 		// Call LLM with fallback chain if candidates are configured.
 		var response *providers.LLMResponse
 		var err error
@@ -869,27 +1079,46 @@ func (al *AgentLoop) runLLMIteration(
 		}
 		// parseThinkingLevel guarantees ThinkingOff for empty/unknown values,
 		// so checking != ThinkingOff is sufficient.
-		if agent.ThinkingLevel != ThinkingOff {
-			if tc, ok := agent.Provider.(providers.ThinkingCapable); ok && tc.SupportsThinking() {
-				llmOpts["thinking_level"] = string(agent.ThinkingLevel)
+		if callModel.ThinkingLevel != ThinkingOff {
+			if tc, ok := callModel.Provider.(providers.ThinkingCapable); ok && tc.SupportsThinking() {
+				llmOpts["thinking_level"] = string(callModel.ThinkingLevel)
 			} else {
 				logger.WarnCF("agent", "thinking_level is set but current provider does not support it, ignoring",
-					map[string]any{"agent_id": agent.ID, "thinking_level": string(agent.ThinkingLevel)})
+					map[string]any{"agent_id": agent.ID, "thinking_level": string(callModel.ThinkingLevel)})
+			}
+		}
+
+		// Stream partial content to the originating chat when the provider
+		// supports it; otherwise fall back to the blocking Chat call.
+		chat := callModel.Provider.Chat
+		if sp, ok := callModel.Provider.(providers.StreamingProvider); ok && opts.Stream {
+			publisher := newStreamPublisher(ctx, al.bus, opts.Channel, opts.ChatID)
+			chat = func(
+				ctx context.Context,
+				messages []providers.Message,
+				tools []providers.ToolDefinition,
+				model string,
+				options map[string]any,
+			) (*providers.LLMResponse, error) {
+				publisher.Reset()
+				return sp.ChatStream(ctx, messages, tools, model, options, publisher.OnDelta)
 			}
 		}
 
+		usedModel := callModel.Model
 		callLLM := func() (*providers.LLMResponse, error) {
-			if len(agent.Candidates) > 1 && al.fallback != nil {
+			if len(callModel.Candidates) > 1 && al.fallback != nil {
 				fbResult, fbErr := al.fallback.Execute(
 					ctx,
-					agent.Candidates,
+					callModel.Candidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, llmOpts)
+						return chat(ctx, messages, providerToolDefs, model, llmOpts)
 					},
 				)
 				if fbErr != nil {
 					return nil, fbErr
 				}
+				usedModel = fbResult.Model
 				if fbResult.Provider != "" && len(fbResult.Attempts) > 0 {
 					logger.InfoCF(
 						"agent",
@@ -900,7 +1129,7 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, llmOpts)
+			return chat(ctx, messages, providerToolDefs, callModel.Model, llmOpts)
 		}
 
 		// Retry loop for context/token errors
@@ -938,7 +1167,11 @@ func (al *AgentLoop) runLLMIteration(
 					"retry":   retry,
 					"backoff": backoff.String(),
 				})
-				time.Sleep(backoff)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return "", iteration, ctx.Err()
+				}
 				continue
 			}
 
@@ -965,7 +1198,7 @@ func (al *AgentLoop) runLLMIteration(
 				newSummary := agent.Sessions.GetSummary(opts.SessionKey)
 				messages = agent.ContextBuilder.BuildMessages(
 					newHistory, newSummary, "",
-					nil, opts.Channel, opts.ChatID,
+					nil, opts.Channel, opts.ChatID, opts.PersonID,
 				)
 				continue
 			}
@@ -982,6 +1215,8 @@ func (al *AgentLoop) runLLMIteration(
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
+		al.recordUsage(agent, opts.SessionKey, usedModel, response.Usage)
+
 		go al.handleReasoning(
 			ctx,
 			response.Reasoning,
@@ -1062,33 +1297,27 @@ func (al *AgentLoop) runLLMIteration(
 		// Save assistant message with tool calls to session
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
-		// Execute tool calls in parallel
-		type indexedAgentResult struct {
-			result *tools.ToolResult
-			tc     providers.ToolCall
+		// Execute tool calls; concurrency-safe tools run in parallel and
+		// results keep the order of the calls.
+		for _, tc := range normalizedToolCalls {
+			argsJSON, _ := json.Marshal(tc.Arguments)
+			argsPreview := utils.Truncate(string(argsJSON), 200)
+			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+				map[string]any{
+					"agent_id":  agent.ID,
+					"tool":      tc.Name,
+					"iteration": iteration,
+				})
 		}
 
-		agentResults := make([]indexedAgentResult, len(normalizedToolCalls))
-		var wg sync.WaitGroup
-
-		for i, tc := range normalizedToolCalls {
-			agentResults[i].tc = tc
-
-			wg.Add(1)
-			go func(idx int, tc providers.ToolCall) {
-				defer wg.Done()
-
-				argsJSON, _ := json.Marshal(tc.Arguments)
-				argsPreview := utils.Truncate(string(argsJSON), 200)
-				logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-					map[string]any{
-						"agent_id":  agent.ID,
-						"tool":      tc.Name,
-						"iteration": iteration,
-					})
-
-				// Create async callback for tools that implement AsyncExecutor
-				asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
+		toolResults := agent.Tools.ExecuteBatch(ctx, normalizedToolCalls, tools.BatchOptions{
+			Channel:     opts.Channel,
+			ChatID:      opts.ChatID,
+			MaxParallel: al.cfg.Agents.Defaults.MaxParallelToolCalls,
+			Timeout:     time.Duration(al.cfg.Agents.Defaults.ToolTimeout) * time.Second,
+			// Create async callback for tools that implement AsyncExecutor
+			AsyncCallback: func(tc providers.ToolCall) tools.AsyncCallback {
+				return func(callbackCtx context.Context, result *tools.ToolResult) {
 					if !result.Silent && result.ForUser != "" {
 						logger.InfoCF("agent", "Async tool completed, agent will handle notification",
 							map[string]any{
@@ -1097,19 +1326,17 @@ func (al *AgentLoop) runLLMIteration(
 							})
 					}
 				}
+			},
+		})
 
-				toolResult := agent.Tools.ExecuteWithContext(
-					ctx,
-					tc.Name,
-					tc.Arguments,
-					opts.Channel,
-					opts.ChatID,
-					asyncCallback,
-				)
-				agentResults[idx].result = toolResult
-			}(i, tc)
+		type indexedAgentResult struct {
+			result *tools.ToolResult
+			tc     providers.ToolCall
+		}
+		agentResults := make([]indexedAgentResult, len(normalizedToolCalls))
+		for i, tc := range normalizedToolCalls {
+			agentResults[i] = indexedAgentResult{result: toolResults[i], tc: tc}
 		}
-		wg.Wait()
 
 		// Process results in original order (send to user, save to session)
 		for _, r := range agentResults {
@@ -1172,13 +1399,18 @@ func (al *AgentLoop) runLLMIteration(
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
-	tokenEstimate := al.estimateTokens(newHistory)
+	tokenEstimate := al.countTokens(agent.Model, newHistory, nil)
 	threshold := agent.ContextWindow * agent.SummarizeTokenPercent / 100
 
 	if len(newHistory) > agent.SummarizeMessageThreshold || tokenEstimate > threshold {
 		summarizeKey := agent.ID + ":" + sessionKey
 		if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); !loading {
+			if !al.startWorker() {
+				al.summarizing.Delete(summarizeKey)
+				return
+			}
 			go func() {
+				defer al.workers.Done()
 				defer al.summarizing.Delete(summarizeKey)
 				logger.Debug("Memory threshold reached. Optimizing conversation history...")
 				al.summarizeSession(agent, sessionKey)
@@ -1327,13 +1559,14 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 	return sb.String()
 }
 
-// summarizeSession summarizes the conversation history for a session.
+// summarizeSession compacts the history of a session into its rolling
+// summary, keeping the last 4 messages for continuity.
 func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
 	history := agent.Sessions.GetHistory(sessionKey)
-	summary := agent.Sessions.GetSummary(sessionKey)
+	rs := parseRollingSummary(agent.Sessions.GetSummary(sessionKey))
 
 	// Keep last 4 messages for continuity
 	if len(history) <= 4 {
@@ -1346,12 +1579,13 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	maxMessageTokens := agent.ContextWindow / 2
 	validMessages := make([]providers.Message, 0)
 	omitted := false
+	tok := al.tokenizers.ForModel(agent.Model)
 
 	for _, m := range toSummarize {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		msgTokens := len(m.Content) / 2
+		msgTokens := tok.Count(m.Content)
 		if msgTokens > maxMessageTokens {
 			omitted = true
 			continue
@@ -1363,101 +1597,51 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		return
 	}
 
-	// Multi-Part Summarization
-	var finalSummary string
+	// Multi-Part Summarization: each part becomes a recent segment
+	parts := [][]providers.Message{validMessages}
 	if len(validMessages) > 10 {
 		mid := len(validMessages) / 2
-		part1 := validMessages[:mid]
-		part2 := validMessages[mid:]
-
-		s1, _ := al.summarizeBatch(ctx, agent, part1, "")
-		s2, _ := al.summarizeBatch(ctx, agent, part2, "")
-
-		mergePrompt := fmt.Sprintf(
-			"Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s",
-			s1,
-			s2,
-		)
-		resp, err := agent.Provider.Chat(
-			ctx,
-			[]providers.Message{{Role: "user", Content: mergePrompt}},
-			nil,
-			agent.Model,
-			map[string]any{
-				"max_tokens":       1024,
-				"temperature":      0.3,
-				"prompt_cache_key": agent.ID,
-			},
-		)
-		if err == nil {
-			finalSummary = resp.Content
-		} else {
-			finalSummary = s1 + " " + s2
-		}
-	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, agent, validMessages, summary)
+		parts = [][]providers.Message{validMessages[:mid], validMessages[mid:]}
 	}
 
-	if omitted && finalSummary != "" {
-		finalSummary += "\n[Note: Some oversized messages were omitted from this summary for efficiency.]"
+	sel := al.summaryModel(agent)
+	var pins []string
+	for _, part := range parts {
+		segment, partPins, err := al.summarizeBatch(ctx, agent, sel, part, rs)
+		if err != nil {
+			logger.WarnCF("agent", "Session summarization failed",
+				map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "error": err.Error()})
+			return
+		}
+		if segment == "" {
+			return
+		}
+		rs.Recent = append(rs.Recent, segment)
+		pins = append(pins, partPins...)
 	}
 
-	if finalSummary != "" {
-		agent.Sessions.SetSummary(sessionKey, finalSummary)
-		agent.Sessions.TruncateHistory(sessionKey, 4)
-		agent.Sessions.Save(sessionKey)
+	if omitted {
+		rs.Recent[len(rs.Recent)-1] += "\n[Note: Some oversized messages were omitted from this summary for efficiency.]"
 	}
-}
 
-// summarizeBatch summarizes a batch of messages.
-func (al *AgentLoop) summarizeBatch(
-	ctx context.Context,
-	agent *AgentInstance,
-	batch []providers.Message,
-	existingSummary string,
-) (string, error) {
-	var sb strings.Builder
-	sb.WriteString(
-		"Provide a concise summary of this conversation segment, preserving core context and key points.\n",
-	)
-	if existingSummary != "" {
-		sb.WriteString("Existing context: ")
-		sb.WriteString(existingSummary)
-		sb.WriteString("\n")
-	}
-	sb.WriteString("\nCONVERSATION:\n")
-	for _, m := range batch {
-		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
+	if len(rs.Recent) > maxRecentSegments {
+		if err := al.foldSummary(ctx, agent, sel, &rs); err != nil {
+			// Keep the segments; the next compaction retries the fold.
+			logger.WarnCF("agent", "Failed to fold session summary",
+				map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "error": err.Error()})
+		}
 	}
-	prompt := sb.String()
 
-	response, err := agent.Provider.Chat(
-		ctx,
-		[]providers.Message{{Role: "user", Content: prompt}},
-		nil,
-		agent.Model,
-		map[string]any{
-			"max_tokens":       1024,
-			"temperature":      0.3,
-			"prompt_cache_key": agent.ID,
-		},
-	)
-	if err != nil {
-		return "", err
+	// Facts may have been pinned or unpinned while the model was busy.
+	rs.Pinned = parseRollingSummary(agent.Sessions.GetSummary(sessionKey)).Pinned
+	if !rs.pin(pins...) {
+		logger.WarnCF("agent", "Pinned fact limit reached, dropping new pins",
+			map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "limit": maxPinnedFacts})
 	}
-	return response.Content, nil
-}
 
-// estimateTokens estimates the number of tokens in a message list.
-// Uses a safe heuristic of 2.5 characters per token to account for CJK and other
-// overheads better than the previous 3 chars/token.
-func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
-	totalChars := 0
-	for _, m := range messages {
-		totalChars += utf8.RuneCountInString(m.Content)
-	}
-	// 2.5 chars per token = totalChars * 2 / 5
-	return totalChars * 2 / 5
+	agent.Sessions.SetSummary(sessionKey, rs.String())
+	agent.Sessions.TruncateHistory(sessionKey, 4)
+	agent.Sessions.Save(sessionKey)
 }
 
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
@@ -1475,17 +1659,47 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 	args := parts[1:]
 
 	switch cmd {
+	case "/stop":
+		return al.stopTurn(msg), true
+
+	case "/usage":
+		return al.usageReport(msg), true
+
+	case "/new":
+		return al.newSession(msg, true), true
+
+	case "/reset":
+		return al.newSession(msg, false), true
+
+	case "/undo":
+		return al.undoLastExchange(msg), true
+
+	case "/history":
+		return al.sessionHistory(msg, args), true
+
+	case "/compact":
+		return al.compactSession(msg), true
+
+	case "/pin":
+		return al.pinFact(msg, strings.TrimPrefix(content, cmd)), true
+
+	case "/pins":
+		return al.listPins(msg), true
+
+	case "/unpin":
+		return al.unpinFact(msg, strings.Join(args, " ")), true
+
 	case "/show":
 		if len(args) < 1 {
 			return "Usage: /show [model|channel|agents]", true
 		}
 		switch args[0] {
 		case "model":
-			defaultAgent := al.registry.GetDefaultAgent()
-			if defaultAgent == nil {
+			agent, sessionKey := al.sessionFor(msg)
+			if agent == nil {
 				return "No default agent configured", true
 			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
+			return fmt.Sprintf("Current model: %s", al.currentModelName(agent, sessionKey)), true
 		case "channel":
 			return fmt.Sprintf("Current channel: %s", msg.Channel), true
 		case "agents":
@@ -1501,7 +1715,11 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		}
 		switch args[0] {
 		case "models":
-			return "Available models: configured in config.json per agent", true
+			names := al.modelNames()
+			if len(names) == 0 {
+				return "Available models: configured in config.json per agent", true
+			}
+			return fmt.Sprintf("Available models: %s", strings.Join(names, ", ")), true
 		case "channels":
 			if al.channelManager == nil {
 				return "Channel manager not initialized", true
@@ -1527,13 +1745,7 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 
 		switch target {
 		case "model":
-			defaultAgent := al.registry.GetDefaultAgent()
-			if defaultAgent == nil {
-				return "No default agent configured", true
-			}
-			oldModel := defaultAgent.Model
-			defaultAgent.Model = value
-			return fmt.Sprintf("Switched model from %s to %s", oldModel, value), true
+			return al.switchSessionModel(msg, value), true
 		case "channel":
 			if al.channelManager == nil {
 				return "Channel manager not initialized", true
@@ -1550,6 +1762,18 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 	return "", false
 }
 
+// routeInputFor builds the routing input for an inbound message.
+func routeInputFor(msg bus.InboundMessage) routing.RouteInput {
+	return routing.RouteInput{
+		Channel:    msg.Channel,
+		AccountID:  msg.Metadata["account_id"],
+		Peer:       extractPeer(msg),
+		ParentPeer: extractParentPeer(msg),
+		GuildID:    msg.Metadata["guild_id"],
+		TeamID:     msg.Metadata["team_id"],
+	}
+}
+
 // extractPeer extracts the routing peer from the inbound message's structured Peer field.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	if msg.Peer.Kind == "" {
@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// pendingApproval is a tool call waiting for a reply in one chat.
+type pendingApproval struct {
+	requestedBy string
+	reply       chan approvalReply
+}
+
+type approvalReply struct {
+	approved bool
+	senderID string
+}
+
+// approvalBroker implements tools.Approver over the message bus: it posts the
+// approval prompt to the chat the turn came from and waits for the reply,
+// which Run hands over via resolve before the message reaches the scheduler.
+type approvalBroker struct {
+	bus       *bus.MessageBus
+	approvers []string
+
+	mu      sync.Mutex
+	pending map[string]*pendingApproval // channel:chatID → waiting request
+	slots   map[string]chan struct{}    // one open prompt per chat at a time
+}
+
+func newApprovalBroker(msgBus *bus.MessageBus, approvers []string) *approvalBroker {
+	return &approvalBroker{
+		bus:       msgBus,
+		approvers: approvers,
+		pending:   make(map[string]*pendingApproval),
+		slots:     make(map[string]chan struct{}),
+	}
+}
+
+func (b *approvalBroker) slot(key string) chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.slots[key]
+	if !ok {
+		s = make(chan struct{}, 1)
+		b.slots[key] = s
+	}
+	return s
+}
+
+// RequestApproval implements tools.Approver.
+func (b *approvalBroker) RequestApproval(
+	ctx context.Context,
+	req tools.ApprovalRequest,
+) (bool, string, error) {
+	if req.Channel == "" || req.ChatID == "" || constants.IsInternalChannel(req.Channel) {
+		return false, "", tools.ErrNoApprover
+	}
+
+	timer := time.NewTimer(req.Timeout)
+	defer timer.Stop()
+
+	key := req.Channel + ":" + req.ChatID
+	slot := b.slot(key)
+	select {
+	case slot <- struct{}{}:
+	case <-timer.C:
+		return false, "", tools.ErrApprovalTimeout
+	case <-ctx.Done():
+		return false, "", ctx.Err()
+	}
+	defer func() { <-slot }()
+
+	p := &pendingApproval{
+		requestedBy: req.RequestedBy,
+		reply:       make(chan approvalReply, 1),
+	}
+	b.mu.Lock()
+	b.pending[key] = p
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, key)
+		b.mu.Unlock()
+	}()
+
+	if err := b.bus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: req.Channel,
+		ChatID:  req.ChatID,
+		Content: approvalPrompt(req),
+	}); err != nil {
+		return false, "", fmt.Errorf("failed to send approval prompt: %w", err)
+	}
+
+	select {
+	case r := <-p.reply:
+		return r.approved, r.senderID, nil
+	case <-timer.C:
+		return false, "", tools.ErrApprovalTimeout
+	case <-ctx.Done():
+		return false, "", ctx.Err()
+	}
+}
+
+// resolve delivers msg to the approval waiting in its chat if msg is an
+// approve/deny reply from someone allowed to decide. It reports whether msg
+// was consumed.
+func (b *approvalBroker) resolve(msg bus.InboundMessage) bool {
+	approved, ok := parseApprovalReply(msg.Content)
+	if !ok {
+		return false
+	}
+
+	b.mu.Lock()
+	p := b.pending[msg.Channel+":"+msg.ChatID]
+	b.mu.Unlock()
+	if p == nil || !b.canDecide(p, msg) {
+		return false
+	}
+
+	select {
+	case p.reply <- approvalReply{approved: approved, senderID: msg.SenderID}:
+		return true
+	default:
+		return false // already answered
+	}
+}
+
+func (b *approvalBroker) canDecide(p *pendingApproval, msg bus.InboundMessage) bool {
+	if len(b.approvers) > 0 {
+		return slices.Contains(b.approvers, msg.SenderID) ||
+			(msg.Sender.CanonicalID != "" && slices.Contains(b.approvers, msg.Sender.CanonicalID))
+	}
+	return p.requestedBy == "" || p.requestedBy == msg.SenderID
+}
+
+// parseApprovalReply recognizes approval replies such as "/approve" or "no".
+func parseApprovalReply(content string) (approved, ok bool) {
+	fields := strings.Fields(strings.ToLower(content))
+	if len(fields) != 1 {
+		return false, false
+	}
+	switch strings.TrimSuffix(fields[0], ".") {
+	case "/approve", "approve", "yes", "y":
+		return true, true
+	case "/deny", "deny", "no", "n":
+		return false, true
+	}
+	return false, false
+}
+
+func approvalPrompt(req tools.ApprovalRequest) string {
+	argsJSON, _ := json.Marshal(req.Args)
+	return fmt.Sprintf(
+		"Approval required: the agent wants to run %s with %s\nReply /approve or /deny (expires in %s).",
+		req.Tool, utils.Truncate(string(argsJSON), 500), req.Timeout,
+	)
+}
+
+// setupApprovals installs the approval policy on every agent's tool registry.
+// It fails if the rules are invalid; the loop then refuses to run rather
+// than execute tools unguarded.
+func (al *AgentLoop) setupApprovals() error {
+	cfg := al.cfg.Tools.Approval
+	if !cfg.Enabled {
+		return nil
+	}
+
+	al.approvals = newApprovalBroker(al.bus, cfg.Approvers)
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		auditPath := filepath.Join(agent.Workspace, "state", "approvals.jsonl")
+		policy, err := tools.NewApprovalPolicy(cfg, al.approvals, auditPath)
+		if err != nil {
+			return fmt.Errorf("tools.approval: %w", err)
+		}
+		agent.Tools.SetApprovalPolicy(policy)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// errTurnAborted is the cancellation cause of a turn stopped with /stop.
+var errTurnAborted = errors.New("turn aborted by user")
+
+// turnAbortedNote is recorded in the session in place of the assistant reply
+// of an aborted turn, so the next turn sees why the previous one ended.
+const turnAbortedNote = "[Turn aborted by user]"
+
+// activeTurn is the cancellation handle of a turn that is being processed.
+type activeTurn struct {
+	cancel context.CancelCauseFunc
+}
+
+// beginTurn derives a cancellable context for a turn of the given session and
+// registers it so /stop can abort it. The returned func must be called when
+// the turn ends.
+func (al *AgentLoop) beginTurn(ctx context.Context, key string) (context.Context, func()) {
+	turnCtx, cancel := context.WithCancelCause(ctx)
+	turn := &activeTurn{cancel: cancel}
+	al.activeTurns.Store(key, turn)
+	return turnCtx, func() {
+		al.activeTurns.CompareAndDelete(key, turn)
+		cancel(nil)
+	}
+}
+
+// isTurnAborted reports whether ctx was canceled by /stop.
+func isTurnAborted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errTurnAborted)
+}
+
+// isStopCommand reports whether content is the /stop command.
+func isStopCommand(content string) bool {
+	fields := strings.Fields(content)
+	return len(fields) > 0 && fields[0] == "/stop"
+}
+
+// stopTurn aborts the in-flight turn of the session msg belongs to and
+// cancels background subagents spawned from the same chat. Aborting the turn
+// context cancels the pending LLM request and kills running exec processes.
+func (al *AgentLoop) stopTurn(msg bus.InboundMessage) string {
+	key := al.schedulingKey(msg)
+
+	stopped := false
+	if v, ok := al.activeTurns.Load(key); ok {
+		v.(*activeTurn).cancel(errTurnAborted)
+		stopped = true
+	}
+
+	canceledTasks := 0
+	agent, ok := al.registry.GetAgent(al.registry.ResolveRoute(routeInputFor(msg)).AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+	if agent != nil {
+		if tool, ok := agent.Tools.Get("spawn"); ok {
+			if canceler, ok := tool.(interface {
+				CancelTasks(channel, chatID string) int
+			}); ok {
+				canceledTasks = canceler.CancelTasks(msg.Channel, msg.ChatID)
+			}
+		}
+	}
+
+	logger.InfoCF("agent", "Stop requested",
+		map[string]any{
+			"session_key":    key,
+			"turn_stopped":   stopped,
+			"tasks_canceled": canceledTasks,
+		})
+
+	switch {
+	case stopped && canceledTasks > 0:
+		return fmt.Sprintf("Stopped the current turn and canceled %d background task(s).", canceledTasks)
+	case stopped:
+		return "Stopped the current turn."
+	case canceledTasks > 0:
+		return fmt.Sprintf("Canceled %d background task(s).", canceledTasks)
+	default:
+		return "Nothing to stop."
+	}
+}
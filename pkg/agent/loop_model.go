@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// modelSelection is the model a turn runs with: the agent's configured model,
+// the session's override or the tier picked by model routing.
+type modelSelection struct {
+	Name          string // model_list name, "" for the agent model
+	Model         string
+	Provider      providers.LLMProvider
+	Candidates    []providers.FallbackCandidate
+	ThinkingLevel ThinkingLevel
+}
+
+func agentModelSelection(agent *AgentInstance) modelSelection {
+	return modelSelection{
+		Model:         agent.Model,
+		Provider:      agent.Provider,
+		Candidates:    agent.Candidates,
+		ThinkingLevel: agent.ThinkingLevel,
+	}
+}
+
+// selectModel returns the model selection for a session. An override that is
+// no longer usable falls back to the agent's model.
+func (al *AgentLoop) selectModel(agent *AgentInstance, sessionKey string) modelSelection {
+	name := agent.Sessions.GetModel(sessionKey)
+	if name == "" {
+		return agentModelSelection(agent)
+	}
+
+	sel, err := al.resolveModel(agent, name)
+	if err != nil {
+		logger.WarnCF("agent", "Session model override unusable, using agent model",
+			map[string]any{
+				"agent_id":    agent.ID,
+				"session_key": sessionKey,
+				"model":       name,
+				"error":       err.Error(),
+			})
+		return agentModelSelection(agent)
+	}
+	return sel
+}
+
+// modelForTurn returns the model a turn runs with: the session's override if
+// set, otherwise the tier chosen by model routing when it is enabled.
+func (al *AgentLoop) modelForTurn(ctx context.Context, agent *AgentInstance, opts processOptions) modelSelection {
+	if agent.Sessions.GetModel(opts.SessionKey) != "" {
+		return al.selectModel(agent, opts.SessionKey)
+	}
+
+	rc := al.cfg.Agents.Defaults.Routing
+	if rc == nil || !rc.Enabled || strings.TrimSpace(opts.UserMessage) == "" {
+		return agentModelSelection(agent)
+	}
+
+	decision := al.routeTurn(ctx, agent, rc, opts)
+	al.lastRoute.Store(routeKey(agent, opts.SessionKey), decision)
+
+	sel := agentModelSelection(agent)
+	if decision.Model != "" {
+		routed, err := al.resolveModel(agent, decision.Model)
+		if err != nil {
+			logger.WarnCF("agent", "Routed model unusable, using agent model",
+				map[string]any{
+					"agent_id": agent.ID,
+					"tier":     string(decision.Tier),
+					"model":    decision.Model,
+					"error":    err.Error(),
+				})
+		} else {
+			sel = routed
+		}
+	}
+
+	logger.InfoCF("agent", "Model routing decision",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": opts.SessionKey,
+			"tier":        string(decision.Tier),
+			"reason":      decision.Reason,
+			"model":       sel.Model,
+		})
+	return sel
+}
+
+// routeKey is the lastRoute key of a session.
+func routeKey(agent *AgentInstance, sessionKey string) string {
+	return agent.ID + ":" + sessionKey
+}
+
+// forgetRoute drops the last routing decision of a session that was reset
+// or expired, so a new conversation does not follow up on the old one.
+func (al *AgentLoop) forgetRoute(agent *AgentInstance, sessionKey string) {
+	al.lastRoute.Delete(routeKey(agent, sessionKey))
+}
+
+// resolveModel builds the selection for a model_list entry, keeping the
+// agent's fallbacks behind it.
+func (al *AgentLoop) resolveModel(agent *AgentInstance, name string) (modelSelection, error) {
+	mc, err := al.cfg.GetModelConfig(name)
+	if err != nil {
+		return modelSelection{}, err
+	}
+
+	provider, modelID, err := al.providerFor(mc)
+	if err != nil {
+		return modelSelection{}, fmt.Errorf("failed to create provider for %s: %w", name, err)
+	}
+
+	candidates := providers.ResolveCandidatesWithLookup(
+		providers.ModelConfig{Primary: name, Fallbacks: agent.Fallbacks},
+		al.cfg.Agents.Defaults.Provider,
+		modelListLookup(al.cfg),
+	)
+
+	return modelSelection{
+		Name:          name,
+		Model:         modelID,
+		Provider:      provider,
+		Candidates:    candidates,
+		ThinkingLevel: parseThinkingLevel(mc.ThinkingLevel),
+	}, nil
+}
+
+// providerFor returns a provider for a model_list entry, creating it on first
+// use. Providers are shared by all sessions using the same entry.
+func (al *AgentLoop) providerFor(mc *config.ModelConfig) (providers.LLMProvider, string, error) {
+	key := mc.ModelName + "\x00" + mc.Model + "\x00" + mc.APIBase
+	if v, ok := al.modelProviders.Load(key); ok {
+		cached := v.(cachedProvider)
+		return cached.provider, cached.modelID, nil
+	}
+
+	newProvider := al.newProvider
+	if newProvider == nil {
+		newProvider = providers.CreateProviderFromConfig
+	}
+	provider, modelID, err := newProvider(mc)
+	if err != nil {
+		return nil, "", err
+	}
+	v, _ := al.modelProviders.LoadOrStore(key, cachedProvider{provider: provider, modelID: modelID})
+	cached := v.(cachedProvider)
+	return cached.provider, cached.modelID, nil
+}
+
+type cachedProvider struct {
+	provider providers.LLMProvider
+	modelID  string
+}
+
+// modelNames lists the distinct model_list names in config order.
+func (al *AgentLoop) modelNames() []string {
+	var names []string
+	for i := range al.cfg.ModelList {
+		if name := al.cfg.ModelList[i].ModelName; name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// switchSessionModel sets the model override of msg's session; "default"
+// clears it.
+func (al *AgentLoop) switchSessionModel(msg bus.InboundMessage, value string) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No default agent configured"
+	}
+
+	oldModel := al.currentModelName(agent, sessionKey)
+
+	if value == "default" {
+		agent.Sessions.SetModel(sessionKey, "")
+	} else {
+		if _, err := al.resolveModel(agent, value); err != nil {
+			available := al.modelNames()
+			if len(available) == 0 {
+				return fmt.Sprintf("Cannot switch to %s: %v", value, err)
+			}
+			return fmt.Sprintf("Cannot switch to %s: %v\nAvailable models: %s",
+				value, err, strings.Join(available, ", "))
+		}
+		agent.Sessions.SetModel(sessionKey, value)
+	}
+
+	if err := agent.Sessions.Save(sessionKey); err != nil {
+		logger.WarnCF("agent", "Failed to save session model override",
+			map[string]any{"session_key": sessionKey, "error": err.Error()})
+	}
+
+	return fmt.Sprintf("Switched model for this conversation from %s to %s",
+		oldModel, al.currentModelName(agent, sessionKey))
+}
+
+// currentModelName describes the model msg's session uses.
+func (al *AgentLoop) currentModelName(agent *AgentInstance, sessionKey string) string {
+	if name := agent.Sessions.GetModel(sessionKey); name != "" {
+		return name
+	}
+	if rc := al.cfg.Agents.Defaults.Routing; rc != nil && rc.Enabled {
+		desc := agent.Model + " (default, routed per turn)"
+		if v, ok := al.lastRoute.Load(routeKey(agent, sessionKey)); ok {
+			d := v.(routeDecision)
+			model := d.Model
+			if model == "" {
+				model = agent.Model
+			}
+			desc += fmt.Sprintf("; last turn: %s tier on %s (%s)", d.Tier, model, d.Reason)
+		}
+		return desc
+	}
+	return agent.Model + " (default)"
+}
@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// historyPageSize is the number of messages shown per /history page.
+const historyPageSize = 10
+
+// sessionFor resolves the agent and session key a message belongs to, the
+// same way processMessage does.
+func (al *AgentLoop) sessionFor(msg bus.InboundMessage) (*AgentInstance, string) {
+	route := al.registry.ResolveRoute(routeInputFor(msg))
+	agent, ok := al.registry.GetAgent(route.AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+
+	sessionKey := route.SessionKey
+	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
+		sessionKey = msg.SessionKey
+	}
+	return agent, sessionKey
+}
+
+// personFor returns the profile key of the person msg comes from: the
+// identity_links name when the sender is linked, so one person shares a
+// profile across channels, and the canonical sender ID otherwise. Internal
+// channels have no person.
+func (al *AgentLoop) personFor(msg bus.InboundMessage) string {
+	if constants.IsInternalChannel(msg.Channel) || msg.SenderID == "" {
+		return ""
+	}
+	id := msg.Sender.CanonicalID
+	if id == "" {
+		id = msg.Channel + ":" + msg.SenderID
+	}
+	if linked := routing.ResolveLinkedIdentity(al.cfg.Session.IdentityLinks, msg.Channel, id); linked != "" {
+		return linked
+	}
+	return id
+}
+
+// newSession archives the conversation of msg's session and starts a fresh
+// one. With archive false the old conversation is discarded.
+func (al *AgentLoop) newSession(msg bus.InboundMessage, archive bool) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+	al.forgetRoute(agent, sessionKey)
+
+	if !archive {
+		agent.Sessions.Reset(sessionKey)
+		if err := agent.Sessions.Save(sessionKey); err != nil {
+			return fmt.Sprintf("Failed to reset conversation: %v", err)
+		}
+		logger.InfoCF("agent", "Session reset", map[string]any{"session_key": sessionKey})
+		return "Conversation cleared. Starting fresh."
+	}
+
+	path, err := agent.Sessions.Archive(sessionKey)
+	if err != nil {
+		return fmt.Sprintf("Failed to archive conversation: %v", err)
+	}
+	logger.InfoCF("agent", "Session archived",
+		map[string]any{
+			"session_key": sessionKey,
+			"archive":     path,
+		})
+	if path == "" {
+		return "Started a new conversation."
+	}
+	return "Previous conversation archived. Started a new conversation."
+}
+
+// undoLastExchange drops the last user message of msg's session together with
+// the replies and tool calls that followed it.
+func (al *AgentLoop) undoLastExchange(msg bus.InboundMessage) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+
+	removed := agent.Sessions.DropLastExchange(sessionKey)
+	if removed == 0 {
+		return "Nothing to undo."
+	}
+	if err := agent.Sessions.Save(sessionKey); err != nil {
+		return fmt.Sprintf("Failed to save conversation: %v", err)
+	}
+	return fmt.Sprintf("Removed the last exchange (%d messages).", removed)
+}
+
+// sessionHistory renders one page of the conversation, page 1 being the most
+// recent messages.
+func (al *AgentLoop) sessionHistory(msg bus.InboundMessage, args []string) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+
+	page := 1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return "Usage: /history [page]"
+		}
+		page = n
+	}
+
+	var entries []string
+	for _, m := range agent.Sessions.GetHistory(sessionKey) {
+		if line := historyLine(m); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if len(entries) == 0 {
+		return "No messages in this conversation."
+	}
+
+	pages := (len(entries) + historyPageSize - 1) / historyPageSize
+	if page > pages {
+		return fmt.Sprintf("Page %d does not exist; the conversation has %d page(s).", page, pages)
+	}
+	end := len(entries) - (page-1)*historyPageSize
+	start := max(end-historyPageSize, 0)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "History page %d/%d (%d messages)\n", page, pages, len(entries))
+	for i := start; i < end; i++ {
+		fmt.Fprintf(&sb, "\n%d. %s", i+1, entries[i])
+	}
+	if summary := agent.Sessions.GetSummary(sessionKey); summary != "" && page == pages {
+		fmt.Fprintf(&sb, "\n\nEarlier messages are summarized: %s", utils.Truncate(summary, 300))
+	}
+	if page < pages {
+		fmt.Fprintf(&sb, "\n\nOlder messages: /history %d", page+1)
+	}
+	return sb.String()
+}
+
+func historyLine(m providers.Message) string {
+	switch m.Role {
+	case "user":
+		return "You: " + utils.Truncate(m.Content, 200)
+	case "assistant":
+		if m.Content != "" {
+			return "Assistant: " + utils.Truncate(m.Content, 200)
+		}
+		if len(m.ToolCalls) > 0 {
+			names := make([]string, 0, len(m.ToolCalls))
+			for _, tc := range m.ToolCalls {
+				name := tc.Name
+				if name == "" && tc.Function != nil {
+					name = tc.Function.Name
+				}
+				names = append(names, name)
+			}
+			return "Assistant: [called " + strings.Join(names, ", ") + "]"
+		}
+	}
+	return ""
+}
+
+// compactSession summarizes msg's session now instead of waiting for the
+// history thresholds. If summarization produces nothing, the oldest half of
+// the conversation is dropped instead.
+func (al *AgentLoop) compactSession(msg bus.InboundMessage) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+
+	before := len(agent.Sessions.GetHistory(sessionKey))
+	if before <= 4 {
+		return "Conversation is already compact."
+	}
+
+	summarizeKey := agent.ID + ":" + sessionKey
+	if _, running := al.summarizing.LoadOrStore(summarizeKey, true); running {
+		return "Compaction is already in progress."
+	}
+	defer al.summarizing.Delete(summarizeKey)
+
+	al.summarizeSession(agent, sessionKey)
+	after := len(agent.Sessions.GetHistory(sessionKey))
+	if after >= before {
+		al.forceCompression(agent, sessionKey)
+		after = len(agent.Sessions.GetHistory(sessionKey))
+	}
+
+	if after >= before {
+		return "Could not compact the conversation."
+	}
+	return fmt.Sprintf("Compacted the conversation from %d to %d messages.", before, after)
+}
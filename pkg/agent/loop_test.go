@@ -2,11 +2,13 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,7 +17,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
 )
 
 type fakeChannel struct{ id string }
@@ -900,3 +904,636 @@ func TestResolveMediaRefs_UsesMetaContentType(t *testing.T) {
 		t.Fatalf("expected jpeg prefix, got %q", result[0].Media[0][:30])
 	}
 }
+
+// streamingMockProvider emits its response as deltas via ChatStream.
+type streamingMockProvider struct {
+	deltas     []string
+	chatCalled bool
+}
+
+func (m *streamingMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.chatCalled = true
+	return &providers.LLMResponse{Content: strings.Join(m.deltas, "")}, nil
+}
+
+func (m *streamingMockProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+	onDelta func(delta string),
+) (*providers.LLMResponse, error) {
+	for _, d := range m.deltas {
+		onDelta(d)
+	}
+	return &providers.LLMResponse{Content: strings.Join(m.deltas, "")}, nil
+}
+
+func (m *streamingMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessMessage_StreamsPartialResponse(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Streaming:         true,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	provider := &streamingMockProvider{deltas: []string{"Hello", ", ", "world"}}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	helper := testHelper{al: al}
+
+	response := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Content:  "hi",
+	})
+	if response != "Hello, world" {
+		t.Fatalf("response = %q, want %q", response, "Hello, world")
+	}
+	if provider.chatCalled {
+		t.Fatal("expected ChatStream to be used instead of Chat")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("expected a partial outbound message")
+	}
+	if !out.Partial || out.Channel != "telegram" || out.ChatID != "chat1" || out.Content != "Hello" {
+		t.Fatalf("unexpected partial message: %+v", out)
+	}
+}
+
+func TestProcessMessage_StreamingDisabledUsesChat(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	provider := &streamingMockProvider{deltas: []string{"Hello"}}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	helper := testHelper{al: al}
+
+	response := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Content:  "hi",
+	})
+	if response != "Hello" {
+		t.Fatalf("response = %q, want %q", response, "Hello")
+	}
+	if !provider.chatCalled {
+		t.Fatal("expected Chat to be used when streaming is disabled")
+	}
+}
+
+// blockingMockProvider blocks until the request context is canceled.
+type blockingMockProvider struct {
+	started chan struct{}
+}
+
+func (m *blockingMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	close(m.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *blockingMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestStopCommand_AbortsInFlightTurn(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	provider := &blockingMockProvider{started: make(chan struct{})}
+	al := NewAgentLoop(cfg, msgBus, provider)
+
+	msg := bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Content:  "do something slow",
+		Peer:     bus.Peer{Kind: "direct", ID: "user1"},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		al.handleInbound(context.Background(), msg)
+		close(done)
+	}()
+
+	select {
+	case <-provider.started:
+	case <-time.After(responseTimeout):
+		t.Fatal("LLM call was never started")
+	}
+
+	stopMsg := msg
+	stopMsg.Content = "/stop"
+	if got := al.stopTurn(stopMsg); got != "Stopped the current turn." {
+		t.Fatalf("stopTurn() = %q", got)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(responseTimeout):
+		t.Fatal("turn did not abort after /stop")
+	}
+
+	if got := al.stopTurn(stopMsg); got != "Nothing to stop." {
+		t.Fatalf("stopTurn() after abort = %q, want %q", got, "Nothing to stop.")
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	sessionKey := al.registry.ResolveRoute(routeInputFor(msg)).SessionKey
+	history := agent.Sessions.GetHistory(sessionKey)
+	if len(history) != 2 {
+		t.Fatalf("history length = %d, want 2 (user + abort note)", len(history))
+	}
+	if last := history[len(history)-1]; last.Role != "assistant" || last.Content != turnAbortedNote {
+		t.Fatalf("last history entry = %+v, want abort note", last)
+	}
+
+	// An aborted turn publishes no error reply; only /stop answers.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if out, ok := msgBus.SubscribeOutbound(ctx); ok {
+		t.Fatalf("unexpected outbound message after abort: %+v", out)
+	}
+}
+
+type usageMockProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *usageMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+	return &providers.LLMResponse{
+		Content: "ok",
+		Usage:   &providers.UsageInfo{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100},
+	}, nil
+}
+
+func (m *usageMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessMessage_BudgetRefusesOverLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Budget:            &config.BudgetConfig{DailyTokens: 150},
+			},
+		},
+	}
+
+	provider := &usageMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	helper := testHelper{al: al}
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1", Content: "hi"}
+
+	for i, want := range []string{"ok", "ok", budgetRefusal("daily token")} {
+		if got := helper.executeAndGetResponse(t, context.Background(), msg); got != want {
+			t.Fatalf("turn %d: response = %q, want %q", i+1, got, want)
+		}
+	}
+	if provider.calls != 2 {
+		t.Fatalf("provider calls = %d, want 2", provider.calls)
+	}
+
+	// Stopping the loop saves the ledger.
+	al.Stop()
+	records, err := usage.Load(usage.DefaultPath(tmpDir))
+	if err != nil {
+		t.Fatalf("usage.Load() error: %v", err)
+	}
+	totals := usage.Sum(records, usage.Filter{AgentID: "main"})
+	if totals.Requests != 2 || totals.TotalTokens != 200 {
+		t.Fatalf("recorded totals = %+v, want 2 requests / 200 tokens", totals)
+	}
+
+	report := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel: "telegram", SenderID: "user1", ChatID: "chat1", Content: "/usage",
+	})
+	if !strings.Contains(report, "Daily token budget: 200 / 150") {
+		t.Fatalf("unexpected /usage report: %q", report)
+	}
+}
+
+func TestProcessMessage_BudgetDowngradesSessionOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				ModelFallbacks:    []string{"pricey", "cheap"},
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Budget:            &config.BudgetConfig{DailyTokens: 150, Action: "downgrade"},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "fast", Model: "openai/fast-model", APIKey: "test-key"},
+			{ModelName: "pricey", Model: "openai/pricey-model", APIKey: "test-key", InputPrice: 5, OutputPrice: 15},
+			{ModelName: "cheap", Model: "openai/cheap-model", APIKey: "test-key", InputPrice: 0.1, OutputPrice: 0.4},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &usageMockProvider{})
+	entryProviders := map[string]*modelRecordingProvider{}
+	al.newProvider = func(mc *config.ModelConfig) (providers.LLMProvider, string, error) {
+		p := &modelRecordingProvider{
+			usage: &providers.UsageInfo{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100},
+		}
+		entryProviders[mc.ModelName] = p
+		_, modelID := providers.ExtractProtocol(mc.Model)
+		return p, modelID, nil
+	}
+	helper := testHelper{al: al}
+	send := func(content string) string {
+		return helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+			Channel: "telegram", SenderID: "user1", ChatID: "chat1", Content: content,
+		})
+	}
+
+	if resp := send("/switch model to fast"); !strings.Contains(resp, "to fast") {
+		t.Fatalf("unexpected /switch response: %q", resp)
+	}
+	for i, want := range []string{"reply from fast-model", "reply from fast-model", "reply from cheap-model"} {
+		if got := send("hello"); got != want {
+			t.Fatalf("turn %d: response = %q, want %q", i+1, got, want)
+		}
+	}
+	if got := entryProviders["cheap"].models; len(got) != 1 || got[0] != "cheap-model" {
+		t.Fatalf("cheap provider calls = %v, want one call for cheap-model", got)
+	}
+	if got := entryProviders["fast"].models; len(got) != 2 {
+		t.Fatalf("fast provider calls = %v, want 2", got)
+	}
+}
+
+func TestApprovalBroker_ApproveFromRequester(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	broker := newApprovalBroker(msgBus, nil)
+
+	type outcome struct {
+		approved  bool
+		decidedBy string
+		err       error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		approved, decidedBy, err := broker.RequestApproval(context.Background(), tools.ApprovalRequest{
+			Tool:        "exec",
+			Args:        map[string]any{"command": "rm -rf build"},
+			Channel:     "telegram",
+			ChatID:      "chat1",
+			RequestedBy: "user1",
+			Timeout:     time.Second,
+		})
+		done <- outcome{approved, decidedBy, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	prompt, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || !strings.Contains(prompt.Content, "rm -rf build") {
+		t.Fatalf("expected approval prompt, got %+v", prompt)
+	}
+
+	if broker.resolve(bus.InboundMessage{Channel: "telegram", ChatID: "chat1", SenderID: "user2", Content: "yes"}) {
+		t.Fatal("reply from another sender should not resolve the approval")
+	}
+	if broker.resolve(bus.InboundMessage{Channel: "telegram", ChatID: "chat1", SenderID: "user1", Content: "hello"}) {
+		t.Fatal("non-approval message should not resolve the approval")
+	}
+	if !broker.resolve(bus.InboundMessage{Channel: "telegram", ChatID: "chat1", SenderID: "user1", Content: "/approve"}) {
+		t.Fatal("expected /approve to resolve the approval")
+	}
+
+	got := <-done
+	if got.err != nil || !got.approved || got.decidedBy != "user1" {
+		t.Fatalf("RequestApproval() = %+v, want approved by user1", got)
+	}
+}
+
+func TestApprovalBroker_TimeoutAndInternalChannel(t *testing.T) {
+	broker := newApprovalBroker(bus.NewMessageBus(), nil)
+
+	_, _, err := broker.RequestApproval(context.Background(), tools.ApprovalRequest{
+		Tool: "exec", Channel: "telegram", ChatID: "chat1", Timeout: 20 * time.Millisecond,
+	})
+	if !errors.Is(err, tools.ErrApprovalTimeout) {
+		t.Fatalf("expected ErrApprovalTimeout, got %v", err)
+	}
+
+	_, _, err = broker.RequestApproval(context.Background(), tools.ApprovalRequest{
+		Tool: "exec", Channel: "cli", ChatID: "direct", Timeout: time.Second,
+	})
+	if !errors.Is(err, tools.ErrNoApprover) {
+		t.Fatalf("expected ErrNoApprover, got %v", err)
+	}
+}
+
+func TestAgentLoop_InvalidApprovalConfigRefusesToRun(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	cfg.Tools.Approval = config.ApprovalConfig{
+		Enabled: true,
+		Rules:   []config.ApprovalRule{{Tool: "exec", Arg: "command", Match: "("}},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	defer al.Stop()
+	if err := al.Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail with an invalid approval config")
+	}
+	if _, err := al.ProcessDirect(context.Background(), "hello", "test-session"); err == nil {
+		t.Fatal("ProcessDirect() should fail with an invalid approval config")
+	}
+}
+
+func TestAgentLoop_StopWaitsForRun(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		al.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !al.running.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	al.Stop()
+	select {
+	case <-done:
+	default:
+		t.Fatal("Stop() returned before Run")
+	}
+
+	// Nothing starts once the loop has stopped.
+	if err := al.Run(context.Background()); err != nil {
+		t.Fatalf("Run() after Stop() = %v", err)
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	helper := testHelper{al: al}
+
+	send := func(content string) string {
+		return helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "user1",
+			ChatID:   "chat1",
+			Content:  content,
+		})
+	}
+
+	send("first question")
+	send("second question")
+
+	agent, sessionKey := al.sessionFor(bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"})
+	if got := len(agent.Sessions.GetHistory(sessionKey)); got != 4 {
+		t.Fatalf("history = %d messages, want 4", got)
+	}
+
+	if resp := send("/history"); !strings.Contains(resp, "You: second question") {
+		t.Errorf("unexpected /history response: %q", resp)
+	}
+
+	if resp := send("/undo"); resp != "Removed the last exchange (2 messages)." {
+		t.Errorf("unexpected /undo response: %q", resp)
+	}
+	history := agent.Sessions.GetHistory(sessionKey)
+	if len(history) != 2 || history[0].Content != "first question" {
+		t.Fatalf("unexpected history after /undo: %+v", history)
+	}
+
+	if resp := send("/new"); !strings.Contains(resp, "archived") {
+		t.Errorf("unexpected /new response: %q", resp)
+	}
+	if got := len(agent.Sessions.GetHistory(sessionKey)); got != 0 {
+		t.Errorf("history after /new = %d messages, want 0", got)
+	}
+	if resp := send("/undo"); resp != "Nothing to undo." {
+		t.Errorf("unexpected /undo response on empty session: %q", resp)
+	}
+}
+
+type modelRecordingProvider struct {
+	mu     sync.Mutex
+	models []string
+	usage  *providers.UsageInfo
+}
+
+func (m *modelRecordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.mu.Lock()
+	m.models = append(m.models, model)
+	m.mu.Unlock()
+	return &providers.LLMResponse{Content: "reply from " + model, Usage: m.usage}, nil
+}
+
+func (m *modelRecordingProvider) GetDefaultModel() string {
+	return "recording-model"
+}
+
+func TestSwitchModel_ScopedToSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "fast", Model: "openai/fast-model", APIKey: "test-key"},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "default reply"})
+	override := &modelRecordingProvider{}
+	var created []string
+	al.newProvider = func(mc *config.ModelConfig) (providers.LLMProvider, string, error) {
+		created = append(created, mc.ModelName)
+		_, modelID := providers.ExtractProtocol(mc.Model)
+		return override, modelID, nil
+	}
+	helper := testHelper{al: al}
+
+	send := func(chatID, content string) string {
+		return helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "user-" + chatID,
+			ChatID:   chatID,
+			Peer:     bus.Peer{Kind: "group", ID: chatID},
+			Content:  content,
+		})
+	}
+
+	if resp := send("chat1", "/switch model to missing"); !strings.Contains(resp, "Available models: fast") {
+		t.Fatalf("expected validation error, got %q", resp)
+	}
+	if resp := send("chat1", "/switch model to fast"); !strings.Contains(resp, "to fast") {
+		t.Fatalf("unexpected /switch response: %q", resp)
+	}
+
+	if resp := send("chat1", "hello"); resp != "reply from fast-model" {
+		t.Errorf("chat1 response = %q, want reply from the override", resp)
+	}
+	if resp := send("chat2", "hello"); resp != "default reply" {
+		t.Errorf("chat2 response = %q, want the default model's reply", resp)
+	}
+	if resp := send("chat1", "again"); resp != "reply from fast-model" {
+		t.Errorf("chat1 response = %q, want reply from the override", resp)
+	}
+	if len(created) != 1 {
+		t.Errorf("providers created = %v, want one cached provider", created)
+	}
+
+	agent, sessionKey := al.sessionFor(bus.InboundMessage{
+		Channel: "telegram", SenderID: "user-chat1", ChatID: "chat1", Peer: bus.Peer{Kind: "group", ID: "chat1"},
+	})
+	reopened, err := session.OpenStoreBackend(filepath.Join(agent.Workspace, "sessions"), "")
+	if err != nil {
+		t.Fatalf("OpenStoreBackend() error = %v", err)
+	}
+	if got := reopened.GetModel(sessionKey); got != "fast" {
+		t.Errorf("persisted model override = %q, want %q", got, "fast")
+	}
+
+	send("chat1", "/switch model to default")
+	if resp := send("chat1", "hello"); resp != "default reply" {
+		t.Errorf("response after reset = %q, want the default model's reply", resp)
+	}
+}
+
+func TestPersonFor(t *testing.T) {
+	al, cfg, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	cfg.Session.IdentityLinks = map[string][]string{
+		"alice": {"telegram:111", "discord:222"},
+	}
+
+	tests := []struct {
+		name string
+		msg  bus.InboundMessage
+		want string
+	}{
+		{
+			name: "linked telegram",
+			msg: bus.InboundMessage{
+				Channel: "telegram", SenderID: "111",
+				Sender: bus.SenderInfo{Platform: "telegram", PlatformID: "111", CanonicalID: "telegram:111"},
+			},
+			want: "alice",
+		},
+		{
+			name: "linked discord without canonical id",
+			msg:  bus.InboundMessage{Channel: "discord", SenderID: "222"},
+			want: "alice",
+		},
+		{
+			name: "unlinked",
+			msg: bus.InboundMessage{
+				Channel: "telegram", SenderID: "333",
+				Sender: bus.SenderInfo{Platform: "telegram", PlatformID: "333", CanonicalID: "telegram:333"},
+			},
+			want: "telegram:333",
+		},
+		{
+			name: "internal channel",
+			msg:  bus.InboundMessage{Channel: "cli", SenderID: "user"},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := al.personFor(tt.msg); got != tt.want {
+				t.Errorf("personFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
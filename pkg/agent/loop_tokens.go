@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// messageOverheadTokens is what the chat format adds to each message for
+// its role and delimiters.
+const messageOverheadTokens = 4
+
+// tokenizerDir is where BPE encoding files are looked up:
+// ~/.picoclaw/tokenizers, or $PICOCLAW_HOME/tokenizers.
+func tokenizerDir() string {
+	home := getGlobalConfigDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, "tokenizers")
+}
+
+// countTokens counts the tokens of a request to model: the messages, their
+// tool calls and the tool definitions.
+func (al *AgentLoop) countTokens(
+	model string,
+	messages []providers.Message,
+	toolDefs []providers.ToolDefinition,
+) int {
+	tok := al.tokenizers.ForModel(model)
+	total := 0
+	for _, m := range messages {
+		total += messageTokens(tok.Count, m)
+	}
+	if len(toolDefs) > 0 {
+		if data, err := json.Marshal(toolDefs); err == nil {
+			total += tok.Count(string(data))
+		}
+	}
+	return total
+}
+
+func messageTokens(count func(string) int, m providers.Message) int {
+	n := messageOverheadTokens + count(m.Content)
+	for _, tc := range m.ToolCalls {
+		if tc.Function != nil {
+			n += count(tc.Function.Name) + count(tc.Function.Arguments)
+		} else if args, err := json.Marshal(tc.Arguments); err == nil {
+			n += count(tc.Name) + count(string(args))
+		}
+	}
+	return n
+}
+
+// fitToContext drops the oldest history from a request until it fits the
+// agent's context window, leaving room for the reply. History is dropped
+// in whole exchanges, from one user message to the next, so tool results
+// never lose their calls; the system prompt and the current turn are always
+// kept. It only applies when context_window is configured, and returns
+// messages unchanged otherwise or when nothing can be dropped.
+func (al *AgentLoop) fitToContext(
+	agent *AgentInstance,
+	model string,
+	messages []providers.Message,
+	toolDefs []providers.ToolDefinition,
+) []providers.Message {
+	budget := agent.ContextWindow - agent.MaxTokens
+	if budget <= 0 || len(messages) < 3 {
+		return messages
+	}
+	total := al.countTokens(model, messages, toolDefs)
+	if total <= budget {
+		return messages
+	}
+
+	// The current turn starts at the last user message.
+	current := len(messages) - 1
+	for current > 1 && messages[current].Role != "user" {
+		current--
+	}
+
+	tok := al.tokenizers.ForModel(model)
+	cut := 1
+	for total > budget {
+		next := cut + 1
+		for next < current && messages[next].Role != "user" {
+			next++
+		}
+		if next > current {
+			break
+		}
+		for _, m := range messages[cut:next] {
+			total -= messageTokens(tok.Count, m)
+		}
+		cut = next
+		if cut == current {
+			break
+		}
+	}
+	if cut == 1 {
+		return messages
+	}
+
+	logger.InfoCF("agent", "Trimmed history to fit the context window",
+		map[string]any{
+			"agent_id":       agent.ID,
+			"model":          model,
+			"dropped_msgs":   cut - 1,
+			"prompt_tokens":  total,
+			"context_window": agent.ContextWindow,
+		})
+	trimmed := make([]providers.Message, 0, len(messages)-cut+1)
+	trimmed = append(trimmed, messages[0])
+	return append(trimmed, messages[cut:]...)
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestFitToContext(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	agent := al.registry.GetDefaultAgent()
+
+	long := strings.Repeat("lorem ipsum dolor sit amet ", 40)
+	messages := []providers.Message{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: long},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{
+			{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "notes.txt"}},
+		}},
+		{Role: "tool", ToolCallID: "call_1", Content: long},
+		{Role: "assistant", Content: "Done."},
+		{Role: "user", Content: "and then?"},
+		{Role: "assistant", Content: "Then we stopped."},
+		{Role: "user", Content: "what now?"},
+	}
+
+	if got := al.fitToContext(agent, agent.Model, messages, nil); len(got) != len(messages) {
+		t.Fatalf("fitToContext() without context_window dropped %d messages", len(messages)-len(got))
+	}
+
+	agent.ContextWindow = agent.MaxTokens + 100
+	got := al.fitToContext(agent, agent.Model, messages, nil)
+	if len(got) != 4 || got[0].Role != "system" || got[1].Content != "and then?" || got[3].Content != "what now?" {
+		t.Fatalf("fitToContext() = %+v, want the system prompt and the last two exchanges", got)
+	}
+	if tokens := al.countTokens(agent.Model, got, nil); tokens > 100 {
+		t.Errorf("trimmed request has %d tokens, want at most 100", tokens)
+	}
+
+	// The current turn is never dropped, even if it alone is too large.
+	agent.ContextWindow = agent.MaxTokens + 10
+	got = al.fitToContext(agent, agent.Model, messages, nil)
+	if len(got) != 2 || got[1].Content != "what now?" {
+		t.Errorf("fitToContext() = %+v, want the system prompt and the current turn", got)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+
+	msgs := []providers.Message{{Role: "user", Content: "hello there"}}
+	base := al.countTokens("claude-sonnet-4", msgs, nil)
+	if base <= messageOverheadTokens {
+		t.Fatalf("countTokens() = %d, want more than the message overhead", base)
+	}
+
+	withTools := al.countTokens("claude-sonnet-4", msgs, []providers.ToolDefinition{{
+		Type: "function",
+		Function: providers.ToolFunctionDefinition{
+			Name:        "read_file",
+			Description: "Read a file from the workspace",
+			Parameters:  map[string]any{"type": "object"},
+		},
+	}})
+	if withTools <= base {
+		t.Errorf("countTokens() with tools = %d, want more than %d", withTools, base)
+	}
+
+	cjk := []providers.Message{{Role: "user", Content: "今天天气很好，我们去公园散步吧。"}}
+	if got := al.countTokens("claude-sonnet-4", cjk, nil); got < 16 {
+		t.Errorf("countTokens() for CJK = %d, want at least a token per character", got)
+	}
+}
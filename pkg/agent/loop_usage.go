@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// modelPrice returns the configured price (USD per million input/output
+// tokens) of a model, looked up by model_name alias or model identifier.
+func (al *AgentLoop) modelPrice(model string) (input, output float64, ok bool) {
+	for i := range al.cfg.ModelList {
+		mc := &al.cfg.ModelList[i]
+		_, modelID := providers.ExtractProtocol(mc.Model)
+		if mc.ModelName == model || mc.Model == model || modelID == model {
+			if mc.InputPrice == 0 && mc.OutputPrice == 0 {
+				return 0, 0, false
+			}
+			return mc.InputPrice, mc.OutputPrice, true
+		}
+	}
+	return 0, 0, false
+}
+
+// recordUsage adds the token usage of one LLM call to the usage ledger.
+func (al *AgentLoop) recordUsage(agent *AgentInstance, sessionKey, model string, u *providers.UsageInfo) {
+	if al.usage == nil || u == nil {
+		return
+	}
+
+	var cost float64
+	if input, output, ok := al.modelPrice(model); ok {
+		cost = (float64(u.PromptTokens)*input + float64(u.CompletionTokens)*output) / 1e6
+	}
+
+	al.usage.Record(
+		agent.ID, sessionKey, model,
+		u.PromptTokens, u.CompletionTokens, u.TotalTokens,
+		cost,
+	)
+}
+
+// budgetExceeded returns the name of the first budget limit the agent has
+// reached, or "" if it is within budget.
+func (al *AgentLoop) budgetExceeded(agent *AgentInstance) string {
+	b := agent.Budget
+	if b == nil || al.usage == nil {
+		return ""
+	}
+
+	if b.DailyTokens > 0 || b.DailyCost > 0 {
+		today := al.usage.Sum(usage.Filter{AgentID: agent.ID, DayPrefix: al.usage.Today()})
+		if b.DailyTokens > 0 && today.TotalTokens >= b.DailyTokens {
+			return "daily token"
+		}
+		if b.DailyCost > 0 && today.Cost >= b.DailyCost {
+			return "daily cost"
+		}
+	}
+	if b.MonthlyTokens > 0 || b.MonthlyCost > 0 {
+		month := al.usage.Sum(usage.Filter{AgentID: agent.ID, DayPrefix: al.usage.ThisMonth()})
+		if b.MonthlyTokens > 0 && month.TotalTokens >= b.MonthlyTokens {
+			return "monthly token"
+		}
+		if b.MonthlyCost > 0 && month.Cost >= b.MonthlyCost {
+			return "monthly cost"
+		}
+	}
+	return ""
+}
+
+// downgradeCandidate picks the model to use once the agent is over budget
+// with the "downgrade" action: the cheapest priced fallback of the turn's
+// model, or its last fallback when none are priced. The turn's model may be
+// a session override or a routed tier, so the choice starts from its
+// candidates rather than the agent's. A fallback listed in model_list runs
+// on that entry's provider. It reports false if the model has no fallbacks
+// or downgrading is not configured.
+func (al *AgentLoop) downgradeCandidate(agent *AgentInstance, model modelSelection) (modelSelection, bool) {
+	if agent.Budget == nil || agent.Budget.Action != "downgrade" || len(model.Candidates) < 2 {
+		return modelSelection{}, false
+	}
+
+	fallbacks := model.Candidates[1:]
+	best := fallbacks[len(fallbacks)-1]
+	bestPrice := -1.0
+	for _, c := range fallbacks {
+		input, output, ok := al.modelPrice(c.Model)
+		if !ok {
+			continue
+		}
+		if price := input + output; bestPrice < 0 || price < bestPrice {
+			best, bestPrice = c, price
+		}
+	}
+
+	sel := modelSelection{Model: best.Model, Provider: model.Provider, ThinkingLevel: model.ThinkingLevel}
+	if mc := al.modelConfigFor(best); mc != nil {
+		if provider, modelID, err := al.providerFor(mc); err == nil {
+			sel.Name, sel.Model, sel.Provider = mc.ModelName, modelID, provider
+			sel.ThinkingLevel = parseThinkingLevel(mc.ThinkingLevel)
+		}
+	}
+	sel.Candidates = []providers.FallbackCandidate{best}
+	return sel, true
+}
+
+// modelConfigFor returns the model_list entry of a fallback candidate, or
+// nil if none serves it.
+func (al *AgentLoop) modelConfigFor(c providers.FallbackCandidate) *config.ModelConfig {
+	for i := range al.cfg.ModelList {
+		mc := &al.cfg.ModelList[i]
+		if ref := providers.ParseModelRef(mc.Model, "openai"); ref != nil && ref.Provider == c.Provider && ref.Model == c.Model {
+			return mc
+		}
+	}
+	return nil
+}
+
+// budgetRefusal is the reply given instead of calling the LLM once the
+// agent's budget is exhausted.
+func budgetRefusal(limit string) string {
+	return fmt.Sprintf(
+		"The %s budget for this agent has been reached. Please try again later or raise the limit in config.json.",
+		limit,
+	)
+}
+
+// usageReport renders the /usage reply for the agent and session msg routes to.
+func (al *AgentLoop) usageReport(msg bus.InboundMessage) string {
+	if al.usage == nil {
+		return "Usage tracking is not available"
+	}
+
+	route := al.registry.ResolveRoute(routeInputFor(msg))
+	agent, ok := al.registry.GetAgent(route.AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+	if agent == nil {
+		return "No agent available"
+	}
+
+	today := al.usage.Sum(usage.Filter{AgentID: agent.ID, DayPrefix: al.usage.Today()})
+	month := al.usage.Sum(usage.Filter{AgentID: agent.ID, DayPrefix: al.usage.ThisMonth()})
+	session := al.usage.Sum(usage.Filter{AgentID: agent.ID, SessionKey: route.SessionKey})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Usage for agent %s\n", agent.ID)
+	fmt.Fprintf(&sb, "Today: %s\n", formatTotals(today))
+	fmt.Fprintf(&sb, "This month: %s\n", formatTotals(month))
+	fmt.Fprintf(&sb, "This session: %s", formatTotals(session))
+
+	if b := agent.Budget; b != nil {
+		if b.DailyTokens > 0 {
+			fmt.Fprintf(&sb, "\nDaily token budget: %d / %d", today.TotalTokens, b.DailyTokens)
+		}
+		if b.MonthlyTokens > 0 {
+			fmt.Fprintf(&sb, "\nMonthly token budget: %d / %d", month.TotalTokens, b.MonthlyTokens)
+		}
+		if b.DailyCost > 0 {
+			fmt.Fprintf(&sb, "\nDaily cost budget: $%.4f / $%.2f", today.Cost, b.DailyCost)
+		}
+		if b.MonthlyCost > 0 {
+			fmt.Fprintf(&sb, "\nMonthly cost budget: $%.4f / $%.2f", month.Cost, b.MonthlyCost)
+		}
+	}
+
+	return sb.String()
+}
+
+func formatTotals(t usage.Totals) string {
+	s := fmt.Sprintf("%d tokens (%d in / %d out) in %d requests",
+		t.TotalTokens, t.PromptTokens, t.CompletionTokens, t.Requests)
+	if t.Cost > 0 {
+		s += fmt.Sprintf(", $%.4f", t.Cost)
+	}
+	return s
+}
@@ -11,18 +11,27 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/memory"
 )
 
 // MemoryStore manages persistent memory for the agent.
 // - Long-term memory: memory/MEMORY.md
 // - Daily notes: memory/YYYYMM/YYYYMMDD.md
+// - Person profiles: memory/people/<person>.md
+// - Conversation notes: memory/conversations/<session>.md
+//
+// Conversation notes are never put in the prompt; they only reach the agent
+// through memory_search, in the scope of the session they summarize.
 type MemoryStore struct {
 	workspace  string
 	memoryDir  string
 	memoryFile string
+
+	mu sync.Mutex // serializes appends from concurrent sessions
 }
 
 // NewMemoryStore creates a new MemoryStore with the given workspace path.
@@ -65,6 +74,67 @@ func (ms *MemoryStore) WriteLongTerm(content string) error {
 	return fileutil.WriteFileAtomic(ms.memoryFile, []byte(content), 0o600)
 }
 
+// AppendLongTerm appends content to the long-term memory file as a new
+// paragraph.
+func (ms *MemoryStore) AppendLongTerm(content string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	existing := strings.TrimRight(ms.ReadLongTerm(), "\n")
+	if existing != "" {
+		content = existing + "\n\n" + content
+	}
+	return ms.WriteLongTerm(content + "\n")
+}
+
+// profilePath returns the profile file of a person. Characters outside
+// [a-z0-9._-] are replaced so any canonical ID maps to a plain file name.
+func (ms *MemoryStore) profilePath(personID string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, personID)
+	name = strings.TrimLeft(name, ".")
+	return filepath.Join(ms.memoryDir, "people", name+".md")
+}
+
+// ReadProfile reads what is remembered about a person.
+// Returns empty string if there is no profile.
+func (ms *MemoryStore) ReadProfile(personID string) string {
+	if personID == "" {
+		return ""
+	}
+	if data, err := os.ReadFile(ms.profilePath(personID)); err == nil {
+		return string(data)
+	}
+	return ""
+}
+
+// AppendProfile appends content to a person's profile.
+func (ms *MemoryStore) AppendProfile(personID, content string) error {
+	if personID == "" {
+		return fmt.Errorf("no person to remember this for")
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	path := ms.profilePath(personID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	existing := strings.TrimRight(ms.ReadProfile(personID), "\n")
+	if existing == "" {
+		existing = "# " + personID
+	}
+	return fileutil.WriteFileAtomic(path, []byte(existing+"\n\n"+content+"\n"), 0o600)
+}
+
 // ReadToday reads today's daily note.
 // Returns empty string if the file doesn't exist.
 func (ms *MemoryStore) ReadToday() string {
@@ -78,16 +148,29 @@ func (ms *MemoryStore) ReadToday() string {
 // AppendToday appends content to today's daily note.
 // If the file doesn't exist, it creates a new file with a date header.
 func (ms *MemoryStore) AppendToday(content string) error {
-	todayFile := ms.getTodayFile()
+	return ms.appendDated(ms.getTodayFile(), content)
+}
+
+// AppendConversationNote appends content to the note of a session, which
+// memory_search recalls only where it would recall the session itself.
+func (ms *MemoryStore) AppendConversationNote(sessionKey, content string) error {
+	return ms.appendDated(memory.ConversationNotePath(ms.memoryDir, sessionKey), content)
+}
+
+// appendDated appends content to a dated note, creating it with a date
+// header.
+func (ms *MemoryStore) appendDated(path, content string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 
 	// Ensure month directory exists
-	monthDir := filepath.Dir(todayFile)
+	monthDir := filepath.Dir(path)
 	if err := os.MkdirAll(monthDir, 0o755); err != nil {
 		return err
 	}
 
 	var existingContent string
-	if data, err := os.ReadFile(todayFile); err == nil {
+	if data, err := os.ReadFile(path); err == nil {
 		existingContent = string(data)
 	}
 
@@ -102,7 +185,7 @@ func (ms *MemoryStore) AppendToday(content string) error {
 	}
 
 	// Use unified atomic write utility with explicit sync for flash storage reliability.
-	return fileutil.WriteFileAtomic(todayFile, []byte(newContent), 0o600)
+	return fileutil.WriteFileAtomic(path, []byte(newContent), 0o600)
 }
 
 // GetRecentDailyNotes returns daily notes from the last N days.
@@ -156,3 +239,38 @@ func (ms *MemoryStore) GetMemoryContext() string {
 
 	return sb.String()
 }
+
+// maxPromptMemoryChars caps the long-term memory copied into the system
+// prompt when memory_search can recall the rest.
+const maxPromptMemoryChars = 4000
+
+// GetRecallContext is GetMemoryContext for agents with memory_search: the
+// start of long-term memory and today's note only, so the prompt stays
+// bounded while older notes are recalled on demand.
+func (ms *MemoryStore) GetRecallContext() string {
+	longTerm := strings.TrimSpace(ms.ReadLongTerm())
+	today := strings.TrimSpace(ms.ReadToday())
+
+	var sb strings.Builder
+	if longTerm != "" {
+		sb.WriteString("## Long-term Memory\n\n")
+		if runes := []rune(longTerm); len(runes) > maxPromptMemoryChars {
+			sb.WriteString(string(runes[:maxPromptMemoryChars]))
+			sb.WriteString("\n\n(truncated; use memory_search to recall the rest)")
+		} else {
+			sb.WriteString(longTerm)
+		}
+	}
+	if today != "" {
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n---\n\n")
+		}
+		sb.WriteString("## Today's Notes\n\n")
+		sb.WriteString(today)
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("Use memory_search to recall older notes and past conversations, and memory_write to save new memories.")
+	return sb.String()
+}
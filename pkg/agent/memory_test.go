@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestMemoryStore_AppendLongTerm(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	if err := ms.AppendLongTerm("likes tea"); err != nil {
+		t.Fatalf("AppendLongTerm() error = %v", err)
+	}
+	if err := ms.AppendLongTerm("lives in Lyon"); err != nil {
+		t.Fatalf("AppendLongTerm() error = %v", err)
+	}
+	if got, want := ms.ReadLongTerm(), "likes tea\n\nlives in Lyon\n"; got != want {
+		t.Errorf("ReadLongTerm() = %q, want %q", got, want)
+	}
+}
+
+func TestMemoryStore_GetRecallContextIsBounded(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	if err := ms.WriteLongTerm(strings.Repeat("fact ", 2000)); err != nil {
+		t.Fatalf("WriteLongTerm() error = %v", err)
+	}
+	if err := ms.AppendToday("met Bob"); err != nil {
+		t.Fatalf("AppendToday() error = %v", err)
+	}
+
+	ctx := ms.GetRecallContext()
+	if len(ctx) > maxPromptMemoryChars+500 {
+		t.Errorf("recall context is %d bytes, want it bounded", len(ctx))
+	}
+	for _, want := range []string{"truncated", "met Bob", "memory_search"} {
+		if !strings.Contains(ctx, want) {
+			t.Errorf("recall context missing %q", want)
+		}
+	}
+}
+
+func TestMemoryStore_ProfilesAreSeparate(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	if err := ms.AppendProfile("telegram:1", "allergic to nuts"); err != nil {
+		t.Fatalf("AppendProfile() error = %v", err)
+	}
+	if err := ms.AppendProfile("alice", "prefers French"); err != nil {
+		t.Fatalf("AppendProfile() error = %v", err)
+	}
+	if err := ms.AppendProfile("", "nobody"); err == nil {
+		t.Error("AppendProfile() with empty person should fail")
+	}
+
+	if got := ms.ReadProfile("telegram:1"); !strings.Contains(got, "allergic to nuts") ||
+		strings.Contains(got, "French") {
+		t.Errorf("ReadProfile(telegram:1) = %q", got)
+	}
+	if got := ms.ReadProfile("../alice"); got != "" {
+		t.Errorf("ReadProfile(../alice) = %q, want empty", got)
+	}
+	if got := ms.ReadLongTerm(); got != "" {
+		t.Errorf("profile leaked into MEMORY.md: %q", got)
+	}
+}
+
+func TestBuildMessages_ProfileOnlyForItsPerson(t *testing.T) {
+	cb := NewContextBuilder(t.TempDir())
+	if err := cb.memory.AppendProfile("alice", "Alice is vegetarian"); err != nil {
+		t.Fatalf("AppendProfile() error = %v", err)
+	}
+
+	system := func(personID string) string {
+		msgs := cb.BuildMessages(nil, "", "what should I cook?", nil, "telegram", "1", personID)
+		return msgs[0].Content
+	}
+	if got := system("alice"); !strings.Contains(got, "Alice is vegetarian") {
+		t.Error("alice's profile missing from her own prompt")
+	}
+	for _, other := range []string{"bob", ""} {
+		if strings.Contains(system(other), "vegetarian") {
+			t.Errorf("alice's profile leaked into the prompt of %q", other)
+		}
+	}
+}
+
+func TestRememberExpiredSession(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	agent := al.registry.GetDefaultAgent()
+
+	info := memory.SessionInfo{
+		Key:       "agent:main:telegram:direct:1",
+		CreatedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+	history := []providers.Message{
+		{Role: "user", Content: "plan my trip"},
+		{Role: "tool", Content: "ignored"},
+		{Role: "assistant", Content: "sure"},
+	}
+	if err := al.rememberExpiredSession(context.Background(), agent, info, history); err != nil {
+		t.Fatalf("rememberExpiredSession() error = %v", err)
+	}
+	ms := agent.ContextBuilder.memory
+	if got := ms.ReadLongTerm(); got != "" {
+		t.Errorf("summary of a private conversation written to MEMORY.md: %q", got)
+	}
+	data, _ := os.ReadFile(memory.ConversationNotePath(ms.memoryDir, info.Key))
+	for _, want := range []string{info.Key, "2026-01-02 to 2026-01-05", "Mock response"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("conversation note = %q, missing %q", data, want)
+		}
+	}
+
+	// A session that belongs to a person goes to their profile.
+	agent.Recall = memory.NewRecall(ms.memoryDir, nil)
+	info.Key = "agent:main:telegram:direct:2"
+	agent.Recall.AddSessionPerson(info.Key, "telegram:2")
+	if err := al.rememberExpiredSession(context.Background(), agent, info, history); err != nil {
+		t.Fatalf("rememberExpiredSession() error = %v", err)
+	}
+	if got := ms.ReadProfile("telegram:2"); !strings.Contains(got, info.Key) {
+		t.Errorf("profile = %q, missing the summary of %s", got, info.Key)
+	}
+	if got := ms.ReadLongTerm(); got != "" {
+		t.Errorf("summary of a private conversation written to MEMORY.md: %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"path/filepath"
 	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -54,6 +55,23 @@ func NewAgentRegistry(
 	return registry
 }
 
+// SessionsDirs returns the sessions directory of every agent
+// NewAgentRegistry creates from cfg, keyed by agent ID, without creating
+// the agents.
+func SessionsDirs(cfg *config.Config) map[string]string {
+	dirs := make(map[string]string)
+	if len(cfg.Agents.List) == 0 {
+		implicitAgent := &config.AgentConfig{ID: "main", Default: true}
+		dirs["main"] = filepath.Join(resolveAgentWorkspace(implicitAgent, &cfg.Agents.Defaults), "sessions")
+		return dirs
+	}
+	for i := range cfg.Agents.List {
+		ac := &cfg.Agents.List[i]
+		dirs[routing.NormalizeAgentID(ac.ID)] = filepath.Join(resolveAgentWorkspace(ac, &cfg.Agents.Defaults), "sessions")
+	}
+	return dirs
+}
+
 // GetAgent returns the agent instance for a given ID.
 func (r *AgentRegistry) GetAgent(agentID string) (*AgentInstance, bool) {
 	r.mu.RLock()
@@ -112,3 +130,22 @@ func (r *AgentRegistry) GetDefaultAgent() *AgentInstance {
 	}
 	return nil
 }
+
+// Close closes the session store of every agent, flushing and releasing
+// the files and databases behind it.
+func (r *AgentRegistry) Close() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for id, agent := range r.agents {
+		if agent.Sessions == nil {
+			continue
+		}
+		if err := agent.Sessions.Close(); err != nil {
+			logger.WarnCF("agent", "Failed to close session store",
+				map[string]any{
+					"agent_id": id,
+					"error":    err.Error(),
+				})
+		}
+	}
+}
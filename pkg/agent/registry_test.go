@@ -2,10 +2,12 @@ package agent
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
 )
 
 type mockRegistryProvider struct{}
@@ -82,6 +84,25 @@ func TestNewAgentRegistry_ExplicitAgents(t *testing.T) {
 	}
 }
 
+func TestSessionsDirs(t *testing.T) {
+	dirs := SessionsDirs(testCfg(nil))
+	if want := filepath.Join("/tmp/picoclaw-test-registry", "sessions"); len(dirs) != 1 || dirs["main"] != want {
+		t.Errorf("implicit main: SessionsDirs() = %v, want main → %s", dirs, want)
+	}
+
+	dirs = SessionsDirs(testCfg([]config.AgentConfig{
+		{ID: "sales", Default: true},
+		{ID: "Support", Workspace: "/srv/support"},
+	}))
+	want := map[string]string{
+		"sales":   filepath.Join("/tmp/picoclaw-test-registry", "sessions"),
+		"support": filepath.Join("/srv/support", "sessions"),
+	}
+	if len(dirs) != len(want) || dirs["sales"] != want["sales"] || dirs["support"] != want["support"] {
+		t.Errorf("SessionsDirs() = %v, want %v", dirs, want)
+	}
+}
+
 func TestAgentRegistry_GetAgent_Normalize(t *testing.T) {
 	cfg := testCfg([]config.AgentConfig{
 		{ID: "my-agent", Default: true},
@@ -111,6 +132,35 @@ func TestAgentRegistry_GetDefaultAgent(t *testing.T) {
 	}
 }
 
+// closeRecorder is a session store that records whether it was closed.
+type closeRecorder struct {
+	session.SessionStore
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestAgentRegistry_Close(t *testing.T) {
+	registry := NewAgentRegistry(testCfg([]config.AgentConfig{{ID: "a"}, {ID: "b"}}), &mockRegistryProvider{})
+	var stores []*closeRecorder
+	for _, id := range registry.ListAgentIDs() {
+		agent, _ := registry.GetAgent(id)
+		store := &closeRecorder{SessionStore: agent.Sessions}
+		agent.Sessions = store
+		stores = append(stores, store)
+	}
+
+	registry.Close()
+	for i, store := range stores {
+		if !store.closed {
+			t.Errorf("session store %d not closed", i)
+		}
+	}
+}
+
 func TestAgentRegistry_CanSpawnSubagent(t *testing.T) {
 	cfg := testCfg([]config.AgentConfig{
 		{
@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// routeTier is the class of model a turn is routed to.
+type routeTier string
+
+const (
+	tierSimple   routeTier = "simple"
+	tierStandard routeTier = "standard"
+	tierComplex  routeTier = "complex"
+)
+
+const defaultMaxSimpleChars = 160
+
+// routeDecision records why a turn was routed to a model, for logs and
+// /show model.
+type routeDecision struct {
+	Tier   routeTier
+	Model  string // model_list name, "" for the agent's model
+	Reason string
+	At     time.Time
+}
+
+var (
+	// complexWords hint at tasks that need a strong model.
+	complexWords = map[string]bool{
+		"analyze": true, "analyse": true, "architecture": true, "algorithm": true,
+		"debug": true, "derive": true, "implement": true, "optimize": true,
+		"prove": true, "refactor": true, "traceback": true, "compile": true,
+	}
+	complexPhrases = []string{"step by step", "stack trace", "write a program", "write code"}
+
+	// toolWords hint at turns that will call tools; small local models are
+	// unreliable at tool calling, so these never go to the simple tier.
+	toolWords = map[string]bool{
+		"search": true, "fetch": true, "download": true, "file": true, "files": true,
+		"run": true, "execute": true, "remind": true, "schedule": true,
+		"install": true, "weather": true, "news": true, "browse": true,
+	}
+	toolPhrases = []string{"http://", "https://", "look up"}
+)
+
+// classifyTurn labels a turn with cheap heuristics on its text and media.
+// ok is false when the heuristics cannot decide.
+func classifyTurn(content string, mediaCount, maxSimpleChars int) (tier routeTier, reason string, ok bool) {
+	if maxSimpleChars <= 0 {
+		maxSimpleChars = defaultMaxSimpleChars
+	}
+	length := utf8.RuneCountInString(strings.TrimSpace(content))
+	lower := strings.ToLower(content)
+
+	if mediaCount > 0 {
+		return tierComplex, "media attached", true
+	}
+	if strings.Contains(content, "```") {
+		return tierComplex, "code block", true
+	}
+	if length > 4*maxSimpleChars {
+		return tierComplex, fmt.Sprintf("long message (%d chars)", length), true
+	}
+
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		if complexWords[w] {
+			return tierComplex, fmt.Sprintf("keyword %q", w), true
+		}
+	}
+	for _, p := range complexPhrases {
+		if strings.Contains(lower, p) {
+			return tierComplex, fmt.Sprintf("keyword %q", p), true
+		}
+	}
+	for _, w := range words {
+		if toolWords[w] {
+			return tierStandard, fmt.Sprintf("likely needs tools (%q)", w), true
+		}
+	}
+	for _, p := range toolPhrases {
+		if strings.Contains(lower, p) {
+			return tierStandard, fmt.Sprintf("likely needs tools (%q)", p), true
+		}
+	}
+
+	if length <= maxSimpleChars && strings.Count(content, "\n") < 2 {
+		return tierSimple, "short message", true
+	}
+	return "", "", false
+}
+
+// routeTurn picks the model tier for a turn. Heuristics decide first; the
+// optional classifier model labels what they cannot, and anything still
+// undecided uses the standard tier. A short follow-up stays on the tier of
+// the previous turn, so "yes, do it" after a hard task is not downgraded.
+func (al *AgentLoop) routeTurn(
+	ctx context.Context,
+	agent *AgentInstance,
+	rc *config.RoutingConfig,
+	opts processOptions,
+) routeDecision {
+	tier, reason, ok := classifyTurn(opts.UserMessage, len(opts.Media), rc.MaxSimpleChars)
+	if !ok && rc.ClassifierModel != "" {
+		if t, err := al.classifyWithModel(ctx, agent, rc.ClassifierModel, opts.UserMessage); err != nil {
+			logger.WarnCF("agent", "Routing classifier failed",
+				map[string]any{"agent_id": agent.ID, "model": rc.ClassifierModel, "error": err.Error()})
+		} else {
+			tier, reason, ok = t, "classifier "+rc.ClassifierModel, true
+		}
+	}
+	if !ok {
+		tier, reason = tierStandard, "undecided"
+	}
+
+	if tier == tierSimple {
+		if v, found := al.lastRoute.Load(routeKey(agent, opts.SessionKey)); found {
+			if prev := v.(routeDecision); prev.Tier != tierSimple && time.Since(prev.At) < 10*time.Minute {
+				tier, reason = prev.Tier, fmt.Sprintf("follow-up to a %s turn", prev.Tier)
+			}
+		}
+	}
+
+	decision := routeDecision{Tier: tier, Reason: reason, At: time.Now()}
+	switch tier {
+	case tierSimple:
+		decision.Model = rc.Simple
+	case tierComplex:
+		decision.Model = rc.Complex
+	}
+	return decision
+}
+
+const classifierPrompt = `You route chat messages to a model. Reply with exactly one word:
+simple - greetings, chit-chat, short factual questions
+standard - everyday requests and tasks that need tools
+complex - coding, analysis, planning or multi-step reasoning`
+
+// classifyWithModel asks a small model to label a turn.
+func (al *AgentLoop) classifyWithModel(
+	ctx context.Context,
+	agent *AgentInstance,
+	modelName, content string,
+) (routeTier, error) {
+	sel, err := al.resolveModel(agent, modelName)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := sel.Provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: classifierPrompt},
+		{Role: "user", Content: utils.Truncate(content, 2000)},
+	}, nil, sel.Model, map[string]any{
+		"max_tokens":  5,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return "", err
+	}
+	al.recordUsage(agent, "", sel.Model, resp.Usage)
+
+	answer := strings.ToLower(strings.TrimSpace(resp.Content))
+	for _, t := range []routeTier{tierSimple, tierStandard, tierComplex} {
+		if strings.HasPrefix(answer, string(t)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected classifier answer %q", utils.Truncate(resp.Content, 40))
+}
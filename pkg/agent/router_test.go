@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestClassifyTurn(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		media   int
+		want    routeTier
+		ok      bool
+	}{
+		{"greeting", "hi there!", 0, tierSimple, true},
+		{"short question", "what's the capital of France?", 0, tierSimple, true},
+		{"media", "what is this?", 1, tierComplex, true},
+		{"code block", "why does this fail?\n```go\nfmt.Println(x)\n```", 0, tierComplex, true},
+		{"complex keyword", "please refactor my parser", 0, tierComplex, true},
+		{"complex phrase", "explain step by step how TLS works", 0, tierComplex, true},
+		{"tool keyword", "what's the weather tomorrow", 0, tierStandard, true},
+		{"url", "summarize https://example.com/post", 0, tierStandard, true},
+		{"long message", strings.Repeat("lorem ipsum ", 80), 0, tierComplex, true},
+		{"medium message", strings.Repeat("tell me more about that ", 10), 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, ok := classifyTurn(tt.content, tt.media, 0)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("classifyTurn() = (%q, %q, %v), want (%q, %v)", got, reason, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestModelRouting_PicksTierModel(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Routing: &config.RoutingConfig{
+					Enabled:         true,
+					Simple:          "small",
+					Complex:         "big",
+					ClassifierModel: "small",
+				},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "small", Model: "ollama/small-model", APIBase: "http://localhost:11434/v1"},
+			{ModelName: "big", Model: "openai/big-model", APIKey: "test-key"},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "default reply"})
+	routed := &classifierMockProvider{label: "complex"}
+	al.newProvider = func(mc *config.ModelConfig) (providers.LLMProvider, string, error) {
+		_, modelID := providers.ExtractProtocol(mc.Model)
+		return routed, modelID, nil
+	}
+	helper := testHelper{al: al}
+
+	send := func(content string) string {
+		return helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "user1",
+			ChatID:   "chat1",
+			Content:  content,
+		})
+	}
+
+	if resp := send("hello!"); resp != "reply from small-model" {
+		t.Errorf("greeting response = %q, want the simple tier", resp)
+	}
+	if resp := send("search the news for me"); resp != "default reply" {
+		t.Errorf("tool turn response = %q, want the agent model", resp)
+	}
+	if resp := send(strings.Repeat("tell me more about that ", 10)); resp != "reply from big-model" {
+		t.Errorf("ambiguous turn response = %q, want the classifier's complex tier", resp)
+	}
+	if resp := send("ok go on"); resp != "reply from big-model" {
+		t.Errorf("follow-up response = %q, want to stay on the complex tier", resp)
+	}
+
+	agent, sessionKey := al.sessionFor(bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"})
+	if desc := al.currentModelName(agent, sessionKey); !strings.Contains(desc, "follow-up to a complex turn") {
+		t.Errorf("currentModelName() = %q, want the last routing decision", desc)
+	}
+
+	// A new conversation does not follow up on the old one.
+	send("/new")
+	if _, ok := al.lastRoute.Load(routeKey(agent, sessionKey)); ok {
+		t.Error("routing decision kept after /new")
+	}
+	if resp := send("ok go on"); resp != "reply from small-model" {
+		t.Errorf("response after /new = %q, want the simple tier", resp)
+	}
+}
+
+// classifierMockProvider answers classifier prompts with label and echoes
+// the model for regular turns.
+type classifierMockProvider struct {
+	label string
+}
+
+func (m *classifierMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if messages[0].Content == classifierPrompt {
+		return &providers.LLMResponse{Content: m.label}, nil
+	}
+	return &providers.LLMResponse{Content: "reply from " + model}, nil
+}
+
+func (m *classifierMockProvider) GetDefaultModel() string {
+	return "classifier-model"
+}
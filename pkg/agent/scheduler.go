@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+const defaultMaxConcurrentSessions = 4
+
+// sessionScheduler dispatches inbound messages to per-session workers.
+//
+// Messages that share a key are handled strictly in arrival order by a single
+// worker goroutine, so a session's history is never mutated by two turns at
+// once. Messages with different keys are handled in parallel, bounded by a
+// global semaphore so a burst of chats cannot exhaust memory or provider
+// rate limits on small boards.
+//
+// A worker exists only while its key has pending work; idle sessions cost
+// nothing beyond the map entry removed when the queue drains.
+type sessionScheduler struct {
+	handle func(ctx context.Context, msg bus.InboundMessage)
+	sem    chan struct{}
+
+	mu     sync.Mutex
+	queues map[string][]bus.InboundMessage // presence of a key means a worker is active
+	wg     sync.WaitGroup
+}
+
+func newSessionScheduler(
+	maxConcurrent int,
+	handle func(ctx context.Context, msg bus.InboundMessage),
+) *sessionScheduler {
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrentSessions
+	}
+	return &sessionScheduler{
+		handle: handle,
+		sem:    make(chan struct{}, maxConcurrent),
+		queues: make(map[string][]bus.InboundMessage),
+	}
+}
+
+// Submit enqueues msg for the given session key. If no worker is active for
+// the key, one is started; otherwise the message waits behind earlier ones.
+func (s *sessionScheduler) Submit(ctx context.Context, key string, msg bus.InboundMessage) {
+	s.mu.Lock()
+	if pending, active := s.queues[key]; active {
+		s.queues[key] = append(pending, msg)
+		s.mu.Unlock()
+		return
+	}
+	s.queues[key] = nil
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go s.worker(ctx, key, msg)
+}
+
+// worker processes msg and then drains the key's queue, exiting once empty.
+func (s *sessionScheduler) worker(ctx context.Context, key string, msg bus.InboundMessage) {
+	defer s.wg.Done()
+
+	for {
+		if !s.acquire(ctx) {
+			// Shutting down: drop whatever is still queued for this key.
+			s.mu.Lock()
+			delete(s.queues, key)
+			s.mu.Unlock()
+			return
+		}
+
+		s.handle(ctx, msg)
+		<-s.sem
+
+		s.mu.Lock()
+		pending := s.queues[key]
+		if len(pending) == 0 {
+			delete(s.queues, key)
+			s.mu.Unlock()
+			return
+		}
+		msg = pending[0]
+		pending[0] = bus.InboundMessage{} // release references held by the backing array
+		s.queues[key] = pending[1:]
+		s.mu.Unlock()
+	}
+}
+
+// acquire takes a concurrency slot, returning false if ctx is done first.
+func (s *sessionScheduler) acquire(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ActiveSessions returns the number of session keys with a running worker.
+func (s *sessionScheduler) ActiveSessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queues)
+}
+
+// Wait blocks until every worker has exited.
+func (s *sessionScheduler) Wait() {
+	s.wg.Wait()
+}
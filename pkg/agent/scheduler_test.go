@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestSessionScheduler_PreservesOrderWithinSession(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+
+	s := newSessionScheduler(4, func(ctx context.Context, msg bus.InboundMessage) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, msg.Content)
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	want := []string{"1", "2", "3", "4", "5"}
+	for _, c := range want {
+		s.Submit(ctx, "session-a", bus.InboundMessage{Content: c})
+	}
+	s.Wait()
+
+	if len(got) != len(want) {
+		t.Fatalf("handled %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+	if n := s.ActiveSessions(); n != 0 {
+		t.Errorf("ActiveSessions() = %d after drain, want 0", n)
+	}
+}
+
+func TestSessionScheduler_RunsSessionsConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+
+	s := newSessionScheduler(4, func(ctx context.Context, msg bus.InboundMessage) {
+		started <- msg.SessionKey
+		<-release
+	})
+
+	ctx := context.Background()
+	s.Submit(ctx, "a", bus.InboundMessage{SessionKey: "a"})
+	s.Submit(ctx, "b", bus.InboundMessage{SessionKey: "b"})
+
+	// Both sessions must start while the other is still blocked.
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("independent session was blocked by another session")
+		}
+	}
+	close(release)
+	s.Wait()
+}
+
+func TestSessionScheduler_RespectsConcurrencyCap(t *testing.T) {
+	var running, peak atomic.Int32
+
+	s := newSessionScheduler(2, func(ctx context.Context, msg bus.InboundMessage) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		s.Submit(ctx, key, bus.InboundMessage{})
+	}
+	s.Wait()
+
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", p)
+	}
+}
+
+func TestSessionScheduler_DropsQueuedOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled atomic.Int32
+
+	s := newSessionScheduler(1, func(ctx context.Context, msg bus.InboundMessage) {
+		handled.Add(1)
+		cancel()
+	})
+
+	s.Submit(ctx, "a", bus.InboundMessage{})
+	s.Submit(ctx, "a", bus.InboundMessage{})
+	s.Submit(ctx, "a", bus.InboundMessage{})
+	s.Wait()
+
+	if n := handled.Load(); n != 1 {
+		t.Errorf("handled %d messages, want only the one before cancel", n)
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// streamPublishInterval bounds how often partial responses are put on the
+// bus. Channels apply their own, usually stricter, edit throttling on top.
+const streamPublishInterval = 500 * time.Millisecond
+
+// streamPublisher accumulates streamed content deltas for one LLM call and
+// periodically publishes the text so far as a partial outbound message.
+type streamPublisher struct {
+	ctx     context.Context
+	bus     *bus.MessageBus
+	channel string
+	chatID  string
+
+	mu       sync.Mutex
+	buf      strings.Builder
+	lastSent time.Time
+}
+
+func newStreamPublisher(ctx context.Context, msgBus *bus.MessageBus, channel, chatID string) *streamPublisher {
+	return &streamPublisher{
+		ctx:     ctx,
+		bus:     msgBus,
+		channel: channel,
+		chatID:  chatID,
+	}
+}
+
+// OnDelta appends a content fragment and publishes if the interval elapsed.
+func (sp *streamPublisher) OnDelta(delta string) {
+	sp.mu.Lock()
+	sp.buf.WriteString(delta)
+	if time.Since(sp.lastSent) < streamPublishInterval {
+		sp.mu.Unlock()
+		return
+	}
+	sp.lastSent = time.Now()
+	content := sp.buf.String()
+	sp.mu.Unlock()
+
+	sp.publish(content)
+}
+
+// Reset discards accumulated text, e.g. before a fallback attempt restarts
+// generation with another model.
+func (sp *streamPublisher) Reset() {
+	sp.mu.Lock()
+	sp.buf.Reset()
+	sp.mu.Unlock()
+}
+
+func (sp *streamPublisher) publish(content string) {
+	if strings.TrimSpace(content) == "" || sp.ctx.Err() != nil {
+		return
+	}
+
+	// Partial updates are best-effort: never stall the provider's read loop
+	// on a full outbound bus, the final message will carry the full text.
+	pubCtx, cancel := context.WithTimeout(sp.ctx, 100*time.Millisecond)
+	defer cancel()
+
+	err := sp.bus.PublishOutbound(pubCtx, bus.OutboundMessage{
+		Channel: sp.channel,
+		ChatID:  sp.chatID,
+		Content: content,
+		Partial: true,
+	})
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, bus.ErrBusClosed) {
+		logger.WarnCF("agent", "Failed to publish partial response", map[string]any{
+			"channel": sp.channel,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// maxRecentSegments is how many summaries of recent compactions are kept
+	// side by side before the oldest are folded into the earlier summary.
+	maxRecentSegments = 3
+	// maxPinnedFacts bounds the pinned facts of a session.
+	maxPinnedFacts = 30
+
+	pinnedHeading  = "## Pinned facts"
+	earlierHeading = "## Earlier conversation"
+	recentHeading  = "## Recent conversation"
+	pinPrefix      = "PIN:"
+)
+
+// rollingSummary is the summary of a session's compacted history. Each
+// compaction adds a recent segment instead of rewriting the whole summary;
+// once there are more than maxRecentSegments, the oldest are folded into the
+// earlier summary. Pinned facts are carried over verbatim and never
+// summarized away. It is stored as the session summary in a Markdown form
+// the model reads directly.
+type rollingSummary struct {
+	Pinned  []string
+	Earlier string
+	Recent  []string
+}
+
+// parseRollingSummary reads a stored summary. Summaries written before
+// rolling summaries existed become the earlier summary.
+func parseRollingSummary(s string) rollingSummary {
+	var rs rollingSummary
+	var section string
+	var buf []string
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(buf, "\n"))
+		buf = buf[:0]
+		switch section {
+		case pinnedHeading:
+			for _, line := range strings.Split(text, "\n") {
+				if fact := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- ")); fact != "" {
+					rs.Pinned = append(rs.Pinned, fact)
+				}
+			}
+		case recentHeading:
+			if text != "" {
+				rs.Recent = append(rs.Recent, text)
+			}
+		default:
+			if text != "" {
+				rs.Earlier = strings.TrimSpace(rs.Earlier + "\n\n" + text)
+			}
+		}
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		switch {
+		case line == pinnedHeading, line == earlierHeading:
+			flush()
+			section = line
+		case strings.HasPrefix(line, recentHeading+" (part ") && strings.HasSuffix(line, ")"):
+			flush()
+			section = recentHeading
+		default:
+			buf = append(buf, line)
+		}
+	}
+	flush()
+	return rs
+}
+
+// String renders the summary for storage and for the prompt.
+func (rs rollingSummary) String() string {
+	var parts []string
+	if len(rs.Pinned) > 0 {
+		var sb strings.Builder
+		sb.WriteString(pinnedHeading)
+		for _, fact := range rs.Pinned {
+			sb.WriteString("\n- ")
+			sb.WriteString(fact)
+		}
+		parts = append(parts, sb.String())
+	}
+	if rs.Earlier != "" {
+		parts = append(parts, earlierHeading+"\n"+rs.Earlier)
+	}
+	for i, segment := range rs.Recent {
+		parts = append(parts, fmt.Sprintf("%s (part %d)\n%s", recentHeading, i+1, segment))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// pin adds facts that are not pinned yet. It returns false if the limit of
+// pinned facts is reached before all were added.
+func (rs *rollingSummary) pin(facts ...string) bool {
+	for _, fact := range facts {
+		fact = strings.Join(strings.Fields(fact), " ")
+		if fact == "" || rs.isPinned(fact) {
+			continue
+		}
+		if len(rs.Pinned) >= maxPinnedFacts {
+			return false
+		}
+		rs.Pinned = append(rs.Pinned, fact)
+	}
+	return true
+}
+
+func (rs *rollingSummary) isPinned(fact string) bool {
+	for _, p := range rs.Pinned {
+		if strings.EqualFold(p, fact) {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryModel returns the model compaction runs with: summary_model when
+// it is set and usable, otherwise the agent's model.
+func (al *AgentLoop) summaryModel(agent *AgentInstance) modelSelection {
+	name := al.cfg.Agents.Defaults.SummaryModel
+	if name == "" {
+		return agentModelSelection(agent)
+	}
+	sel, err := al.resolveModel(agent, name)
+	if err != nil {
+		logger.WarnCF("agent", "Summary model unusable, using agent model",
+			map[string]any{"agent_id": agent.ID, "model": name, "error": err.Error()})
+		return agentModelSelection(agent)
+	}
+	return sel
+}
+
+// summarizeBatch summarizes a batch of messages. The rolling summary rs, if
+// given, is context the new summary should not repeat. Facts the model marks
+// as must-keep are returned separately.
+func (al *AgentLoop) summarizeBatch(
+	ctx context.Context,
+	agent *AgentInstance,
+	sel modelSelection,
+	batch []providers.Message,
+	rs rollingSummary,
+) (string, []string, error) {
+	var sb strings.Builder
+	sb.WriteString(
+		"Provide a concise summary of this conversation segment, preserving core context and key points.\n",
+	)
+	if rs.Earlier != "" || len(rs.Recent) > 0 {
+		sb.WriteString("Existing context (do not repeat it): ")
+		sb.WriteString(strings.TrimSpace(rs.Earlier + "\n" + strings.Join(rs.Recent, "\n")))
+		sb.WriteString("\n")
+	}
+	if len(rs.Pinned) > 0 {
+		sb.WriteString("Already pinned facts (do not repeat them): ")
+		sb.WriteString(strings.Join(rs.Pinned, "; "))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("After the summary, list facts from this segment that must never be forgotten " +
+		"(names, decisions, commitments, preferences), one per line starting with \"" + pinPrefix +
+		" \". Leave the list out if there are none.\n")
+	sb.WriteString("\nCONVERSATION:\n")
+	for _, m := range batch {
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
+	}
+
+	content, err := al.summaryChat(ctx, agent, sel, sb.String())
+	if err != nil {
+		return "", nil, err
+	}
+
+	var summary []string
+	var pins []string
+	for _, line := range strings.Split(content, "\n") {
+		if fact, ok := strings.CutPrefix(strings.TrimSpace(line), pinPrefix); ok {
+			pins = append(pins, strings.TrimSpace(fact))
+			continue
+		}
+		summary = append(summary, line)
+	}
+	return strings.TrimSpace(strings.Join(summary, "\n")), pins, nil
+}
+
+// foldSummary merges the earlier summary and all but the newest recent
+// segment into a new earlier summary.
+func (al *AgentLoop) foldSummary(
+	ctx context.Context,
+	agent *AgentInstance,
+	sel modelSelection,
+	rs *rollingSummary,
+) error {
+	if len(rs.Recent) < 2 {
+		return nil
+	}
+	fold := rs.Recent[:len(rs.Recent)-1]
+
+	var sb strings.Builder
+	sb.WriteString("Condense these summaries of one conversation, oldest first, into a single summary " +
+		"of the earlier conversation. Keep decisions, open tasks and facts; drop small talk.\n")
+	n := 1
+	if rs.Earlier != "" {
+		fmt.Fprintf(&sb, "\n%d: %s\n", n, rs.Earlier)
+		n++
+	}
+	for _, segment := range fold {
+		fmt.Fprintf(&sb, "\n%d: %s\n", n, segment)
+		n++
+	}
+
+	earlier, err := al.summaryChat(ctx, agent, sel, sb.String())
+	if err != nil {
+		return err
+	}
+	if earlier = strings.TrimSpace(earlier); earlier == "" {
+		return fmt.Errorf("empty summary")
+	}
+	rs.Earlier = earlier
+	rs.Recent = append([]string(nil), rs.Recent[len(fold):]...)
+	return nil
+}
+
+func (al *AgentLoop) summaryChat(
+	ctx context.Context,
+	agent *AgentInstance,
+	sel modelSelection,
+	prompt string,
+) (string, error) {
+	response, err := sel.Provider.Chat(
+		ctx,
+		[]providers.Message{{Role: "user", Content: prompt}},
+		nil,
+		sel.Model,
+		map[string]any{
+			"max_tokens":       1024,
+			"temperature":      0.3,
+			"prompt_cache_key": agent.ID,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	al.recordUsage(agent, "", sel.Model, response.Usage)
+	return response.Content, nil
+}
+
+// pinFact pins a fact in msg's session so compaction never drops it.
+func (al *AgentLoop) pinFact(msg bus.InboundMessage, fact string) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+	fact = strings.TrimSpace(fact)
+	if fact == "" {
+		return "Usage: /pin <fact to keep>"
+	}
+
+	rs := parseRollingSummary(agent.Sessions.GetSummary(sessionKey))
+	if rs.isPinned(fact) {
+		return "Already pinned."
+	}
+	if !rs.pin(fact) {
+		return fmt.Sprintf("At most %d facts can be pinned, /unpin one first.", maxPinnedFacts)
+	}
+	agent.Sessions.SetSummary(sessionKey, rs.String())
+	agent.Sessions.Save(sessionKey)
+	return fmt.Sprintf("📌 Pinned #%d: %s", len(rs.Pinned), fact)
+}
+
+// unpinFact removes the pinned fact numbered arg, as listed by /pins.
+func (al *AgentLoop) unpinFact(msg bus.InboundMessage, arg string) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+
+	rs := parseRollingSummary(agent.Sessions.GetSummary(sessionKey))
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 1 || n > len(rs.Pinned) {
+		if len(rs.Pinned) == 0 {
+			return "No pinned facts."
+		}
+		return fmt.Sprintf("Usage: /unpin <1-%d>", len(rs.Pinned))
+	}
+	fact := rs.Pinned[n-1]
+	rs.Pinned = append(rs.Pinned[:n-1], rs.Pinned[n:]...)
+	agent.Sessions.SetSummary(sessionKey, rs.String())
+	agent.Sessions.Save(sessionKey)
+	return fmt.Sprintf("Unpinned: %s", fact)
+}
+
+// listPins lists the pinned facts of msg's session.
+func (al *AgentLoop) listPins(msg bus.InboundMessage) string {
+	agent, sessionKey := al.sessionFor(msg)
+	if agent == nil {
+		return "No agent available"
+	}
+
+	rs := parseRollingSummary(agent.Sessions.GetSummary(sessionKey))
+	if len(rs.Pinned) == 0 {
+		return "No pinned facts. Use /pin <fact> to keep something through compaction."
+	}
+	var sb strings.Builder
+	sb.WriteString("Pinned facts:")
+	for i, fact := range rs.Pinned {
+		fmt.Fprintf(&sb, "\n%d. %s", i+1, fact)
+	}
+	return sb.String()
+}
@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestRollingSummary_RoundTrip(t *testing.T) {
+	rs := rollingSummary{
+		Pinned:  []string{"User's name is Ada", "Deploy on Fridays is forbidden"},
+		Earlier: "We set up the project.",
+		Recent:  []string{"We wrote the parser.", "We fixed a bug\nin the lexer."},
+	}
+	if got := parseRollingSummary(rs.String()); !reflect.DeepEqual(got, rs) {
+		t.Errorf("parseRollingSummary(String()) = %+v, want %+v", got, rs)
+	}
+
+	legacy := parseRollingSummary("The user asked about Go.\n\nWe discussed generics.")
+	if legacy.Earlier != "The user asked about Go.\n\nWe discussed generics." || legacy.Pinned != nil || legacy.Recent != nil {
+		t.Errorf("legacy summary parsed as %+v, want it all in Earlier", legacy)
+	}
+
+	if got := parseRollingSummary(""); !reflect.DeepEqual(got, rollingSummary{}) {
+		t.Errorf("empty summary parsed as %+v", got)
+	}
+}
+
+func TestRollingSummary_Pin(t *testing.T) {
+	var rs rollingSummary
+	rs.pin("a  fact", "A FACT", "", "other")
+	if want := []string{"a fact", "other"}; !reflect.DeepEqual(rs.Pinned, want) {
+		t.Fatalf("Pinned = %v, want %v", rs.Pinned, want)
+	}
+
+	for i := len(rs.Pinned); i < maxPinnedFacts; i++ {
+		rs.pin(fmt.Sprintf("fact %d", i))
+	}
+	if rs.pin("one too many") {
+		t.Error("pin() beyond maxPinnedFacts = true, want false")
+	}
+	if len(rs.Pinned) != maxPinnedFacts {
+		t.Errorf("len(Pinned) = %d, want %d", len(rs.Pinned), maxPinnedFacts)
+	}
+}
+
+// summaryMockProvider answers summarization prompts with a numbered segment
+// and a pinned fact, and condense prompts with a folded summary.
+type summaryMockProvider struct {
+	mu       sync.Mutex
+	models   []string
+	segments int
+}
+
+func (m *summaryMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = append(m.models, model)
+	if strings.HasPrefix(messages[0].Content, "Condense") {
+		return &providers.LLMResponse{Content: "folded summary"}, nil
+	}
+	m.segments++
+	return &providers.LLMResponse{
+		Content: fmt.Sprintf("segment %d\nPIN: User's name is Ada", m.segments),
+	}, nil
+}
+
+func (m *summaryMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func addExchanges(agent *AgentInstance, sessionKey string, n int) {
+	for i := 0; i < n; i++ {
+		agent.Sessions.AddMessage(sessionKey, "user", fmt.Sprintf("question %d", i))
+		agent.Sessions.AddMessage(sessionKey, "assistant", fmt.Sprintf("answer %d", i))
+	}
+}
+
+func TestSummarizeSession_RollingSegments(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &summaryMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent, sessionKey := al.sessionFor(bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"})
+
+	agent.Sessions.SetSummary(sessionKey, "Legacy summary.")
+	if resp := al.pinFact(bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"},
+		"Prefers metric units"); !strings.Contains(resp, "Pinned #1") {
+		t.Fatalf("pinFact() = %q", resp)
+	}
+
+	addExchanges(agent, sessionKey, 4)
+	al.summarizeSession(agent, sessionKey)
+
+	rs := parseRollingSummary(agent.Sessions.GetSummary(sessionKey))
+	if rs.Earlier != "Legacy summary." || !reflect.DeepEqual(rs.Recent, []string{"segment 1"}) {
+		t.Errorf("summary after first compaction = %+v", rs)
+	}
+	if want := []string{"Prefers metric units", "User's name is Ada"}; !reflect.DeepEqual(rs.Pinned, want) {
+		t.Errorf("Pinned = %v, want %v", rs.Pinned, want)
+	}
+	if got := len(agent.Sessions.GetHistory(sessionKey)); got != 4 {
+		t.Errorf("history after compaction = %d messages, want 4", got)
+	}
+
+	for i := 0; i < maxRecentSegments; i++ {
+		addExchanges(agent, sessionKey, 2)
+		al.summarizeSession(agent, sessionKey)
+	}
+
+	rs = parseRollingSummary(agent.Sessions.GetSummary(sessionKey))
+	if rs.Earlier != "folded summary" {
+		t.Errorf("Earlier = %q, want the folded summary", rs.Earlier)
+	}
+	if want := []string{fmt.Sprintf("segment %d", maxRecentSegments+1)}; !reflect.DeepEqual(rs.Recent, want) {
+		t.Errorf("Recent = %v, want %v", rs.Recent, want)
+	}
+	if len(rs.Pinned) != 2 {
+		t.Errorf("Pinned = %v, want the two facts kept through folding", rs.Pinned)
+	}
+}
+
+func TestSummarizeSession_UsesSummaryModel(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				SummaryModel:      "cheap",
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "cheap", Model: "ollama/cheap-model", APIBase: "http://localhost:11434/v1"},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "main model"})
+	cheap := &summaryMockProvider{}
+	al.newProvider = func(mc *config.ModelConfig) (providers.LLMProvider, string, error) {
+		_, modelID := providers.ExtractProtocol(mc.Model)
+		return cheap, modelID, nil
+	}
+	agent, sessionKey := al.sessionFor(bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"})
+
+	addExchanges(agent, sessionKey, 4)
+	al.summarizeSession(agent, sessionKey)
+
+	if want := []string{"cheap-model"}; !reflect.DeepEqual(cheap.models, want) {
+		t.Errorf("summary calls went to %v, want %v", cheap.models, want)
+	}
+	if summary := agent.Sessions.GetSummary(sessionKey); !strings.Contains(summary, "segment 1") {
+		t.Errorf("summary = %q, want the summary model's segment", summary)
+	}
+
+	cfg.Agents.Defaults.SummaryModel = "missing"
+	if sel := al.summaryModel(agent); sel.Model != agent.Model {
+		t.Errorf("summaryModel() with unknown model = %q, want fallback to %q", sel.Model, agent.Model)
+	}
+}
+
+func TestPinCommands(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	helper := testHelper{al: al}
+
+	send := func(content string) string {
+		return helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "user1",
+			ChatID:   "chat1",
+			Content:  content,
+		})
+	}
+
+	if resp := send("/pins"); !strings.HasPrefix(resp, "No pinned facts") {
+		t.Errorf("unexpected /pins response on empty session: %q", resp)
+	}
+	if resp := send("/pin"); !strings.HasPrefix(resp, "Usage:") {
+		t.Errorf("unexpected /pin response without a fact: %q", resp)
+	}
+	if resp := send("/pin The server runs Debian 12"); !strings.Contains(resp, "Pinned #1") {
+		t.Errorf("unexpected /pin response: %q", resp)
+	}
+	if resp := send("/pin the server runs debian 12"); resp != "Already pinned." {
+		t.Errorf("unexpected /pin response for a duplicate: %q", resp)
+	}
+	send("/pin Budget is 500 EUR")
+
+	if resp := send("/pins"); resp != "Pinned facts:\n1. The server runs Debian 12\n2. Budget is 500 EUR" {
+		t.Errorf("unexpected /pins response: %q", resp)
+	}
+	if resp := send("/unpin 3"); resp != "Usage: /unpin <1-2>" {
+		t.Errorf("unexpected /unpin response for a bad index: %q", resp)
+	}
+	if resp := send("/unpin 1"); resp != "Unpinned: The server runs Debian 12" {
+		t.Errorf("unexpected /unpin response: %q", resp)
+	}
+
+	agent, sessionKey := al.sessionFor(bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"})
+	if summary := agent.Sessions.GetSummary(sessionKey); summary != pinnedHeading+"\n- Budget is 500 EUR" {
+		t.Errorf("summary = %q", summary)
+	}
+}
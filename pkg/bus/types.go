@@ -33,6 +33,9 @@ type OutboundMessage struct {
 	Channel string `json:"channel"`
 	ChatID  string `json:"chat_id"`
 	Content string `json:"content"`
+	// Partial marks an in-progress streamed response. Content holds the text
+	// generated so far and is superseded by the final (non-partial) message.
+	Partial bool `json:"partial,omitempty"`
 }
 
 // MediaPart describes a single media attachment to send.
@@ -56,6 +56,19 @@ type placeholderEntry struct {
 	createdAt time.Time
 }
 
+// streamEditInterval maps channel name to the minimum gap between
+// progressive placeholder edits of a streamed response. Edits count against
+// the same platform limits as sends, so chat platforms that throttle edits
+// aggressively get a wider gap.
+var streamEditInterval = map[string]time.Duration{
+	"telegram": 1 * time.Second,
+	"discord":  1500 * time.Millisecond,
+	"feishu":   1 * time.Second,
+	"pico":     250 * time.Millisecond,
+}
+
+const defaultStreamEditInterval = 2 * time.Second
+
 // channelRateConfig maps channel name to per-second rate limit.
 var channelRateConfig = map[string]float64{
 	"telegram": 20,
@@ -86,6 +99,7 @@ type Manager struct {
 	placeholders  sync.Map // "channel:chatID" → placeholderID (string)
 	typingStops   sync.Map // "channel:chatID" → func()
 	reactionUndos sync.Map // "channel:chatID" → reactionEntry
+	streamEdits   sync.Map // "channel:chatID" → time.Time of last partial edit
 }
 
 type asyncTask struct {
@@ -133,6 +147,7 @@ func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMess
 	}
 
 	// 3. Try editing placeholder
+	m.streamEdits.Delete(key)
 	if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
 		if entry, ok := v.(placeholderEntry); ok && entry.id != "" {
 			if editor, ok := ch.(MessageEditor); ok {
@@ -461,6 +476,10 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 			if !ok {
 				return
 			}
+			if msg.Partial {
+				m.editPartial(ctx, name, w, msg)
+				continue
+			}
 			maxLen := 0
 			if mlp, ok := w.ch.(MessageLengthProvider); ok {
 				maxLen = mlp.MaxMessageLength()
@@ -481,6 +500,59 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 	}
 }
 
+// editPartial progressively updates the placeholder of a chat with a
+// streamed, still incomplete response. Partial updates are best-effort: they
+// are dropped when the chat has no editable placeholder, when they arrive
+// faster than the channel's edit interval, or when the text no longer fits
+// in a single message. The final message always goes through preSend.
+func (m *Manager) editPartial(ctx context.Context, name string, w *channelWorker, msg bus.OutboundMessage) {
+	key := name + ":" + msg.ChatID
+
+	editor, ok := w.ch.(MessageEditor)
+	if !ok {
+		return
+	}
+	v, ok := m.placeholders.Load(key)
+	if !ok {
+		return
+	}
+	entry, ok := v.(placeholderEntry)
+	if !ok || entry.id == "" {
+		return
+	}
+
+	if mlp, ok := w.ch.(MessageLengthProvider); ok {
+		if maxLen := mlp.MaxMessageLength(); maxLen > 0 && len([]rune(msg.Content)) > maxLen {
+			return
+		}
+	}
+
+	interval, ok := streamEditInterval[name]
+	if !ok {
+		interval = defaultStreamEditInterval
+	}
+	now := time.Now()
+	if v, ok := m.streamEdits.Load(key); ok {
+		if last, ok := v.(time.Time); ok && now.Sub(last) < interval {
+			return
+		}
+	}
+	// Never wait for a rate-limit token: a skipped edit is superseded by the
+	// next one, while waiting would delay the final message.
+	if !w.limiter.Allow() {
+		return
+	}
+	m.streamEdits.Store(key, now)
+
+	if err := editor.EditMessage(ctx, msg.ChatID, entry.id, msg.Content); err != nil {
+		logger.DebugCF("channels", "Partial edit failed", map[string]any{
+			"channel": name,
+			"chat_id": msg.ChatID,
+			"error":   err.Error(),
+		})
+	}
+}
+
 // sendWithRetry sends a message through the channel with rate limiting and
 // retry logic. It classifies errors to determine the retry strategy:
 //   - ErrNotRunning / ErrSendFailed: permanent, no retry
@@ -744,6 +816,12 @@ func (m *Manager) runTTLJanitor(ctx context.Context) {
 				}
 				return true
 			})
+			m.streamEdits.Range(func(key, value any) bool {
+				if last, ok := value.(time.Time); ok && now.Sub(last) > placeholderTTL {
+					m.streamEdits.Delete(key)
+				}
+				return true
+			})
 		}
 	}
 }
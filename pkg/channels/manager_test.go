@@ -631,6 +631,125 @@ func TestSendWithRetry_PreSendEditsPlaceholder(t *testing.T) {
 	}
 }
 
+func TestEditPartial_ThrottlesAndKeepsPlaceholder(t *testing.T) {
+	m := newTestManager()
+	var edits []string
+	var sendCalled bool
+
+	ch := &mockMessageEditor{
+		mockChannel: mockChannel{
+			sendFn: func(_ context.Context, _ bus.OutboundMessage) error {
+				sendCalled = true
+				return nil
+			},
+		},
+		editFn: func(_ context.Context, _, _, content string) error {
+			edits = append(edits, content)
+			return nil
+		},
+	}
+
+	m.RecordPlaceholder("test", "123", "456")
+	w := &channelWorker{
+		ch:      ch,
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+
+	ctx := context.Background()
+	m.editPartial(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "Hel", Partial: true})
+	m.editPartial(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "Hello", Partial: true})
+
+	if len(edits) != 1 || edits[0] != "Hel" {
+		t.Fatalf("expected a single throttled edit with 'Hel', got %v", edits)
+	}
+	if sendCalled {
+		t.Fatal("partial updates must never call Send")
+	}
+
+	// The placeholder must survive partial edits so the final message can
+	// still replace it.
+	m.sendWithRetry(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "Hello, world"})
+	if sendCalled {
+		t.Fatal("expected final message to edit the placeholder instead of sending")
+	}
+	if last := edits[len(edits)-1]; last != "Hello, world" {
+		t.Fatalf("expected final edit 'Hello, world', got %q", last)
+	}
+	if _, ok := m.streamEdits.Load("test:123"); ok {
+		t.Fatal("expected stream edit state to be cleared by the final message")
+	}
+}
+
+func TestEditPartial_NoPlaceholderDropped(t *testing.T) {
+	m := newTestManager()
+	var editCalled, sendCalled bool
+
+	ch := &mockMessageEditor{
+		mockChannel: mockChannel{
+			sendFn: func(_ context.Context, _ bus.OutboundMessage) error {
+				sendCalled = true
+				return nil
+			},
+		},
+		editFn: func(_ context.Context, _, _, _ string) error {
+			editCalled = true
+			return nil
+		},
+	}
+	w := &channelWorker{
+		ch:      ch,
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+
+	m.editPartial(context.Background(), "test", w,
+		bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "partial", Partial: true})
+
+	if editCalled || sendCalled {
+		t.Fatal("expected partial update without placeholder to be dropped")
+	}
+}
+
+func TestRunWorker_PartialNotSplitOrSent(t *testing.T) {
+	m := newTestManager()
+
+	var mu sync.Mutex
+	var received []string
+
+	ch := &mockChannelWithLength{
+		mockChannel: mockChannel{
+			sendFn: func(_ context.Context, msg bus.OutboundMessage) error {
+				mu.Lock()
+				received = append(received, msg.Content)
+				mu.Unlock()
+				return nil
+			},
+		},
+		maxLen: 5,
+	}
+
+	w := &channelWorker{
+		ch:      ch,
+		queue:   make(chan bus.OutboundMessage, 10),
+		done:    make(chan struct{}),
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+
+	ctx := t.Context()
+
+	go m.runWorker(ctx, "test", w)
+
+	w.queue <- bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "a long partial", Partial: true}
+	w.queue <- bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "final"}
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != "final" {
+		t.Fatalf("expected only the final message to be sent, got %v", received)
+	}
+}
+
 // --- Dispatcher exit tests (Step 1) ---
 
 func TestDispatcherExitsOnCancel(t *testing.T) {
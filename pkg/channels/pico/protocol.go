@@ -5,9 +5,10 @@ import "time"
 // Protocol message types.
 const (
 	// TypeMessageSend is sent from client to server.
-	TypeMessageSend = "message.send"
-	TypeMediaSend   = "media.send"
-	TypePing        = "ping"
+	TypeMessageSend   = "message.send"
+	TypeMessageCancel = "message.cancel"
+	TypeMediaSend     = "media.send"
+	TypePing          = "ping"
 
 	// TypeMessageCreate is sent from server to client.
 	TypeMessageCreate = "message.create"
@@ -203,6 +203,42 @@ func (c *TelegramChannel) initBotCommands(ctx context.Context) error {
 			Command:     "list",
 			Description: "List available options",
 		},
+		{
+			Command:     "new",
+			Description: "Start a new conversation",
+		},
+		{
+			Command:     "stop",
+			Description: "Stop the current reply",
+		},
+		{
+			Command:     "undo",
+			Description: "Remove the last exchange",
+		},
+		{
+			Command:     "history",
+			Description: "Show the conversation history",
+		},
+		{
+			Command:     "compact",
+			Description: "Summarize the conversation now",
+		},
+		{
+			Command:     "pin",
+			Description: "Keep a fact through summarization",
+		},
+		{
+			Command:     "pins",
+			Description: "List pinned facts",
+		},
+		{
+			Command:     "unpin",
+			Description: "Remove a pinned fact",
+		},
+		{
+			Command:     "usage",
+			Description: "Show token usage and cost",
+		},
 	}
 
 	// Setting commands on each start will hit the rate limit very quickly, that's why we check if an update is needed
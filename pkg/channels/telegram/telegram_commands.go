@@ -42,6 +42,16 @@ func (c *cmd) Help(ctx context.Context, message telego.Message) error {
 /help - Show this help message
 /show [model|channel] - Show current configuration
 /list [models|channels] - List available options
+/new - Archive this conversation and start a new one
+/reset - Clear this conversation
+/stop - Stop the current reply and its subagents
+/undo - Remove the last exchange
+/history [page] - Show the conversation history
+/compact - Summarize the conversation now
+/pin <fact> - Keep a fact through summarization
+/pins - List pinned facts
+/unpin <n> - Remove a pinned fact
+/usage - Show token usage and cost
 	`
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 		ChatID: telego.ChatID{ID: message.Chat.ID},
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"sync/atomic"
 
 	"github.com/caarlos0/env/v11"
@@ -137,6 +138,32 @@ type AgentConfig struct {
 	Model     *AgentModelConfig `json:"model,omitempty"`
 	Skills    []string          `json:"skills,omitempty"`
 	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	Budget    *BudgetConfig     `json:"budget,omitempty"` // Overrides agents.defaults.budget
+}
+
+// BudgetConfig limits LLM usage of an agent. Zero limits are disabled.
+// Costs are in USD and computed from the input_price/output_price of
+// model_list entries.
+type BudgetConfig struct {
+	DailyTokens   int64   `json:"daily_tokens,omitempty"`
+	MonthlyTokens int64   `json:"monthly_tokens,omitempty"`
+	DailyCost     float64 `json:"daily_cost,omitempty"`
+	MonthlyCost   float64 `json:"monthly_cost,omitempty"`
+	// Action when a limit is exceeded: "refuse" (default) answers with a
+	// budget notice, "downgrade" switches to the cheapest fallback model.
+	Action string `json:"action,omitempty"`
+}
+
+// RoutingConfig routes each turn to a model tier. Tiers name model_list
+// entries; an empty tier uses the agent's model.
+type RoutingConfig struct {
+	Enabled bool   `json:"enabled"`
+	Simple  string `json:"simple,omitempty"`  // chit-chat and short questions, e.g. a local model
+	Complex string `json:"complex,omitempty"` // long, technical or multi-step tasks
+	// Optional small model_list entry asked to label turns the heuristics
+	// cannot decide.
+	ClassifierModel string `json:"classifier_model,omitempty"`
+	MaxSimpleChars  int    `json:"max_simple_chars,omitempty"` // 0 means use default (160)
 }
 
 type SubagentsConfig struct {
@@ -163,8 +190,20 @@ type AgentBinding struct {
 }
 
 type SessionConfig struct {
-	DMScope       string              `json:"dm_scope,omitempty"`
-	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	DMScope       string                 `json:"dm_scope,omitempty"`
+	IdentityLinks map[string][]string    `json:"identity_links,omitempty"`
+	Retention     SessionRetentionConfig `json:"retention"`
+}
+
+// SessionRetentionConfig bounds how long and how many sessions the gateway
+// keeps. Zero limits are disabled.
+type SessionRetentionConfig struct {
+	MaxIdleDays     int  `json:"max_idle_days"    env:"PICOCLAW_SESSION_RETENTION_MAX_IDLE_DAYS"`    // delete sessions idle for longer
+	MaxSessions     int  `json:"max_sessions"     env:"PICOCLAW_SESSION_RETENTION_MAX_SESSIONS"`     // per agent, least recently used go first
+	MaxMessages     int  `json:"max_messages"     env:"PICOCLAW_SESSION_RETENTION_MAX_MESSAGES"`     // older messages of a session are trimmed
+	Archive         bool `json:"archive"          env:"PICOCLAW_SESSION_RETENTION_ARCHIVE"`          // keep a compressed copy under sessions/archive/YYYY-MM-DD
+	Summarize       bool `json:"summarize"        env:"PICOCLAW_SESSION_RETENTION_SUMMARIZE"`        // save a private summary before deleting
+	IntervalMinutes int  `json:"interval_minutes" env:"PICOCLAW_SESSION_RETENTION_INTERVAL_MINUTES"` // how often the janitor runs
 }
 
 type AgentDefaults struct {
@@ -178,11 +217,23 @@ type AgentDefaults struct {
 	ImageModel                string   `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
 	ImageModelFallbacks       []string `json:"image_model_fallbacks,omitempty"`
 	MaxTokens                 int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	ContextWindow             int      `json:"context_window,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_CONTEXT_WINDOW"` // Model context window in tokens; when set, history is trimmed to fit before each call
 	Temperature               *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
 	MaxToolIterations         int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
 	SummarizeMessageThreshold int      `json:"summarize_message_threshold"     env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_MESSAGE_THRESHOLD"`
 	SummarizeTokenPercent     int      `json:"summarize_token_percent"         env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_TOKEN_PERCENT"`
+	SummaryModel              string   `json:"summary_model,omitempty"         env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARY_MODEL"` // model_list name used for compaction; "" uses the agent model
 	MaxMediaSize              int      `json:"max_media_size,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_MEDIA_SIZE"`
+	MaxConcurrentSessions     int      `json:"max_concurrent_sessions"         env:"PICOCLAW_AGENTS_DEFAULTS_MAX_CONCURRENT_SESSIONS"` // Sessions processed in parallel; 0 uses the default (4)
+	Streaming                 bool     `json:"streaming"                       env:"PICOCLAW_AGENTS_DEFAULTS_STREAMING"`               // Progressively edit placeholders while the LLM responds
+	MaxParallelToolCalls      int      `json:"max_parallel_tool_calls"         env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOL_CALLS"` // Concurrency-safe tool calls run in parallel; 0 uses the default (4)
+	ToolTimeout               int      `json:"tool_timeout"                    env:"PICOCLAW_AGENTS_DEFAULTS_TOOL_TIMEOUT"`            // Seconds per tool call; 0 uses the default (300)
+	SessionStore              string   `json:"session_store,omitempty"         env:"PICOCLAW_AGENTS_DEFAULTS_SESSION_STORE"`           // "jsonl" (default) or "sqlite"
+
+	// Usage limits for all agents; see BudgetConfig
+	Budget *BudgetConfig `json:"budget,omitempty"`
+	// Per-turn model routing between cheap and strong models; see RoutingConfig
+	Routing *RoutingConfig `json:"routing,omitempty"`
 }
 
 const DefaultMaxMediaSize = 20 * 1024 * 1024 // 20 MB
@@ -508,6 +559,10 @@ type ModelConfig struct {
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
 	ThinkingLevel  string `json:"thinking_level,omitempty"` // Extended thinking: off|low|medium|high|xhigh|adaptive
+
+	// Pricing in USD per million tokens, used for cost accounting and budgets
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -582,6 +637,27 @@ type WebToolsConfig struct {
 	// For authenticated proxies, prefer HTTP_PROXY/HTTPS_PROXY env vars instead of embedding credentials in config.
 	Proxy           string `json:"proxy,omitempty"             env:"PICOCLAW_TOOLS_WEB_PROXY"`
 	FetchLimitBytes int64  `json:"fetch_limit_bytes,omitempty" env:"PICOCLAW_TOOLS_WEB_FETCH_LIMIT_BYTES"`
+
+	// TrustedHosts lists host names ("*.lan" for subdomains), IPs and CIDR
+	// ranges that web_fetch may reach although they are loopback, private,
+	// link-local or metadata addresses, which are blocked otherwise.
+	TrustedHosts []string `json:"trusted_hosts,omitempty" env:"PICOCLAW_TOOLS_WEB_TRUSTED_HOSTS"`
+
+	// Search combines several of the providers above; see WebSearchConfig
+	Search WebSearchConfig `json:"search"`
+}
+
+// WebSearchConfig configures aggregated web search. When Providers is
+// empty, web_search uses the first enabled provider alone.
+type WebSearchConfig struct {
+	// Providers are tried in order: "brave", "tavily", "duckduckgo",
+	// "perplexity", "searxng" or "glm_search". Each must also be enabled.
+	Providers []string `json:"providers,omitempty" env:"PICOCLAW_TOOLS_WEB_SEARCH_PROVIDERS"`
+	// MergeProviders is how many providers' results are merged per search;
+	// 1 only fails over to the next provider on errors.
+	MergeProviders int `json:"merge_providers" env:"PICOCLAW_TOOLS_WEB_SEARCH_MERGE_PROVIDERS"`
+	// CacheTTLSeconds caches results per query; 0 disables the cache.
+	CacheTTLSeconds int `json:"cache_ttl_seconds" env:"PICOCLAW_TOOLS_WEB_SEARCH_CACHE_TTL_SECONDS"`
 }
 
 type CronToolsConfig struct {
@@ -595,6 +671,92 @@ type ExecConfig struct {
 	CustomDenyPatterns  []string `                                 env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"  json:"custom_deny_patterns"`
 	CustomAllowPatterns []string `                                 env:"PICOCLAW_TOOLS_EXEC_CUSTOM_ALLOW_PATTERNS" json:"custom_allow_patterns"`
 	TimeoutSeconds      int      `                                 env:"PICOCLAW_TOOLS_EXEC_TIMEOUT_SECONDS"       json:"timeout_seconds"` // 0 means use default (60s)
+
+	// Process isolation for commands; see ExecSandboxConfig
+	Sandbox ExecSandboxConfig `json:"sandbox"`
+
+	// Commands started with "background": true
+	MaxBackgroundJobs  int `json:"max_background_jobs"  env:"PICOCLAW_TOOLS_EXEC_MAX_BACKGROUND_JOBS"`  // per session; 0 means default (4)
+	BackgroundOutputKB int `json:"background_output_kb" env:"PICOCLAW_TOOLS_EXEC_BACKGROUND_OUTPUT_KB"` // output kept per job; 0 means default (64)
+}
+
+// ExecSandboxConfig confines the commands of the exec tool. The "linux"
+// backend uses namespaces, Landlock and seccomp and needs no root. Commands
+// can read system directories and ReadPaths, and write the workspace and
+// WritePaths only.
+type ExecSandboxConfig struct {
+	Backend       string   `json:"backend"         env:"PICOCLAW_TOOLS_EXEC_SANDBOX_BACKEND"`         // "" or "none" disables, "linux"
+	ReadPaths     []string `json:"read_paths"      env:"PICOCLAW_TOOLS_EXEC_SANDBOX_READ_PATHS"`      // readable besides system directories
+	WritePaths    []string `json:"write_paths"     env:"PICOCLAW_TOOLS_EXEC_SANDBOX_WRITE_PATHS"`     // writable besides the workspace
+	AllowNetwork  bool     `json:"allow_network"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_ALLOW_NETWORK"`   // sockets, including Unix sockets, are blocked otherwise
+	MaxMemoryMB   int      `json:"max_memory_mb"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_MEMORY_MB"`   // address space per process; 0 means unlimited
+	MaxCPUSeconds int      `json:"max_cpu_seconds" env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_CPU_SECONDS"` // CPU time per process; 0 means unlimited
+	MaxProcesses  int      `json:"max_processes"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_PROCESSES"`   // counts all processes of the user; 0 means unlimited
+}
+
+// ApprovalConfig pauses matching tool calls until a human approves them from
+// the chat the turn came from.
+type ApprovalConfig struct {
+	Enabled        bool           `json:"enabled"         env:"PICOCLAW_TOOLS_APPROVAL_ENABLED"`
+	TimeoutSeconds int            `json:"timeout_seconds" env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_SECONDS"` // 0 means use default (120s); unanswered requests are denied
+	Approvers      []string       `json:"approvers"       env:"PICOCLAW_TOOLS_APPROVAL_APPROVERS"`       // Sender IDs allowed to approve; empty means the sender of the turn
+	Rules          []ApprovalRule `json:"rules"`
+}
+
+// ApprovalRule selects tool calls that need approval. A rule without Arg
+// matches every call to Tool; otherwise the string value of argument Arg must
+// match Match (if set) and must not match NotMatch (if set).
+type ApprovalRule struct {
+	Tool     string `json:"tool"`
+	Arg      string `json:"arg,omitempty"`
+	Match    string `json:"match,omitempty"`
+	NotMatch string `json:"not_match,omitempty"`
+}
+
+// Validate checks that every rule names a tool and has valid patterns.
+func (c *ApprovalConfig) Validate() error {
+	for i, rule := range c.Rules {
+		if rule.Tool == "" {
+			return fmt.Errorf("approval rule %d: tool is required", i)
+		}
+		if rule.Arg == "" && (rule.Match != "" || rule.NotMatch != "") {
+			return fmt.Errorf("approval rule %d: match patterns require arg", i)
+		}
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			return fmt.Errorf("approval rule %d: invalid match pattern: %w", i, err)
+		}
+		if _, err := regexp.Compile(rule.NotMatch); err != nil {
+			return fmt.Errorf("approval rule %d: invalid not_match pattern: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MemorySearchConfig configures the memory_search tool. With an
+// EmbeddingModel, keyword hits are reranked by meaning using that model_list
+// entry's embeddings API.
+type MemorySearchConfig struct {
+	ToolConfig     `       envPrefix:"PICOCLAW_TOOLS_MEMORY_SEARCH_"`
+	EmbeddingModel string `json:"embedding_model" env:"PICOCLAW_TOOLS_MEMORY_SEARCH_EMBEDDING_MODEL"` // model_list entry, "" for keyword search only
+}
+
+// HTTPRequestConfig configures the http_request tool, which calls HTTP APIs
+// with credentials from Secrets. The model references a secret by name as
+// {{secret:NAME}} and never sees its value.
+type HTTPRequestConfig struct {
+	ToolConfig       `      envPrefix:"PICOCLAW_TOOLS_HTTP_REQUEST_"`
+	TimeoutSeconds   int   `json:"timeout_seconds"    env:"PICOCLAW_TOOLS_HTTP_REQUEST_TIMEOUT_SECONDS"`    // 0 means use default (30s)
+	MaxResponseBytes int64 `json:"max_response_bytes" env:"PICOCLAW_TOOLS_HTTP_REQUEST_MAX_RESPONSE_BYTES"` // 0 means use default (1MB)
+
+	Secrets map[string]HTTPSecretConfig `json:"secrets,omitempty"`
+}
+
+// HTTPSecretConfig is a credential for http_request. It is only sent to
+// Hosts: host names, "*.example.lan" for subdomains, or "*" for any host.
+// Listed hosts may be reached even on private addresses.
+type HTTPSecretConfig struct {
+	Value string   `json:"value"`
+	Hosts []string `json:"hosts"`
 }
 
 type SkillsToolsConfig struct {
@@ -619,12 +781,19 @@ type ToolsConfig struct {
 	Skills          SkillsToolsConfig  `json:"skills"`
 	MediaCleanup    MediaCleanupConfig `json:"media_cleanup"`
 	MCP             MCPConfig          `json:"mcp"`
+	Approval        ApprovalConfig     `json:"approval"`
+	HTTPRequest     HTTPRequestConfig  `json:"http_request"`
 	AppendFile      ToolConfig         `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
+	ApplyPatch      ToolConfig         `json:"apply_patch"                                              envPrefix:"PICOCLAW_TOOLS_APPLY_PATCH_"`
 	EditFile        ToolConfig         `json:"edit_file"                                                envPrefix:"PICOCLAW_TOOLS_EDIT_FILE_"`
+	FindFiles       ToolConfig         `json:"find_files"                                               envPrefix:"PICOCLAW_TOOLS_FIND_FILES_"`
 	FindSkills      ToolConfig         `json:"find_skills"                                              envPrefix:"PICOCLAW_TOOLS_FIND_SKILLS_"`
+	GrepFiles       ToolConfig         `json:"grep_files"                                               envPrefix:"PICOCLAW_TOOLS_GREP_FILES_"`
 	I2C             ToolConfig         `json:"i2c"                                                      envPrefix:"PICOCLAW_TOOLS_I2C_"`
 	InstallSkill    ToolConfig         `json:"install_skill"                                            envPrefix:"PICOCLAW_TOOLS_INSTALL_SKILL_"`
 	ListDir         ToolConfig         `json:"list_dir"                                                 envPrefix:"PICOCLAW_TOOLS_LIST_DIR_"`
+	MemorySearch    MemorySearchConfig `json:"memory_search"`
+	MemoryWrite     ToolConfig         `json:"memory_write"                                             envPrefix:"PICOCLAW_TOOLS_MEMORY_WRITE_"`
 	Message         ToolConfig         `json:"message"                                                  envPrefix:"PICOCLAW_TOOLS_MESSAGE_"`
 	ReadFile        ToolConfig         `json:"read_file"                                                envPrefix:"PICOCLAW_TOOLS_READ_FILE_"`
 	Spawn           ToolConfig         `json:"spawn"                                                    envPrefix:"PICOCLAW_TOOLS_SPAWN_"`
@@ -728,6 +897,13 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// An invalid approval rule must not let the agent run unguarded.
+	if cfg.Tools.Approval.Enabled {
+		if err := cfg.Tools.Approval.Validate(); err != nil {
+			return nil, fmt.Errorf("tools.approval: %w", err)
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -878,16 +1054,28 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.MediaCleanup.Enabled
 	case "append_file":
 		return t.AppendFile.Enabled
+	case "apply_patch":
+		return t.ApplyPatch.Enabled
 	case "edit_file":
 		return t.EditFile.Enabled
+	case "find_files":
+		return t.FindFiles.Enabled
 	case "find_skills":
 		return t.FindSkills.Enabled
+	case "grep_files":
+		return t.GrepFiles.Enabled
+	case "http_request":
+		return t.HTTPRequest.Enabled
 	case "i2c":
 		return t.I2C.Enabled
 	case "install_skill":
 		return t.InstallSkill.Enabled
 	case "list_dir":
 		return t.ListDir.Enabled
+	case "memory_search":
+		return t.MemorySearch.Enabled
+	case "memory_write":
+		return t.MemoryWrite.Enabled
 	case "message":
 		return t.Message.Enabled
 	case "read_file":
@@ -35,11 +35,19 @@ func DefaultConfig() *Config {
 				MaxToolIterations:         50,
 				SummarizeMessageThreshold: 20,
 				SummarizeTokenPercent:     75,
+				MaxConcurrentSessions:     4,
+				Streaming:                 true,
+				MaxParallelToolCalls:      4,
+				ToolTimeout:               300,
 			},
 		},
 		Bindings: []AgentBinding{},
 		Session: SessionConfig{
 			DMScope: "per-channel-peer",
+			Retention: SessionRetentionConfig{
+				Archive:         true,
+				IntervalMinutes: 60,
+			},
 		},
 		Channels: ChannelsConfig{
 			WhatsApp: WhatsAppConfig{
@@ -374,6 +382,10 @@ func DefaultConfig() *Config {
 					SearchEngine: "search_std",
 					MaxResults:   5,
 				},
+				Search: WebSearchConfig{
+					MergeProviders:  1,
+					CacheTTLSeconds: 600,
+				},
 			},
 			Cron: CronToolsConfig{
 				ToolConfig: ToolConfig{
@@ -387,6 +399,8 @@ func DefaultConfig() *Config {
 				},
 				EnableDenyPatterns: true,
 				TimeoutSeconds:     60,
+				MaxBackgroundJobs:  4,
+				BackgroundOutputKB: 64,
 			},
 			Skills: SkillsToolsConfig{
 				ToolConfig: ToolConfig{
@@ -410,15 +424,41 @@ func DefaultConfig() *Config {
 				},
 				Servers: map[string]MCPServerConfig{},
 			},
+			HTTPRequest: HTTPRequestConfig{
+				ToolConfig: ToolConfig{
+					Enabled: true,
+				},
+				TimeoutSeconds:   30,
+				MaxResponseBytes: 1024 * 1024,
+			},
+			Approval: ApprovalConfig{
+				Enabled:        false,
+				TimeoutSeconds: 120,
+				Rules: []ApprovalRule{
+					{Tool: "exec"},
+					{Tool: "i2c", Arg: "action", Match: "^write$"},
+					{Tool: "spi", Arg: "action", Match: "^transfer$"},
+					{Tool: "install_skill"},
+				},
+			},
 			AppendFile: ToolConfig{
 				Enabled: true,
 			},
+			ApplyPatch: ToolConfig{
+				Enabled: true,
+			},
 			EditFile: ToolConfig{
 				Enabled: true,
 			},
+			FindFiles: ToolConfig{
+				Enabled: true,
+			},
 			FindSkills: ToolConfig{
 				Enabled: true,
 			},
+			GrepFiles: ToolConfig{
+				Enabled: true,
+			},
 			I2C: ToolConfig{
 				Enabled: false, // Hardware tool - Linux only
 			},
@@ -428,6 +468,14 @@ func DefaultConfig() *Config {
 			ListDir: ToolConfig{
 				Enabled: true,
 			},
+			MemorySearch: MemorySearchConfig{
+				ToolConfig: ToolConfig{
+					Enabled: true,
+				},
+			},
+			MemoryWrite: ToolConfig{
+				Enabled: true,
+			},
 			Message: ToolConfig{
 				Enabled: true,
 			},
@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BM25 parameters: k1 controls term frequency saturation, b the length
+// normalization. These are the usual defaults.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Document is a chunk of text that can be recalled.
+type Document struct {
+	Source string // "memory/MEMORY.md", "memory/202601/20260115.md" or "session:<key>"
+	Text   string
+	Time   time.Time
+}
+
+// Hit is a document matched by a search, with its relevance score.
+type Hit struct {
+	Document
+	Score float64
+}
+
+// BM25Index is an in-memory inverted index ranking documents with Okapi
+// BM25. Documents are added and removed per source, so a changed source is
+// reindexed without rebuilding the rest.
+type BM25Index struct {
+	docs     []Document
+	lengths  []int                // term count per document, 0 once removed
+	postings map[string][]posting // term → documents containing it
+	sources  map[string][]int     // source → its documents
+	totalLen int
+	removed  int
+}
+
+type posting struct {
+	doc  int
+	freq int
+}
+
+// NewBM25Index creates an empty index.
+func NewBM25Index() *BM25Index {
+	return &BM25Index{
+		postings: make(map[string][]posting),
+		sources:  make(map[string][]int),
+	}
+}
+
+// Add indexes a document.
+func (ix *BM25Index) Add(doc Document) {
+	terms := tokenize(doc.Text)
+	if len(terms) == 0 {
+		return
+	}
+	id := len(ix.docs)
+	ix.docs = append(ix.docs, doc)
+	ix.sources[doc.Source] = append(ix.sources[doc.Source], id)
+	ix.lengths = append(ix.lengths, len(terms))
+	ix.totalLen += len(terms)
+
+	freqs := make(map[string]int)
+	for _, t := range terms {
+		freqs[t]++
+	}
+	for t, f := range freqs {
+		ix.postings[t] = append(ix.postings[t], posting{doc: id, freq: f})
+	}
+}
+
+// Remove drops the documents of source from the index.
+func (ix *BM25Index) Remove(source string) {
+	ids := ix.sources[source]
+	if len(ids) == 0 {
+		return
+	}
+	delete(ix.sources, source)
+	for _, id := range ids {
+		seen := make(map[string]bool)
+		for _, t := range tokenize(ix.docs[id].Text) {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			plist := ix.postings[t]
+			for i, p := range plist {
+				if p.doc == id {
+					plist = append(plist[:i], plist[i+1:]...)
+					break
+				}
+			}
+			if len(plist) == 0 {
+				delete(ix.postings, t)
+			} else {
+				ix.postings[t] = plist
+			}
+		}
+		ix.totalLen -= ix.lengths[id]
+		ix.docs[id] = Document{}
+		ix.lengths[id] = 0
+		ix.removed++
+	}
+
+	// Reclaim the slots of removed documents once they are the majority.
+	if ix.removed > len(ix.docs)/2 {
+		docs := make([]Document, 0, len(ix.docs)-ix.removed)
+		for id, doc := range ix.docs {
+			if ix.lengths[id] > 0 {
+				docs = append(docs, doc)
+			}
+		}
+		*ix = *NewBM25Index()
+		for _, doc := range docs {
+			ix.Add(doc)
+		}
+	}
+}
+
+// Len returns the number of indexed documents.
+func (ix *BM25Index) Len() int {
+	return len(ix.docs) - ix.removed
+}
+
+// Search returns up to limit documents matching any term of query, best
+// first. Ties go to the more recent document.
+func (ix *BM25Index) Search(query string, limit int) []Hit {
+	if ix.Len() == 0 {
+		return nil
+	}
+
+	n := float64(ix.Len())
+	avgLen := float64(ix.totalLen) / n
+	scores := make(map[int]float64)
+
+	seen := make(map[string]bool)
+	for _, t := range tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		plist := ix.postings[t]
+		if len(plist) == 0 {
+			continue
+		}
+		df := float64(len(plist))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range plist {
+			tf := float64(p.freq)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(ix.lengths[p.doc])/avgLen)
+			scores[p.doc] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{Document: ix.docs[id], Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Time.After(hits[j].Time)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// stopWords are frequent English words that carry no meaning for recall.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "did": true, "do": true, "for": true, "from": true,
+	"has": true, "have": true, "i": true, "in": true, "is": true, "it": true,
+	"me": true, "my": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "we": true, "what": true,
+	"when": true, "where": true, "which": true, "who": true, "with": true,
+	"you": true, "your": true,
+}
+
+// tokenize lowercases text and splits it into letter/digit runs, dropping
+// stop words. Each Han, Hiragana, Katakana or Hangul character is its own
+// term, since those scripts do not separate words with spaces.
+func tokenize(text string) []string {
+	var terms []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			if t := cur.String(); !stopWords[t] {
+				terms = append(terms, t)
+			}
+			cur.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			cur.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
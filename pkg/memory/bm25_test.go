@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("What is Alice's favourite café? 猫")
+	want := []string{"alice", "s", "favourite", "café", "猫"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize() = %q, want %q", got, want)
+	}
+}
+
+func TestBM25Index_Search(t *testing.T) {
+	ix := NewBM25Index()
+	ix.Add(Document{Source: "a", Text: "The router password is stored in the garage notebook"})
+	ix.Add(Document{Source: "b", Text: "Alice likes green tea; Bob prefers coffee"})
+	ix.Add(Document{Source: "c", Text: "Coffee machine descaling is due in March, coffee coffee"})
+	ix.Add(Document{Source: "empty", Text: "the of and"})
+
+	if ix.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3 (stop-word-only documents are skipped)", ix.Len())
+	}
+
+	hits := ix.Search("coffee", 10)
+	if len(hits) != 2 || hits[0].Source != "c" || hits[1].Source != "b" {
+		t.Fatalf("Search(coffee) = %+v", hits)
+	}
+
+	hits = ix.Search("what did Bob drink, coffee or tea?", 1)
+	if len(hits) != 1 || hits[0].Source != "b" {
+		t.Errorf("Search(bob coffee tea) = %+v, want b first", hits)
+	}
+
+	if hits := ix.Search("unrelated", 10); len(hits) != 0 {
+		t.Errorf("Search(unrelated) = %+v, want none", hits)
+	}
+}
+
+func TestBM25Index_Remove(t *testing.T) {
+	ix := NewBM25Index()
+	ix.Add(Document{Source: "a", Text: "coffee beans from Kenya"})
+	ix.Add(Document{Source: "b", Text: "coffee grinder settings"})
+	ix.Add(Document{Source: "b", Text: "descale the coffee machine"})
+	ix.Add(Document{Source: "c", Text: "green tea"})
+
+	ix.Remove("b")
+	if ix.Len() != 2 {
+		t.Fatalf("Len() after Remove = %d, want 2", ix.Len())
+	}
+	hits := ix.Search("coffee", 10)
+	if len(hits) != 1 || hits[0].Source != "a" {
+		t.Fatalf("Search(coffee) after Remove = %+v, want only a", hits)
+	}
+
+	// Removing most documents compacts the index; it keeps working.
+	ix.Remove("a")
+	ix.Add(Document{Source: "b", Text: "new coffee grinder"})
+	if ix.Len() != 2 || len(ix.docs) != 2 {
+		t.Fatalf("Len() = %d with %d slots, want 2 compacted", ix.Len(), len(ix.docs))
+	}
+	if hits := ix.Search("coffee", 10); len(hits) != 1 || hits[0].Source != "b" {
+		t.Errorf("Search(coffee) after compaction = %+v, want b", hits)
+	}
+	if hits := ix.Search("tea", 10); len(hits) != 1 || hits[0].Source != "c" {
+		t.Errorf("Search(tea) after compaction = %+v, want c", hits)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +37,7 @@ const (
 type sessionMeta struct {
 	Key       string    `json:"key"`
 	Summary   string    `json:"summary"`
+	Model     string    `json:"model,omitempty"`
 	Skip      int       `json:"skip"`
 	Count     int       `json:"count"`
 	CreatedAt time.Time `json:"created_at"`
@@ -324,6 +326,41 @@ func (s *JSONLStore) SetSummary(
 	return s.writeMeta(sessionKey, meta)
 }
 
+func (s *JSONLStore) GetModel(
+	_ context.Context, sessionKey string,
+) (string, error) {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return "", err
+	}
+	return meta.Model, nil
+}
+
+func (s *JSONLStore) SetModel(
+	_ context.Context, sessionKey, model string,
+) error {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = now
+	}
+	meta.Model = model
+	meta.UpdatedAt = now
+
+	return s.writeMeta(sessionKey, meta)
+}
+
 func (s *JSONLStore) TruncateHistory(
 	_ context.Context, sessionKey string, keepLast int,
 ) error {
@@ -393,6 +430,61 @@ func (s *JSONLStore) SetHistory(
 	return s.rewriteJSONL(sessionKey, history)
 }
 
+// ListSessions reads the metadata file of every session in the store
+// directory. Message counts come from the metadata and may be off by the
+// lines of an interrupted append.
+func (s *JSONLStore) ListSessions(_ context.Context) ([]SessionInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("memory: read store dir: %w", err)
+	}
+
+	var sessions []SessionInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".meta.json") {
+			continue
+		}
+		data, readErr := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if readErr != nil {
+			continue
+		}
+		var meta sessionMeta
+		if json.Unmarshal(data, &meta) != nil || meta.Key == "" {
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			Key:       meta.Key,
+			Summary:   meta.Summary,
+			Model:     meta.Model,
+			Messages:  max(meta.Count-meta.Skip, 0),
+			CreatedAt: meta.CreatedAt,
+			UpdatedAt: meta.UpdatedAt,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession removes the JSONL and metadata files of a session. The
+// metadata goes first, so an interrupted delete leaves no listed session
+// behind.
+func (s *JSONLStore) DeleteSession(
+	_ context.Context, sessionKey string,
+) error {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	for _, path := range []string{s.metaPath(sessionKey), s.jsonlPath(sessionKey)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("memory: delete session: %w", err)
+		}
+	}
+	return nil
+}
+
 // Compact physically rewrites the JSONL file, dropping all logically
 // skipped lines. This reclaims disk space that accumulates after
 // repeated TruncateHistory calls.
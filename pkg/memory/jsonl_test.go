@@ -833,3 +833,51 @@ func BenchmarkGetHistory_1000(b *testing.B) {
 		_, _ = store.GetHistory(ctx, "bench")
 	}
 }
+
+func TestListSessions(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	store.AddMessage(ctx, "telegram:1", "user", "a")
+	store.AddMessage(ctx, "telegram:1", "assistant", "b")
+	store.AddMessage(ctx, "telegram:1", "user", "c")
+	store.TruncateHistory(ctx, "telegram:1", 1)
+	store.SetSummary(ctx, "discord:2", "only a summary")
+
+	sessions, err := store.ListSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	if sessions[0].Key != "discord:2" || sessions[0].Summary != "only a summary" {
+		t.Errorf("sessions[0] = %+v, want the most recently updated first", sessions[0])
+	}
+	if sessions[1].Key != "telegram:1" || sessions[1].Messages != 1 {
+		t.Errorf("sessions[1] = %+v", sessions[1])
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	store.AddMessage(ctx, "telegram:1", "user", "a")
+	store.AddMessage(ctx, "discord:2", "user", "b")
+
+	if err := store.DeleteSession(ctx, "telegram:1"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if err := store.DeleteSession(ctx, "missing"); err != nil {
+		t.Fatalf("DeleteSession of missing session: %v", err)
+	}
+
+	sessions, _ := store.ListSessions(ctx)
+	if len(sessions) != 1 || sessions[0].Key != "discord:2" {
+		t.Fatalf("sessions after delete = %+v", sessions)
+	}
+	if history, _ := store.GetHistory(ctx, "telegram:1"); len(history) != 0 {
+		t.Errorf("deleted history still readable: %+v", history)
+	}
+}
@@ -18,6 +18,7 @@ type jsonSession struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
+	Model    string              `json:"model,omitempty"`
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
 }
@@ -48,8 +49,9 @@ func MigrateFromJSON(
 		if !strings.HasSuffix(name, ".json") {
 			continue
 		}
-		// Skip already-migrated files.
-		if strings.HasSuffix(name, ".migrated") {
+		// Skip the store's own metadata files, which live in the
+		// same directory when the store is rooted at sessionsDir.
+		if strings.HasSuffix(name, ".meta.json") {
 			continue
 		}
 
@@ -95,6 +97,15 @@ func MigrateFromJSON(
 			}
 		}
 
+		if sess.Model != "" {
+			if modelErr := store.SetModel(ctx, key, sess.Model); modelErr != nil {
+				return migrated, fmt.Errorf(
+					"memory: migrate %s: set model: %w",
+					name, modelErr,
+				)
+			}
+		}
+
 		// Rename to .migrated as backup (not delete).
 		renameErr := os.Rename(srcPath, srcPath+".migrated")
 		if renameErr != nil {
@@ -382,3 +382,50 @@ func TestMigrateFromJSON_NonexistentDir(t *testing.T) {
 		t.Errorf("expected 0, got %d", count)
 	}
 }
+
+func TestMigrateFromJSON_StoreInSessionsDir(t *testing.T) {
+	sessionsDir := t.TempDir()
+	store, err := NewJSONLStore(sessionsDir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	ctx := context.Background()
+
+	writeJSONSession(t, sessionsDir, "agent_main_main.json", jsonSession{
+		Key:      "agent:main:main",
+		Messages: []providers.Message{{Role: "user", Content: "hello"}},
+		Model:    "fast",
+	})
+
+	if _, err = MigrateFromJSON(ctx, sessionsDir, store); err != nil {
+		t.Fatalf("first migration: %v", err)
+	}
+	if err = store.AddMessage(ctx, "agent:main:main", "assistant", "hi"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+
+	// A restart migrates again; the store's .meta.json files must not be
+	// mistaken for legacy sessions.
+	count, err := MigrateFromJSON(ctx, sessionsDir, store)
+	if err != nil {
+		t.Fatalf("second migration: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected 0 migrated on restart, got %d", count)
+	}
+
+	history, err := store.GetHistory(ctx, "agent:main:main")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(history))
+	}
+	model, err := store.GetModel(ctx, "agent:main:main")
+	if err != nil {
+		t.Fatalf("GetModel: %v", err)
+	}
+	if model != "fast" {
+		t.Errorf("model = %q, want %q", model, "fast")
+	}
+}
@@ -0,0 +1,461 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// maxChunkChars bounds the size of a recalled chunk of notes, so a hit
+// returns the relevant paragraph rather than the whole file.
+const maxChunkChars = 800
+
+// Only the most recent sessions, and the most recent messages of each, are
+// indexed, which keeps the index to a few MB however long the agent runs.
+const (
+	maxRecallSessions     = 50
+	maxRecallSessionBytes = 32 * 1024
+)
+
+// Embedder turns texts into embedding vectors. With one set, recall
+// reranks keyword hits by meaning.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Recall searches an agent's long-term memory: MEMORY.md, the daily notes
+// under memory/YYYYMM/, the recent messages of the most recent sessions and
+// the notes summarizing expired sessions. Before a search, the sources that
+// changed since the last one are reindexed. Person profiles under
+// memory/people/ are not indexed, so they only reach the prompt of the
+// person they describe, and sessions and their notes are only recalled for
+// the person who had them (see Scope).
+type Recall struct {
+	memoryDir string
+	sessions  Store // nil when sessions are not searchable
+
+	mu       sync.Mutex
+	embedder Embedder
+	index    *BM25Index
+	stamps   map[string]string // change stamp per indexed note file or session
+
+	peopleMu sync.Mutex
+	people   map[string][]string // session key → people who took part, nil until loaded
+}
+
+// Scope is who a search is for. Notes are shared, but a session or its
+// conversation note is only returned in the session's own turns and to the
+// one person who took part in it, so nobody recalls someone else's
+// conversation.
+type Scope struct {
+	SessionKey string // session of the turn
+	Person     string // profile key of the person asking, "" for none
+}
+
+// NewRecall creates a Recall over the notes in memoryDir and the sessions
+// in store, which may be nil.
+func NewRecall(memoryDir string, sessions Store) *Recall {
+	return &Recall{memoryDir: memoryDir, sessions: sessions}
+}
+
+// conversationNotesDir holds the notes of expired sessions, one per session.
+const conversationNotesDir = "conversations"
+
+// ConversationNotePath returns the note that summarizes the session with
+// sessionKey once it has expired.
+func ConversationNotePath(memoryDir, sessionKey string) string {
+	return filepath.Join(memoryDir, conversationNotesDir, url.QueryEscape(sessionKey)+".md")
+}
+
+// SetEmbedder enables semantic reranking of keyword hits.
+func (r *Recall) SetEmbedder(e Embedder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.embedder = e
+}
+
+// Search returns up to limit chunks of memory relevant to query that scope
+// may see.
+func (r *Recall) Search(ctx context.Context, scope Scope, query string, limit int) ([]Hit, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	visible, err := r.visibleSessions(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if err := r.refresh(ctx); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	var candidates []Hit
+	for _, h := range r.index.Search(query, 0) {
+		if key, ok := sessionOf(h.Source); !ok || visible(key) {
+			candidates = append(candidates, h)
+		}
+	}
+	embedder := r.embedder
+	r.mu.Unlock()
+
+	if embedder == nil {
+		if len(candidates) > limit {
+			candidates = candidates[:limit]
+		}
+		return candidates, nil
+	}
+
+	// Rerank a wider set of keyword candidates by embedding similarity.
+	if len(candidates) > limit*4 {
+		candidates = candidates[:limit*4]
+	}
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+	reranked, err := rerank(ctx, embedder, query, candidates)
+	if err != nil {
+		// Keyword results are still useful without the embeddings.
+		reranked = candidates
+	}
+	if len(reranked) > limit {
+		reranked = reranked[:limit]
+	}
+	return reranked, nil
+}
+
+// AddSessionPerson records that person took part in the session.
+func (r *Recall) AddSessionPerson(sessionKey, person string) error {
+	if sessionKey == "" || person == "" {
+		return nil
+	}
+	r.peopleMu.Lock()
+	defer r.peopleMu.Unlock()
+	if err := r.loadPeople(); err != nil {
+		return err
+	}
+	if slices.Contains(r.people[sessionKey], person) {
+		return nil
+	}
+	r.people[sessionKey] = append(r.people[sessionKey], person)
+
+	data, err := json.MarshalIndent(r.people, "", "  ")
+	if err != nil {
+		return fmt.Errorf("memory: marshal session people: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.peopleFile()), 0o755); err != nil {
+		return fmt.Errorf("memory: create people directory: %w", err)
+	}
+	return fileutil.WriteFileAtomic(r.peopleFile(), data, 0o600)
+}
+
+// SessionPerson returns the person a session belongs to: the only person
+// who took part in it, or "" if nobody or several people did.
+func (r *Recall) SessionPerson(sessionKey string) (string, error) {
+	r.peopleMu.Lock()
+	defer r.peopleMu.Unlock()
+	if err := r.loadPeople(); err != nil {
+		return "", err
+	}
+	if people := r.people[sessionKey]; len(people) == 1 {
+		return people[0], nil
+	}
+	return "", nil
+}
+
+// visibleSessions returns whether scope may recall the session with a key.
+func (r *Recall) visibleSessions(scope Scope) (func(key string) bool, error) {
+	r.peopleMu.Lock()
+	defer r.peopleMu.Unlock()
+	if err := r.loadPeople(); err != nil {
+		return nil, err
+	}
+	var own map[string]bool
+	if scope.Person != "" {
+		own = make(map[string]bool)
+		for key, people := range r.people {
+			if len(people) == 1 && people[0] == scope.Person {
+				own[key] = true
+			}
+		}
+	}
+	return func(key string) bool {
+		return key == scope.SessionKey || own[key]
+	}, nil
+}
+
+// sessionOf returns the key of the session a source belongs to.
+func sessionOf(source string) (string, bool) {
+	if key, ok := strings.CutPrefix(source, "session:"); ok {
+		return key, true
+	}
+	return strings.CutPrefix(source, "conversation:")
+}
+
+// peopleFile is where the people of each session are kept, next to the
+// profiles and likewise never indexed.
+func (r *Recall) peopleFile() string {
+	return filepath.Join(r.memoryDir, "people", "sessions.json")
+}
+
+// loadPeople reads the people of each session on first use. r.peopleMu
+// must be held.
+func (r *Recall) loadPeople() error {
+	if r.people != nil {
+		return nil
+	}
+	people := make(map[string][]string)
+	data, err := os.ReadFile(r.peopleFile())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("memory: read session people: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &people); err != nil {
+			return fmt.Errorf("memory: parse session people: %w", err)
+		}
+	}
+	r.people = people
+	return nil
+}
+
+// refresh reindexes the notes and sessions that changed since the last
+// search and drops the ones that are gone. r.mu must be held.
+func (r *Recall) refresh(ctx context.Context) error {
+	var sessions []SessionInfo
+	if r.sessions != nil {
+		var err error
+		if sessions, err = r.sessions.ListSessions(ctx); err != nil {
+			return fmt.Errorf("memory: list sessions: %w", err)
+		}
+		if len(sessions) > maxRecallSessions {
+			sessions = sessions[:maxRecallSessions]
+		}
+	}
+
+	if r.index == nil {
+		r.index = NewBM25Index()
+		r.stamps = make(map[string]string)
+	}
+	current := make(map[string]bool)
+	update := func(name, stamp string, read func() []Document) {
+		current[name] = true
+		if old, ok := r.stamps[name]; ok && old == stamp {
+			return
+		}
+		r.index.Remove(name)
+		for _, doc := range read() {
+			r.index.Add(doc)
+		}
+		r.stamps[name] = stamp
+	}
+
+	for _, f := range r.noteFiles() {
+		name := f.source
+		if name == "" {
+			rel, _ := filepath.Rel(filepath.Dir(r.memoryDir), f.path)
+			name = filepath.ToSlash(rel)
+		}
+		update(name, fmt.Sprintf("%d %d", f.size, f.modTime.UnixNano()), func() []Document {
+			data, err := os.ReadFile(f.path)
+			if err != nil {
+				return nil
+			}
+			var docs []Document
+			for _, chunk := range chunkMarkdown(string(data)) {
+				docs = append(docs, Document{Source: name, Text: chunk, Time: f.modTime})
+			}
+			return docs
+		})
+	}
+	for _, s := range sessions {
+		update("session:"+s.Key, fmt.Sprintf("%d %d", s.Messages, s.UpdatedAt.UnixNano()), func() []Document {
+			return r.sessionDocs(ctx, s)
+		})
+	}
+
+	for name := range r.stamps {
+		if !current[name] {
+			r.index.Remove(name)
+			delete(r.stamps, name)
+		}
+	}
+	return nil
+}
+
+// sessionDocs turns the summary and the most recent user and assistant
+// messages of a session, up to maxRecallSessionBytes, into documents.
+func (r *Recall) sessionDocs(ctx context.Context, s SessionInfo) []Document {
+	history, err := r.sessions.GetHistory(ctx, s.Key)
+	if err != nil {
+		return nil
+	}
+	source := "session:" + s.Key
+	var docs []Document
+	if s.Summary != "" {
+		docs = append(docs, Document{Source: source, Text: "summary: " + s.Summary, Time: s.UpdatedAt})
+	}
+	budget := maxRecallSessionBytes
+	first := len(history)
+	for first > 0 && budget > 0 {
+		first--
+		if m := history[first]; m.Role == "user" || m.Role == "assistant" {
+			budget -= len(m.Content)
+		}
+	}
+	for _, m := range history[first:] {
+		if (m.Role != "user" && m.Role != "assistant") || strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		docs = append(docs, Document{Source: source, Text: m.Role + ": " + m.Content, Time: s.UpdatedAt})
+	}
+	return docs
+}
+
+type noteFile struct {
+	path    string
+	source  string // "conversation:<session key>" for conversation notes
+	size    int64
+	modTime time.Time
+}
+
+// noteFiles lists MEMORY.md, the daily notes and the conversation notes,
+// sorted by path.
+func (r *Recall) noteFiles() []noteFile {
+	var files []noteFile
+	peopleDir := filepath.Join(r.memoryDir, "people")
+	conversationsDir := filepath.Join(r.memoryDir, conversationNotesDir)
+	filepath.WalkDir(r.memoryDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path == peopleDir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		f := noteFile{path: path}
+		if filepath.Dir(path) == conversationsDir {
+			key, err := url.QueryUnescape(strings.TrimSuffix(d.Name(), ".md"))
+			if err != nil || key == "" {
+				return nil
+			}
+			f.source = "conversation:" + key
+		} else if strings.HasPrefix(path, conversationsDir+string(filepath.Separator)) {
+			return nil // may mix sessions, so it is never shared
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		f.size, f.modTime = info.Size(), info.ModTime()
+		files = append(files, f)
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files
+}
+
+// chunkMarkdown splits notes into paragraphs, keeping each heading with the
+// text under it and splitting paragraphs longer than maxChunkChars.
+func chunkMarkdown(text string) []string {
+	var chunks []string
+	var heading string
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if strings.HasPrefix(para, "#") && !strings.Contains(para, "\n") {
+			heading = para
+			continue
+		}
+		if heading != "" {
+			para = heading + "\n" + para
+		}
+		for utf8.RuneCountInString(para) > maxChunkChars {
+			cut := splitPoint(para, maxChunkChars)
+			chunks = append(chunks, strings.TrimSpace(para[:cut]))
+			para = strings.TrimSpace(para[cut:])
+		}
+		if para != "" {
+			chunks = append(chunks, para)
+		}
+	}
+	if len(chunks) == 0 && heading != "" {
+		chunks = append(chunks, heading)
+	}
+	return chunks
+}
+
+// splitPoint returns a byte offset at most limit runes into s, preferring
+// the last line break or space before it.
+func splitPoint(s string, limit int) int {
+	end := len(s)
+	for i := range s {
+		if limit == 0 {
+			end = i
+			break
+		}
+		limit--
+	}
+	if i := strings.LastIndex(s[:end], "\n"); i > end/2 {
+		return i
+	}
+	if i := strings.LastIndex(s[:end], " "); i > end/2 {
+		return i
+	}
+	return end
+}
+
+// rerank orders hits by an equal blend of normalized keyword score and
+// cosine similarity to the query embedding.
+func rerank(ctx context.Context, e Embedder, query string, hits []Hit) ([]Hit, error) {
+	texts := make([]string, 0, len(hits)+1)
+	texts = append(texts, query)
+	for _, h := range hits {
+		texts = append(texts, h.Text)
+	}
+	vectors, err := e.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("memory: embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+
+	top := hits[0].Score
+	out := make([]Hit, len(hits))
+	for i, h := range hits {
+		h.Score = 0.5*h.Score/top + 0.5*cosine(vectors[0], vectors[i+1])
+		out[i] = h
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
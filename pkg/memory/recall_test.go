@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRecall_SearchesNotesAndSessions(t *testing.T) {
+	workspace := t.TempDir()
+	memoryDir := filepath.Join(workspace, "memory")
+	os.MkdirAll(filepath.Join(memoryDir, "202601"), 0o755)
+	os.WriteFile(filepath.Join(memoryDir, "MEMORY.md"),
+		[]byte("# People\n\nAlice is allergic to peanuts.\n\n# Home\n\nThe wifi password is on the fridge."), 0o644)
+	os.WriteFile(filepath.Join(memoryDir, "202601", "20260105.md"),
+		[]byte("# 2026-01-05\n\nBooked the dentist for Friday."), 0o644)
+
+	store := newTestStore(t)
+	ctx := context.Background()
+	store.AddMessage(ctx, "telegram:1", "user", "remind me that the car needs new tyres")
+	store.AddMessage(ctx, "telegram:1", "tool", "tyres tyres tyres")
+
+	r := NewRecall(memoryDir, store)
+	if err := r.AddSessionPerson("telegram:1", "telegram:1"); err != nil {
+		t.Fatalf("AddSessionPerson: %v", err)
+	}
+
+	hits, err := r.Search(ctx, Scope{}, "peanuts allergy", 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Source != "memory/MEMORY.md" || !strings.HasPrefix(hits[0].Text, "# People\n") {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+
+	hits, _ = r.Search(ctx, Scope{}, "dentist", 5)
+	if len(hits) != 1 || hits[0].Source != "memory/202601/20260105.md" {
+		t.Fatalf("unexpected daily note hits: %+v", hits)
+	}
+
+	hits, _ = r.Search(ctx, Scope{Person: "telegram:1"}, "tyres", 5)
+	if len(hits) != 1 || hits[0].Source != "session:telegram:1" {
+		t.Fatalf("unexpected session hits (tool output must be skipped): %+v", hits)
+	}
+
+	// Person profiles are private to their person and never recalled.
+	os.MkdirAll(filepath.Join(memoryDir, "people"), 0o755)
+	os.WriteFile(filepath.Join(memoryDir, "people", "bob.md"), []byte("# bob\n\nBob's salary is private."), 0o644)
+	if hits, _ = r.Search(ctx, Scope{}, "salary", 5); len(hits) != 0 {
+		t.Errorf("profile indexed: %+v", hits)
+	}
+
+	// New notes are picked up without rebuilding by hand.
+	os.WriteFile(filepath.Join(memoryDir, "202601", "20260106.md"), []byte("Bought a bicycle."), 0o644)
+	if hits, _ = r.Search(ctx, Scope{}, "bicycle", 5); len(hits) != 1 {
+		t.Errorf("new note not indexed: %+v", hits)
+	}
+}
+
+func TestRecall_IndexesRecentSessionMessages(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	store.AddMessage(ctx, "s1", "user", "the boiler was serviced in spring")
+	store.AddMessage(ctx, "s1", "assistant", strings.Repeat("filler ", maxRecallSessionBytes/7+1))
+	store.AddMessage(ctx, "s1", "user", "the gutter needs cleaning")
+	store.AddMessage(ctx, "s2", "user", "book the piano tuner")
+
+	r := NewRecall(t.TempDir(), store)
+	scope := Scope{SessionKey: "s1"}
+	if hits, _ := r.Search(ctx, scope, "gutter", 5); len(hits) != 1 {
+		t.Errorf("recent message not indexed: %+v", hits)
+	}
+	if hits, _ := r.Search(ctx, scope, "boiler", 5); len(hits) != 0 {
+		t.Errorf("message beyond the size limit indexed: %+v", hits)
+	}
+
+	// Changed and deleted sessions are reindexed on the next search.
+	store.AddMessage(ctx, "s2", "assistant", "the piano tuner comes on Monday")
+	scope = Scope{SessionKey: "s2"}
+	if hits, _ := r.Search(ctx, scope, "monday", 5); len(hits) != 1 {
+		t.Errorf("new message not indexed: %+v", hits)
+	}
+	store.DeleteSession(ctx, "s2")
+	if hits, _ := r.Search(ctx, scope, "piano", 5); len(hits) != 0 {
+		t.Errorf("deleted session still indexed: %+v", hits)
+	}
+}
+
+func TestRecall_SessionsArePrivate(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	store.AddMessage(ctx, "dm:alice", "user", "my salary review is on Friday")
+	store.AddMessage(ctx, "dm:bob", "user", "my salary is paid monthly")
+	store.AddMessage(ctx, "group", "user", "salary talk in the group")
+
+	memoryDir := t.TempDir()
+	r := NewRecall(memoryDir, store)
+	r.AddSessionPerson("dm:alice", "alice")
+	r.AddSessionPerson("dm:bob", "bob")
+	r.AddSessionPerson("group", "alice")
+	r.AddSessionPerson("group", "bob")
+
+	bob := Scope{SessionKey: "dm:bob", Person: "bob"}
+	if got := sourcesOf(t, r, bob); !reflect.DeepEqual(got, []string{"session:dm:bob"}) {
+		t.Errorf("bob recalls %v, want only his own session", got)
+	}
+	if got := sourcesOf(t, r, Scope{SessionKey: "group", Person: "alice"}); !reflect.DeepEqual(got,
+		[]string{"session:dm:alice", "session:group"}) {
+		t.Errorf("alice in the group recalls %v, want her session and the group", got)
+	}
+	if got := sourcesOf(t, r, Scope{SessionKey: "cron"}); len(got) != 0 {
+		t.Errorf("turn without a person recalls %v, want nothing", got)
+	}
+
+	// The people of each session survive a restart.
+	restarted := NewRecall(memoryDir, store)
+	if got := sourcesOf(t, restarted, Scope{Person: "alice"}); !reflect.DeepEqual(got, []string{"session:dm:alice"}) {
+		t.Errorf("after restart alice recalls %v, want her session", got)
+	}
+}
+
+func TestRecall_ConversationNotesArePrivate(t *testing.T) {
+	memoryDir := filepath.Join(t.TempDir(), "memory")
+	writeNote := func(path, text string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeNote(ConversationNotePath(memoryDir, "group:1"), "the group discussed salary bands")
+	writeNote(filepath.Join(memoryDir, "conversations", "202601", "20260105.md"), "old salary note")
+	writeNote(filepath.Join(memoryDir, "MEMORY.md"), "salary is paid monthly")
+
+	r := NewRecall(memoryDir, nil)
+	if got := sourcesOf(t, r, Scope{SessionKey: "dm:bob", Person: "bob"}); !reflect.DeepEqual(got,
+		[]string{"memory/MEMORY.md"}) {
+		t.Errorf("bob recalls %v, want only shared notes", got)
+	}
+	if got := sourcesOf(t, r, Scope{SessionKey: "group:1", Person: "alice"}); !reflect.DeepEqual(got,
+		[]string{"conversation:group:1", "memory/MEMORY.md"}) {
+		t.Errorf("the group recalls %v, want its note and shared notes", got)
+	}
+}
+
+func sourcesOf(t *testing.T, r *Recall, scope Scope) []string {
+	t.Helper()
+	hits, err := r.Search(context.Background(), scope, "salary", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var out []string
+	for _, h := range hits {
+		out = append(out, h.Source)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// fakeEmbedder maps a text to the vector of the first keyword it contains.
+type fakeEmbedder struct {
+	keywords []string
+	vectors  [][]float32
+	err      error
+}
+
+func (f *fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = []float32{0, 1}
+		for k, kw := range f.keywords {
+			if strings.Contains(text, kw) {
+				out[i] = f.vectors[k]
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestRecall_EmbedderReranks(t *testing.T) {
+	memoryDir := t.TempDir()
+	os.WriteFile(filepath.Join(memoryDir, "MEMORY.md"),
+		[]byte("pet pet: the cat sleeps\n\npet: our dog Rex is a good boy"), 0o644)
+
+	r := NewRecall(memoryDir, nil)
+	ctx := context.Background()
+
+	hits, _ := r.Search(ctx, Scope{}, "pet", 1)
+	if len(hits) != 1 || !strings.Contains(hits[0].Text, "cat") {
+		t.Fatalf("keyword-only hits = %+v", hits)
+	}
+
+	r.SetEmbedder(&fakeEmbedder{
+		keywords: []string{"dog", "cat", "pet"},
+		vectors:  [][]float32{{1, 0}, {0, 1}, {1, 0}},
+	})
+	hits, _ = r.Search(ctx, Scope{}, "pet", 1)
+	if len(hits) != 1 || !strings.Contains(hits[0].Text, "dog") {
+		t.Errorf("reranked hits = %+v, want the dog note first", hits)
+	}
+
+	r.SetEmbedder(&fakeEmbedder{err: errors.New("no embeddings")})
+	if hits, err := r.Search(ctx, Scope{}, "pet", 2); err != nil || len(hits) != 2 {
+		t.Errorf("Search with failing embedder = (%+v, %v), want keyword hits", hits, err)
+	}
+}
+
+func TestChunkMarkdown_SplitsLongParagraphs(t *testing.T) {
+	long := strings.Repeat("word ", 400)
+	chunks := chunkMarkdown("# Title\n\n" + long)
+	if len(chunks) < 2 {
+		t.Fatalf("expected long paragraph to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if n := len([]rune(c)); n > maxChunkChars {
+			t.Errorf("chunk of %d runes exceeds %d", n, maxChunkChars)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// sqliteSchema creates the tables on first open. messages_fts is an
+// external-content FTS5 index over messages.content, kept in sync by
+// triggers so writers never touch it directly.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	key        TEXT PRIMARY KEY,
+	summary    TEXT NOT NULL DEFAULT '',
+	model      TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_key TEXT NOT NULL REFERENCES sessions(key) ON DELETE CASCADE,
+	role        TEXT NOT NULL,
+	content     TEXT NOT NULL,
+	data        TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS messages_session ON messages(session_key, id);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+	content, content='messages', content_rowid='id'
+);
+
+CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
+	INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
+	INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+`
+
+// SQLiteStore implements Store on an embedded SQLite database.
+//
+// Messages, summaries and session metadata live in one database file, so
+// every operation is a single transaction and truncation deletes rows
+// instead of leaving dead lines behind. Message content is indexed with
+// FTS5 for Search.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// SearchResult is a message matched by SQLiteStore.Search.
+type SearchResult struct {
+	SessionKey string
+	Role       string
+	Content    string
+	Snippet    string  // matched terms marked with [ ]
+	Score      float64 // BM25 rank; lower is a better match
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("memory: create directory: %w", err)
+	}
+
+	dsn := "file:" + path +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("memory: open sqlite: %w", err)
+	}
+	// A single connection serializes writers inside the process, which
+	// avoids SQLITE_BUSY and keeps memory use flat on small boards.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("memory: create schema: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// withTx runs fn in a transaction, committing if it returns nil.
+func (s *SQLiteStore) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("memory: begin: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("memory: commit: %w", err)
+	}
+	return nil
+}
+
+// touchSession creates the session row if needed and bumps updated_at.
+func touchSession(ctx context.Context, tx *sql.Tx, key string) error {
+	now := time.Now().UnixMilli()
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO sessions (key, created_at, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET updated_at = excluded.updated_at`,
+		key, now, now)
+	if err != nil {
+		return fmt.Errorf("memory: update session: %w", err)
+	}
+	return nil
+}
+
+func insertMessage(ctx context.Context, tx *sql.Tx, key string, msg providers.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("memory: marshal message: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO messages (session_key, role, content, data) VALUES (?, ?, ?, ?)`,
+		key, msg.Role, msg.Content, string(data))
+	if err != nil {
+		return fmt.Errorf("memory: insert message: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) AddMessage(
+	ctx context.Context, sessionKey, role, content string,
+) error {
+	return s.AddFullMessage(ctx, sessionKey, providers.Message{
+		Role:    role,
+		Content: content,
+	})
+}
+
+func (s *SQLiteStore) AddFullMessage(
+	ctx context.Context, sessionKey string, msg providers.Message,
+) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		return insertMessage(ctx, tx, sessionKey, msg)
+	})
+}
+
+func (s *SQLiteStore) GetHistory(
+	ctx context.Context, sessionKey string,
+) ([]providers.Message, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT data FROM messages WHERE session_key = ? ORDER BY id`, sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("memory: query history: %w", err)
+	}
+	defer rows.Close()
+
+	msgs := []providers.Message{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("memory: scan message: %w", err)
+		}
+		var msg providers.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("memory: decode message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("memory: query history: %w", err)
+	}
+	return msgs, nil
+}
+
+// sessionColumn reads one text column of a session row, "" if the session
+// does not exist.
+func (s *SQLiteStore) sessionColumn(ctx context.Context, column, sessionKey string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT `+column+` FROM sessions WHERE key = ?`, sessionKey).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("memory: read %s: %w", column, err)
+	}
+	return value, nil
+}
+
+// setSessionColumn writes one text column of a session row, creating the
+// session if needed.
+func (s *SQLiteStore) setSessionColumn(ctx context.Context, column, sessionKey, value string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`UPDATE sessions SET `+column+` = ? WHERE key = ?`, value, sessionKey)
+		if err != nil {
+			return fmt.Errorf("memory: write %s: %w", column, err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetSummary(
+	ctx context.Context, sessionKey string,
+) (string, error) {
+	return s.sessionColumn(ctx, "summary", sessionKey)
+}
+
+func (s *SQLiteStore) SetSummary(
+	ctx context.Context, sessionKey, summary string,
+) error {
+	return s.setSessionColumn(ctx, "summary", sessionKey, summary)
+}
+
+func (s *SQLiteStore) GetModel(
+	ctx context.Context, sessionKey string,
+) (string, error) {
+	return s.sessionColumn(ctx, "model", sessionKey)
+}
+
+func (s *SQLiteStore) SetModel(
+	ctx context.Context, sessionKey, model string,
+) error {
+	return s.setSessionColumn(ctx, "model", sessionKey, model)
+}
+
+func (s *SQLiteStore) TruncateHistory(
+	ctx context.Context, sessionKey string, keepLast int,
+) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		var err error
+		if keepLast <= 0 {
+			_, err = tx.ExecContext(ctx,
+				`DELETE FROM messages WHERE session_key = ?`, sessionKey)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				DELETE FROM messages WHERE session_key = ? AND id NOT IN (
+					SELECT id FROM messages WHERE session_key = ?
+					ORDER BY id DESC LIMIT ?
+				)`, sessionKey, sessionKey, keepLast)
+		}
+		if err != nil {
+			return fmt.Errorf("memory: truncate history: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) SetHistory(
+	ctx context.Context,
+	sessionKey string,
+	history []providers.Message,
+) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM messages WHERE session_key = ?`, sessionKey); err != nil {
+			return fmt.Errorf("memory: clear history: %w", err)
+		}
+		for _, msg := range history {
+			if err := insertMessage(ctx, tx, sessionKey, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.key, s.summary, s.model, COUNT(m.id), s.created_at, s.updated_at
+		FROM sessions s
+		LEFT JOIN messages m ON m.session_key = s.key
+		GROUP BY s.key
+		ORDER BY s.updated_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("memory: list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []SessionInfo
+	for rows.Next() {
+		var info SessionInfo
+		var created, updated int64
+		if err := rows.Scan(&info.Key, &info.Summary, &info.Model, &info.Messages, &created, &updated); err != nil {
+			return nil, fmt.Errorf("memory: scan session: %w", err)
+		}
+		info.CreatedAt = time.UnixMilli(created)
+		info.UpdatedAt = time.UnixMilli(updated)
+		sessions = append(sessions, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("memory: list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// DeleteSession removes a session. Its messages and their index entries
+// go with it through the foreign key cascade and the delete trigger.
+func (s *SQLiteStore) DeleteSession(ctx context.Context, sessionKey string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE key = ?`, sessionKey); err != nil {
+		return fmt.Errorf("memory: delete session: %w", err)
+	}
+	return nil
+}
+
+// Compact is a no-op: truncated messages are deleted, not skipped. SQLite
+// reuses the freed pages for later writes.
+func (s *SQLiteStore) Compact(_ context.Context, _ string) error {
+	return nil
+}
+
+// Search returns the messages whose content best matches query, across all
+// sessions, ranked by BM25. Each word of query must appear in a match;
+// FTS5 query syntax in query is treated as plain text.
+func (s *SQLiteStore) Search(
+	ctx context.Context, query string, limit int,
+) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.session_key, m.role, m.content,
+			snippet(messages_fts, 0, '[', ']', '…', 16),
+			bm25(messages_fts)
+		FROM messages_fts
+		JOIN messages m ON m.id = messages_fts.rowid
+		WHERE messages_fts MATCH ?
+		ORDER BY bm25(messages_fts)
+		LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("memory: search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.SessionKey, &r.Role, &r.Content, &r.Snippet, &r.Score); err != nil {
+			return nil, fmt.Errorf("memory: scan result: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("memory: search: %w", err)
+	}
+	return results, nil
+}
+
+// ftsQuery quotes every word of query as an FTS5 string so that operators
+// and punctuation typed by users cannot break the MATCH expression.
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
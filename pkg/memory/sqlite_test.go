@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore_Roundtrip(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	if err := store.AddMessage(ctx, "s1", "user", "hello"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+	msg := providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: &providers.FunctionCall{Name: "web_search", Arguments: `{"q":"go"}`},
+		}},
+	}
+	if err := store.AddFullMessage(ctx, "s1", msg); err != nil {
+		t.Fatalf("AddFullMessage: %v", err)
+	}
+
+	history, err := store.GetHistory(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(history))
+	}
+	if history[0].Content != "hello" {
+		t.Errorf("msg[0] = %+v", history[0])
+	}
+	if len(history[1].ToolCalls) != 1 || history[1].ToolCalls[0].Function.Name != "web_search" {
+		t.Errorf("msg[1] tool calls = %+v", history[1].ToolCalls)
+	}
+
+	empty, err := store.GetHistory(ctx, "missing")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", empty)
+	}
+}
+
+func TestSQLiteStore_SummaryAndModel(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	if s, err := store.GetSummary(ctx, "s1"); err != nil || s != "" {
+		t.Fatalf("GetSummary of missing session = (%q, %v)", s, err)
+	}
+	if err := store.SetSummary(ctx, "s1", "talked about Go"); err != nil {
+		t.Fatalf("SetSummary: %v", err)
+	}
+	if err := store.SetModel(ctx, "s1", "fast"); err != nil {
+		t.Fatalf("SetModel: %v", err)
+	}
+	if s, _ := store.GetSummary(ctx, "s1"); s != "talked about Go" {
+		t.Errorf("summary = %q", s)
+	}
+	if m, _ := store.GetModel(ctx, "s1"); m != "fast" {
+		t.Errorf("model = %q", m)
+	}
+
+	sessions, err := store.ListSessions(ctx)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Key != "s1" || sessions[0].Model != "fast" || sessions[0].Messages != 0 {
+		t.Errorf("ListSessions = %+v", sessions)
+	}
+}
+
+func TestSQLiteStore_TruncateAndSetHistory(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	for _, c := range []string{"one", "two", "three", "four"} {
+		if err := store.AddMessage(ctx, "s1", "user", c); err != nil {
+			t.Fatalf("AddMessage: %v", err)
+		}
+	}
+	if err := store.AddMessage(ctx, "s2", "user", "other"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+
+	if err := store.TruncateHistory(ctx, "s1", 2); err != nil {
+		t.Fatalf("TruncateHistory: %v", err)
+	}
+	history, _ := store.GetHistory(ctx, "s1")
+	if len(history) != 2 || history[0].Content != "three" || history[1].Content != "four" {
+		t.Fatalf("history after truncate = %+v", history)
+	}
+	if other, _ := store.GetHistory(ctx, "s2"); len(other) != 1 {
+		t.Errorf("truncate touched another session: %+v", other)
+	}
+
+	if err := store.SetHistory(ctx, "s1", []providers.Message{{Role: "user", Content: "replaced"}}); err != nil {
+		t.Fatalf("SetHistory: %v", err)
+	}
+	history, _ = store.GetHistory(ctx, "s1")
+	if len(history) != 1 || history[0].Content != "replaced" {
+		t.Fatalf("history after SetHistory = %+v", history)
+	}
+
+	if err := store.TruncateHistory(ctx, "s1", 0); err != nil {
+		t.Fatalf("TruncateHistory: %v", err)
+	}
+	if history, _ = store.GetHistory(ctx, "s1"); len(history) != 0 {
+		t.Errorf("expected empty history, got %+v", history)
+	}
+}
+
+func TestSQLiteStore_Search(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	store.AddMessage(ctx, "s1", "user", "my cat is called Miso")
+	store.AddMessage(ctx, "s1", "assistant", "Miso is a lovely name")
+	store.AddMessage(ctx, "s2", "user", "the build is failing on arm64")
+
+	results, err := store.Search(ctx, "miso cat", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].SessionKey != "s1" || results[0].Role != "user" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// Replaced messages leave the index.
+	store.SetHistory(ctx, "s2", nil)
+	if results, _ = store.Search(ctx, "arm64", 10); len(results) != 0 {
+		t.Errorf("deleted message still found: %+v", results)
+	}
+
+	// Deleted sessions leave the index too.
+	if err := store.DeleteSession(ctx, "s1"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if results, _ = store.Search(ctx, "miso", 10); len(results) != 0 {
+		t.Errorf("message of deleted session still found: %+v", results)
+	}
+	if sessions, _ := store.ListSessions(ctx); len(sessions) != 1 {
+		t.Errorf("sessions after delete = %+v", sessions)
+	}
+
+	// FTS syntax is treated as text.
+	if _, err := store.Search(ctx, `"miso" OR (NEAR`, 10); err != nil {
+		t.Errorf("Search with operators: %v", err)
+	}
+}
+
+func TestSQLiteStore_Migration(t *testing.T) {
+	sessionsDir := t.TempDir()
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	writeJSONSession(t, sessionsDir, "telegram_1.json", jsonSession{
+		Key:      "telegram:1",
+		Messages: []providers.Message{{Role: "user", Content: "hello"}},
+		Summary:  "greeting",
+	})
+	if count, err := MigrateFromJSON(ctx, sessionsDir, store); err != nil || count != 1 {
+		t.Fatalf("MigrateFromJSON = (%d, %v)", count, err)
+	}
+	if history, _ := store.GetHistory(ctx, "telegram:1"); len(history) != 1 {
+		t.Errorf("migrated history = %+v", history)
+	}
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
@@ -33,6 +34,22 @@ type Store interface {
 	// SetHistory replaces all messages in a session with the provided history.
 	SetHistory(ctx context.Context, sessionKey string, history []providers.Message) error
 
+	// GetModel returns the model override of a session, or an empty
+	// string if the session uses the agent's model.
+	GetModel(ctx context.Context, sessionKey string) (string, error)
+
+	// SetModel sets the model override of a session. An empty model
+	// clears the override.
+	SetModel(ctx context.Context, sessionKey, model string) error
+
+	// ListSessions returns every stored session, most recently updated
+	// first.
+	ListSessions(ctx context.Context) ([]SessionInfo, error)
+
+	// DeleteSession removes a session and all of its messages. Deleting a
+	// session that does not exist is not an error.
+	DeleteSession(ctx context.Context, sessionKey string) error
+
 	// Compact reclaims storage by physically removing logically truncated
 	// data. Backends that do not accumulate dead data may return nil.
 	Compact(ctx context.Context, sessionKey string) error
@@ -40,3 +57,13 @@ type Store interface {
 	// Close releases any resources held by the store.
 	Close() error
 }
+
+// SessionInfo describes a stored session without loading its messages.
+type SessionInfo struct {
+	Key       string
+	Summary   string
+	Model     string
+	Messages  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,303 @@
+package openai_compat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxStreamLineSize bounds a single SSE line. Tool-call argument chunks are
+// small, but some gateways batch large deltas into one event.
+const maxStreamLineSize = 1024 * 1024
+
+// mayRejectStreaming reports whether a streaming request failing with
+// status may have been rejected for asking to stream. Authentication and
+// rate limit errors would fail without streaming too.
+func mayRejectStreaming(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+// chatUnstreamed answers a ChatStream call with Chat, passing the whole
+// content to onDelta at once.
+func (p *Provider) chatUnstreamed(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	out, err := p.Chat(ctx, messages, tools, model, options)
+	if err == nil && out.Content != "" && onDelta != nil {
+		onDelta(out.Content)
+	}
+	return out, err
+}
+
+// streamChunk is one "data:" event of a chat completions stream.
+type streamChunk struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function *struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+				ExtraContent *struct {
+					Google *struct {
+						ThoughtSignature string `json:"thought_signature"`
+					} `json:"google"`
+				} `json:"extra_content"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *UsageInfo `json:"usage"`
+}
+
+// streamToolCall accumulates the fragments of a single tool call.
+type streamToolCall struct {
+	ID               string
+	Type             string
+	Name             string
+	Arguments        strings.Builder
+	ThoughtSignature string
+}
+
+// streamAccumulator assembles stream chunks into a complete response.
+type streamAccumulator struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	reasoningAlt strings.Builder
+	toolCalls    map[int]*streamToolCall
+	finishReason string
+	usage        *UsageInfo
+}
+
+func (a *streamAccumulator) add(chunk *streamChunk, onDelta func(string)) {
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		d := choice.Delta
+		if d.Content != "" {
+			a.content.WriteString(d.Content)
+			if onDelta != nil {
+				onDelta(d.Content)
+			}
+		}
+		a.reasoning.WriteString(d.ReasoningContent)
+		a.reasoningAlt.WriteString(d.Reasoning)
+		for _, tc := range d.ToolCalls {
+			if a.toolCalls == nil {
+				a.toolCalls = make(map[int]*streamToolCall)
+			}
+			acc, ok := a.toolCalls[tc.Index]
+			if !ok {
+				acc = &streamToolCall{}
+				a.toolCalls[tc.Index] = acc
+			}
+			if tc.ID != "" {
+				acc.ID = tc.ID
+			}
+			if tc.Type != "" {
+				acc.Type = tc.Type
+			}
+			if tc.Function != nil {
+				if tc.Function.Name != "" {
+					acc.Name = tc.Function.Name
+				}
+				acc.Arguments.WriteString(tc.Function.Arguments)
+			}
+			if tc.ExtraContent != nil && tc.ExtraContent.Google != nil &&
+				tc.ExtraContent.Google.ThoughtSignature != "" {
+				acc.ThoughtSignature = tc.ExtraContent.Google.ThoughtSignature
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			a.finishReason = *choice.FinishReason
+		}
+	}
+}
+
+// response renders the accumulated stream in the non-streaming wire format
+// and parses it with parseResponse, so both paths share one decoder.
+func (a *streamAccumulator) response() (*LLMResponse, error) {
+	type wireToolCall struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+		ExtraContent map[string]any `json:"extra_content,omitempty"`
+	}
+
+	indexes := make([]int, 0, len(a.toolCalls))
+	for idx := range a.toolCalls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	toolCalls := make([]wireToolCall, 0, len(indexes))
+	for _, idx := range indexes {
+		acc := a.toolCalls[idx]
+		wtc := wireToolCall{ID: acc.ID, Type: acc.Type}
+		wtc.Function.Name = acc.Name
+		wtc.Function.Arguments = acc.Arguments.String()
+		if acc.ThoughtSignature != "" {
+			wtc.ExtraContent = map[string]any{
+				"google": map[string]any{"thought_signature": acc.ThoughtSignature},
+			}
+		}
+		toolCalls = append(toolCalls, wtc)
+	}
+
+	finishReason := a.finishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"choices": []map[string]any{{
+			"message": map[string]any{
+				"content":           a.content.String(),
+				"reasoning_content": a.reasoning.String(),
+				"reasoning":         a.reasoningAlt.String(),
+				"tool_calls":        toolCalls,
+			},
+			"finish_reason": finishReason,
+		}},
+		"usage": a.usage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble streamed response: %w", err)
+	}
+	return parseResponse(body)
+}
+
+// ChatStream behaves like Chat but requests a server-sent event stream and
+// invokes onDelta with each content fragment as it arrives. The returned
+// response is fully assembled, including tool calls and usage.
+//
+// Some OpenAI-compatible servers reject "stream" or "stream_options" with
+// a client error. ChatStream then retries once with Chat, and if that
+// succeeds, uses Chat for every later call.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("API base not configured")
+	}
+	if p.streamUnsupported.Load() {
+		return p.chatUnstreamed(ctx, messages, tools, model, options, onDelta)
+	}
+
+	requestBody := p.buildRequestBody(messages, tools, model, options)
+	requestBody["stream"] = true
+	requestBody["stream_options"] = map[string]any{"include_usage": true}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/chat/completions", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		streamErr := fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+		if !mayRejectStreaming(resp.StatusCode) {
+			return nil, streamErr
+		}
+		out, err := p.chatUnstreamed(ctx, messages, tools, model, options, onDelta)
+		if err != nil {
+			// The request itself is at fault, not streaming.
+			return nil, streamErr
+		}
+		p.streamUnsupported.Store(true)
+		log.Printf("openai_compat: %s rejected a streaming request (status %d), using non-streaming requests",
+			p.apiBase, resp.StatusCode)
+		return out, nil
+	}
+
+	// Some OpenAI-compatible servers ignore "stream" and answer with a
+	// regular JSON body; fall back to the non-streaming decoder.
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		out, err := parseResponse(body)
+		if err == nil && out.Content != "" && onDelta != nil {
+			onDelta(out.Content)
+		}
+		return out, err
+	}
+
+	var acc streamAccumulator
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue // blank separators, comments, "event:" lines
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		acc.add(&chunk, onDelta)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return acc.response()
+}
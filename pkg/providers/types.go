@@ -37,6 +37,27 @@ type StatefulProvider interface {
 	Close()
 }
 
+// EmbeddingProvider is an optional interface for providers with an
+// embeddings API. Embed returns one vector per text.
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string, model string) ([][]float32, error)
+}
+
+// StreamingProvider is an optional interface for providers that can stream
+// partial output. ChatStream behaves like Chat but invokes onDelta with each
+// content fragment as it arrives; the returned response is fully assembled.
+type StreamingProvider interface {
+	LLMProvider
+	ChatStream(
+		ctx context.Context,
+		messages []Message,
+		tools []ToolDefinition,
+		model string,
+		options map[string]any,
+		onDelta func(delta string),
+	) (*LLMResponse, error)
+}
+
 // ThinkingCapable is an optional interface for providers that support
 // extended thinking (e.g. Anthropic). Used by the agent loop to warn
 // when thinking_level is configured but the active provider cannot use it.
@@ -144,6 +144,13 @@ func normalizeChannel(channel string) string {
 	return c
 }
 
+// ResolveLinkedIdentity returns the identity_links name peerID on channel is
+// linked to, or "" if it is not linked. peerID may be a raw platform ID or a
+// canonical "platform:id".
+func ResolveLinkedIdentity(identityLinks map[string][]string, channel, peerID string) string {
+	return resolveLinkedPeerID(identityLinks, channel, peerID)
+}
+
 func resolveLinkedPeerID(identityLinks map[string][]string, channel, peerID string) string {
 	if len(identityLinks) == 0 {
 		return ""
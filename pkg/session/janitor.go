@@ -0,0 +1,186 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// activeGrace protects sessions updated this recently from the janitor, so
+// it never trims or deletes a conversation that is still going on.
+const activeGrace = 10 * time.Minute
+
+// ExpireHook is called with a session the janitor is about to delete. An
+// error keeps the session until the next sweep.
+type ExpireHook func(ctx context.Context, info memory.SessionInfo, history []providers.Message) error
+
+// Janitor enforces a RetentionPolicy on a session store: it deletes expired
+// sessions and trims the oldest messages of sessions longer than
+// MaxMessages, optionally archiving them first.
+type Janitor struct {
+	store      memory.Store
+	policy     RetentionPolicy
+	archiveDir string // "" disables archiving
+	onExpire   ExpireHook
+	nowFunc    func() time.Time // for testing
+}
+
+// NewJanitor creates a Janitor for store. Sessions are archived below
+// archiveDir/YYYY-MM-DD as gzipped JSON unless archiveDir is empty.
+// onExpire may be nil.
+func NewJanitor(store memory.Store, policy RetentionPolicy, archiveDir string, onExpire ExpireHook) *Janitor {
+	return &Janitor{
+		store:      store,
+		policy:     policy,
+		archiveDir: archiveDir,
+		onExpire:   onExpire,
+		nowFunc:    time.Now,
+	}
+}
+
+// SweepResult counts what a sweep did.
+type SweepResult struct {
+	Deleted  int
+	Trimmed  int
+	Archived int
+}
+
+// Sweep applies the policy once. Failures on single sessions are logged and
+// the session is retried on the next sweep.
+func (j *Janitor) Sweep(ctx context.Context) (SweepResult, error) {
+	var result SweepResult
+	sessions, err := j.store.ListSessions(ctx)
+	if err != nil {
+		return result, fmt.Errorf("session: list sessions: %w", err)
+	}
+
+	now := j.nowFunc()
+	expired := make(map[string]bool)
+	for _, info := range j.policy.Expired(sessions, now) {
+		if now.Sub(info.UpdatedAt) < activeGrace {
+			continue
+		}
+		expired[info.Key] = true
+		if err := j.expire(ctx, info, &result); err != nil {
+			logger.WarnCF("session", "Failed to expire session",
+				map[string]any{"session_key": info.Key, "error": err.Error()})
+		}
+	}
+
+	if j.policy.MaxMessages <= 0 {
+		return result, nil
+	}
+	for _, info := range sessions {
+		if expired[info.Key] || info.Messages <= j.policy.MaxMessages || now.Sub(info.UpdatedAt) < activeGrace {
+			continue
+		}
+		if err := j.trim(ctx, info, &result); err != nil {
+			logger.WarnCF("session", "Failed to trim session",
+				map[string]any{"session_key": info.Key, "error": err.Error()})
+		}
+	}
+	return result, nil
+}
+
+func (j *Janitor) expire(ctx context.Context, info memory.SessionInfo, result *SweepResult) error {
+	history, err := j.store.GetHistory(ctx, info.Key)
+	if err != nil {
+		return err
+	}
+	if err := j.archive(info, history, result); err != nil {
+		return err
+	}
+	if j.onExpire != nil {
+		if err := j.onExpire(ctx, info, history); err != nil {
+			return err
+		}
+	}
+	if err := j.store.DeleteSession(ctx, info.Key); err != nil {
+		return err
+	}
+	result.Deleted++
+	return nil
+}
+
+// trim drops the oldest messages of a session so at most MaxMessages
+// remain, cutting at a user message so no tool call loses its result.
+func (j *Janitor) trim(ctx context.Context, info memory.SessionInfo, result *SweepResult) error {
+	history, err := j.store.GetHistory(ctx, info.Key)
+	if err != nil {
+		return err
+	}
+	cut := len(history) - j.policy.MaxMessages
+	if cut <= 0 {
+		return nil
+	}
+	for cut < len(history) && history[cut].Role != "user" {
+		cut++
+	}
+	if cut == len(history) {
+		return nil // no clean cut point yet
+	}
+	if err := j.archive(info, history, result); err != nil {
+		return err
+	}
+	if err := j.store.TruncateHistory(ctx, info.Key, len(history)-cut); err != nil {
+		return err
+	}
+	if err := j.store.Compact(ctx, info.Key); err != nil {
+		return err
+	}
+	result.Trimmed++
+	return nil
+}
+
+// archive writes a gzipped copy of a session to archiveDir/YYYY-MM-DD.
+func (j *Janitor) archive(info memory.SessionInfo, history []providers.Message, result *SweepResult) error {
+	if j.archiveDir == "" || (len(history) == 0 && info.Summary == "") {
+		return nil
+	}
+	filename, err := sessionFilename(info.Key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(Session{
+		Key:      info.Key,
+		Messages: history,
+		Summary:  info.Summary,
+		Model:    info.Model,
+		Created:  info.CreatedAt,
+		Updated:  info.UpdatedAt,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	now := j.nowFunc()
+	dir := filepath.Join(j.archiveDir, now.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d.json.gz", filename, now.UnixMilli()))
+	if err := fileutil.WriteFileAtomic(path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	result.Archived++
+	return nil
+}
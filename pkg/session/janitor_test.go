@@ -0,0 +1,151 @@
+package session
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newJanitorStore(t *testing.T) *memory.JSONLStore {
+	t.Helper()
+	store, err := memory.NewJSONLStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJSONLStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestJanitor_ExpiresAndArchives(t *testing.T) {
+	ctx := context.Background()
+	store := newJanitorStore(t)
+	store.AddMessage(ctx, "agent:main:telegram:direct:1", "user", "hello")
+	store.AddMessage(ctx, "agent:main:telegram:direct:1", "assistant", "hi")
+
+	archiveDir := t.TempDir()
+	var hooked []string
+	j := NewJanitor(store, RetentionPolicy{MaxAge: 24 * time.Hour}, archiveDir,
+		func(_ context.Context, info memory.SessionInfo, history []providers.Message) error {
+			hooked = append(hooked, info.Key)
+			if len(history) != 2 {
+				t.Errorf("hook got %d messages, want 2", len(history))
+			}
+			return nil
+		})
+
+	// Nothing is old enough yet.
+	if result, err := j.Sweep(ctx); err != nil || result != (SweepResult{}) {
+		t.Fatalf("Sweep() = %+v, %v; want no changes", result, err)
+	}
+
+	now := time.Now().Add(48 * time.Hour)
+	j.nowFunc = func() time.Time { return now }
+	result, err := j.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if result.Deleted != 1 || result.Archived != 1 {
+		t.Fatalf("Sweep() = %+v, want 1 deleted and archived", result)
+	}
+	if len(hooked) != 1 {
+		t.Errorf("expire hook called for %v", hooked)
+	}
+	if sessions, _ := store.ListSessions(ctx); len(sessions) != 0 {
+		t.Errorf("sessions after sweep = %+v", sessions)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(archiveDir, now.Format("2006-01-02"), "*.json.gz"))
+	if len(matches) != 1 {
+		t.Fatalf("archives = %v, want one gzipped file in a dated directory", matches)
+	}
+	f, err := os.Open(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive is not gzipped: %v", err)
+	}
+	var archived Session
+	if err := json.NewDecoder(zr).Decode(&archived); err != nil {
+		t.Fatalf("decode archive: %v", err)
+	}
+	if archived.Key != "agent:main:telegram:direct:1" || len(archived.Messages) != 2 {
+		t.Errorf("archived session = %+v", archived)
+	}
+}
+
+func TestJanitor_HookErrorKeepsSession(t *testing.T) {
+	ctx := context.Background()
+	store := newJanitorStore(t)
+	store.AddMessage(ctx, "s1", "user", "hello")
+
+	j := NewJanitor(store, RetentionPolicy{MaxAge: time.Hour}, "",
+		func(context.Context, memory.SessionInfo, []providers.Message) error {
+			return errors.New("model unavailable")
+		})
+	j.nowFunc = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if result, err := j.Sweep(ctx); err != nil || result.Deleted != 0 {
+		t.Fatalf("Sweep() = %+v, %v; want nothing deleted", result, err)
+	}
+	if sessions, _ := store.ListSessions(ctx); len(sessions) != 1 {
+		t.Errorf("session deleted although the hook failed: %+v", sessions)
+	}
+}
+
+func TestJanitor_SparesActiveSessions(t *testing.T) {
+	ctx := context.Background()
+	store := newJanitorStore(t)
+	store.AddMessage(ctx, "s1", "user", "one")
+	store.AddMessage(ctx, "s2", "user", "two")
+
+	j := NewJanitor(store, RetentionPolicy{MaxSessions: 1}, "", nil)
+	if result, _ := j.Sweep(ctx); result.Deleted != 0 {
+		t.Errorf("Sweep() deleted a session in use: %+v", result)
+	}
+
+	j.nowFunc = func() time.Time { return time.Now().Add(time.Hour) }
+	if result, _ := j.Sweep(ctx); result.Deleted != 1 {
+		t.Errorf("Sweep() = %+v, want the older session deleted", result)
+	}
+}
+
+func TestJanitor_TrimsAtUserMessage(t *testing.T) {
+	ctx := context.Background()
+	store := newJanitorStore(t)
+	history := []providers.Message{
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "c1"}}},
+		{Role: "tool", ToolCallID: "c1", Content: "r1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "a2"},
+	}
+	store.SetHistory(ctx, "s1", history)
+
+	archiveDir := t.TempDir()
+	j := NewJanitor(store, RetentionPolicy{MaxMessages: 4}, archiveDir, nil)
+	j.nowFunc = func() time.Time { return time.Now().Add(time.Hour) }
+
+	result, err := j.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if result.Trimmed != 1 || result.Archived != 1 || result.Deleted != 0 {
+		t.Fatalf("Sweep() = %+v", result)
+	}
+	got, _ := store.GetHistory(ctx, "s1")
+	if len(got) != 2 || got[0].Content != "q2" {
+		t.Errorf("history after trim = %+v, want it to start at the next user message", got)
+	}
+}
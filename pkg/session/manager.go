@@ -2,12 +2,14 @@ package session
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/fileutil"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -15,6 +17,7 @@ type Session struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
+	Model    string              `json:"model,omitempty"` // model_list name overriding the agent's model
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
 }
@@ -145,6 +148,110 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	session.Updated = time.Now()
 }
 
+// GetModel returns the model override of a session, or "" if it uses the
+// agent's model.
+func (sm *SessionManager) GetModel(key string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return ""
+	}
+	return session.Model
+}
+
+// SetModel sets the model override of a session, creating the session if
+// needed. An empty model clears the override.
+func (sm *SessionManager) SetModel(key, model string) {
+	session := sm.GetOrCreate(key)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	session.Model = model
+	session.Updated = time.Now()
+}
+
+// Reset clears the messages and summary of a session, starting a fresh
+// conversation under the same key. The model override is kept.
+func (sm *SessionManager) Reset(key string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	session.Messages = []providers.Message{}
+	session.Summary = ""
+	session.Created = now
+	session.Updated = now
+}
+
+// Archive copies the current state of a session to the archive directory
+// inside the storage directory and then resets it. It returns the path of
+// the archived file, or "" if there was nothing to archive.
+func (sm *SessionManager) Archive(key string) (string, error) {
+	sm.mu.RLock()
+	stored, ok := sm.sessions[key]
+	empty := !ok || (len(stored.Messages) == 0 && stored.Summary == "")
+	sm.mu.RUnlock()
+	if empty {
+		return "", nil
+	}
+
+	var archivePath string
+	if sm.storage != "" {
+		filename, err := sessionFilename(key)
+		if err != nil {
+			return "", err
+		}
+
+		sm.mu.RLock()
+		data, err := json.MarshalIndent(stored, "", "  ")
+		sm.mu.RUnlock()
+		if err != nil {
+			return "", err
+		}
+
+		archiveDir := filepath.Join(sm.storage, "archive")
+		if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+			return "", err
+		}
+		archivePath = filepath.Join(archiveDir, fmt.Sprintf("%s-%d.json", filename, time.Now().UnixMilli()))
+		if err := fileutil.WriteFileAtomic(archivePath, data, 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	sm.Reset(key)
+	return archivePath, sm.Save(key)
+}
+
+// DropLastExchange removes the most recent user message and everything after
+// it (assistant replies, tool calls and tool results). It returns the number
+// of messages removed.
+func (sm *SessionManager) DropLastExchange(key string) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return 0
+	}
+
+	for i := len(session.Messages) - 1; i >= 0; i-- {
+		if session.Messages[i].Role == "user" {
+			removed := len(session.Messages) - i
+			session.Messages = session.Messages[:i]
+			session.Updated = time.Now()
+			return removed
+		}
+	}
+	return 0
+}
+
 // sanitizeFilename converts a session key into a cross-platform safe filename.
 // Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
 // volume separator on Windows, so filepath.Base would misinterpret the key.
@@ -154,19 +261,30 @@ func sanitizeFilename(key string) string {
 	return strings.ReplaceAll(key, ":", "_")
 }
 
-func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
-		return nil
-	}
-
+// sessionFilename returns the file name for a session key, rejecting keys
+// that would escape the storage directory.
+func sessionFilename(key string) (string, error) {
 	filename := sanitizeFilename(key)
 
 	// filepath.IsLocal rejects empty names, "..", absolute paths, and
 	// OS-reserved device names (NUL, COM1 … on Windows).
 	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
+	// the session file is always written directly inside the storage
+	// directory.
 	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
+		return "", os.ErrInvalid
+	}
+	return filename, nil
+}
+
+func (sm *SessionManager) Save(key string) error {
+	if sm.storage == "" {
+		return nil
+	}
+
+	filename, err := sessionFilename(key)
+	if err != nil {
+		return err
 	}
 
 	// Snapshot under read lock, then perform slow file I/O after unlock.
@@ -180,6 +298,7 @@ func (sm *SessionManager) Save(key string) error {
 	snapshot := Session{
 		Key:     stored.Key,
 		Summary: stored.Summary,
+		Model:   stored.Model,
 		Created: stored.Created,
 		Updated: stored.Updated,
 	}
@@ -265,6 +384,12 @@ func (sm *SessionManager) loadSessions() error {
 	return nil
 }
 
+// Close implements SessionStore. Sessions are only written by Save, so there
+// is nothing to release.
+func (sm *SessionManager) Close() error {
+	return nil
+}
+
 // SetHistory updates the messages of a session.
 func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
 	sm.mu.Lock()
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func TestSanitizeFilename(t *testing.T) {
@@ -72,3 +74,64 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestArchive_ResetsSessionAndKeepsCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:123456"
+	sm.AddMessage(key, "user", "hello")
+	sm.AddMessage(key, "assistant", "hi")
+	sm.SetSummary(key, "greetings")
+
+	path, err := sm.Archive(key)
+	if err != nil {
+		t.Fatalf("Archive(%q) failed: %v", key, err)
+	}
+	if filepath.Dir(path) != filepath.Join(tmpDir, "archive") {
+		t.Fatalf("unexpected archive path %q", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("archived file missing: %v", err)
+	}
+	if got := len(sm.GetHistory(key)); got != 0 {
+		t.Errorf("history after archive = %d messages, want 0", got)
+	}
+	if got := sm.GetSummary(key); got != "" {
+		t.Errorf("summary after archive = %q, want empty", got)
+	}
+
+	// Archived copies are not loaded back as live sessions.
+	sm2 := NewSessionManager(tmpDir)
+	if got := len(sm2.GetHistory(key)); got != 0 {
+		t.Errorf("reloaded history = %d messages, want 0", got)
+	}
+
+	if path, err := sm.Archive(key); err != nil || path != "" {
+		t.Errorf("Archive of empty session = (%q, %v), want no-op", path, err)
+	}
+}
+
+func TestDropLastExchange(t *testing.T) {
+	sm := NewSessionManager("")
+	key := "s"
+	sm.AddMessage(key, "user", "first")
+	sm.AddMessage(key, "assistant", "one")
+	sm.AddMessage(key, "user", "second")
+	sm.AddFullMessage(key, providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "1"}}})
+	sm.AddFullMessage(key, providers.Message{Role: "tool", Content: "result", ToolCallID: "1"})
+	sm.AddMessage(key, "assistant", "two")
+
+	if removed := sm.DropLastExchange(key); removed != 4 {
+		t.Fatalf("DropLastExchange() removed %d, want 4", removed)
+	}
+	history := sm.GetHistory(key)
+	if len(history) != 2 || history[1].Content != "one" {
+		t.Fatalf("unexpected history after undo: %+v", history)
+	}
+
+	sm.DropLastExchange(key)
+	if removed := sm.DropLastExchange(key); removed != 0 {
+		t.Errorf("DropLastExchange() on empty session removed %d, want 0", removed)
+	}
+}
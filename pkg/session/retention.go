@@ -0,0 +1,37 @@
+package session
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// RetentionPolicy limits how long and how many sessions are kept, and how
+// long they grow. Zero values disable a limit.
+type RetentionPolicy struct {
+	MaxAge      time.Duration // sessions idle for longer expire
+	MaxSessions int           // only the most recently updated sessions are kept
+	MaxMessages int           // older messages of longer sessions are trimmed
+}
+
+// IsZero reports whether the policy keeps every session. MaxMessages only
+// trims sessions, so it does not count.
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge <= 0 && p.MaxSessions <= 0
+}
+
+// Expired returns the sessions the policy drops at now. sessions must be
+// ordered most recently updated first, as memory.Store.ListSessions
+// returns them.
+func (p RetentionPolicy) Expired(sessions []memory.SessionInfo, now time.Time) []memory.SessionInfo {
+	var expired []memory.SessionInfo
+	for i, s := range sessions {
+		switch {
+		case p.MaxSessions > 0 && i >= p.MaxSessions:
+			expired = append(expired, s)
+		case p.MaxAge > 0 && now.Sub(s.UpdatedAt) > p.MaxAge:
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,52 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func TestRetentionPolicy_Expired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sessions := []memory.SessionInfo{
+		{Key: "a", UpdatedAt: now.Add(-time.Hour)},
+		{Key: "b", UpdatedAt: now.Add(-48 * time.Hour)},
+		{Key: "c", UpdatedAt: now.Add(-72 * time.Hour)},
+	}
+
+	keys := func(infos []memory.SessionInfo) []string {
+		var out []string
+		for _, s := range infos {
+			out = append(out, s.Key)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string
+	}{
+		{"no limits", RetentionPolicy{}, nil},
+		{"max age", RetentionPolicy{MaxAge: 24 * time.Hour}, []string{"b", "c"}},
+		{"max sessions", RetentionPolicy{MaxSessions: 2}, []string{"c"}},
+		{"both", RetentionPolicy{MaxAge: 60 * time.Hour, MaxSessions: 1}, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keys(tt.policy.Expired(sessions, now))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expired() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expired() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+	if !(RetentionPolicy{}).IsZero() {
+		t.Error("empty policy should be zero")
+	}
+}
@@ -0,0 +1,28 @@
+package session
+
+import "github.com/sipeed/picoclaw/pkg/providers"
+
+// SessionStore is the session persistence used by agents. SessionManager
+// keeps every session in memory; StoreBackend reads them on demand from a
+// memory.Store.
+type SessionStore interface {
+	AddMessage(sessionKey, role, content string)
+	AddFullMessage(sessionKey string, msg providers.Message)
+	GetHistory(key string) []providers.Message
+	SetHistory(key string, history []providers.Message)
+	GetSummary(key string) string
+	SetSummary(key string, summary string)
+	TruncateHistory(key string, keepLast int)
+	GetModel(key string) string
+	SetModel(key, model string)
+	Reset(key string)
+	Archive(key string) (string, error)
+	DropLastExchange(key string) int
+	Save(key string) error
+	Close() error
+}
+
+var (
+	_ SessionStore = (*SessionManager)(nil)
+	_ SessionStore = (*StoreBackend)(nil)
+)
@@ -0,0 +1,229 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// StoreBackend adapts a memory.Store (JSONL files or SQLite) to
+// SessionStore. Nothing is cached: each call reads or writes the store, so
+// only sessions that are in use cost memory. Store errors are logged, since
+// SessionStore callers do not handle them.
+type StoreBackend struct {
+	store   memory.Store
+	storage string // directory for archived sessions, "" disables archiving
+}
+
+// NewStoreBackend wraps store. Archived sessions are written below
+// storage/archive.
+func NewStoreBackend(store memory.Store, storage string) *StoreBackend {
+	return &StoreBackend{store: store, storage: storage}
+}
+
+// Session store backends accepted by OpenStoreBackend.
+const (
+	BackendJSONL  = "jsonl"
+	BackendSQLite = "sqlite"
+)
+
+// OpenStoreBackend opens the session store of the given backend in dir ("" is
+// BackendJSONL), migrating any legacy sessions/*.json files found there first.
+func OpenStoreBackend(dir, backend string) (*StoreBackend, error) {
+	var store memory.Store
+	switch backend {
+	case "", BackendJSONL:
+		s, err := memory.NewJSONLStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	case BackendSQLite:
+		s, err := memory.NewSQLiteStore(filepath.Join(dir, "sessions.db"))
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		return nil, fmt.Errorf("unknown session store %q", backend)
+	}
+
+	n, err := memory.MigrateFromJSON(context.Background(), dir, store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if n > 0 {
+		logger.InfoCF("session", "Migrated legacy sessions",
+			map[string]any{"dir": dir, "backend": backend, "sessions": n})
+	}
+	return NewStoreBackend(store, dir), nil
+}
+
+// Store returns the underlying memory.Store.
+func (b *StoreBackend) Store() memory.Store {
+	return b.store
+}
+
+func (b *StoreBackend) warn(op, key string, err error) {
+	logger.WarnCF("session", "Session store "+op+" failed",
+		map[string]any{"session_key": key, "error": err.Error()})
+}
+
+func (b *StoreBackend) AddMessage(sessionKey, role, content string) {
+	if err := b.store.AddMessage(context.Background(), sessionKey, role, content); err != nil {
+		b.warn("append", sessionKey, err)
+	}
+}
+
+func (b *StoreBackend) AddFullMessage(sessionKey string, msg providers.Message) {
+	if err := b.store.AddFullMessage(context.Background(), sessionKey, msg); err != nil {
+		b.warn("append", sessionKey, err)
+	}
+}
+
+func (b *StoreBackend) GetHistory(key string) []providers.Message {
+	history, err := b.store.GetHistory(context.Background(), key)
+	if err != nil {
+		b.warn("read", key, err)
+		return []providers.Message{}
+	}
+	return history
+}
+
+func (b *StoreBackend) SetHistory(key string, history []providers.Message) {
+	if err := b.store.SetHistory(context.Background(), key, history); err != nil {
+		b.warn("write", key, err)
+	}
+}
+
+func (b *StoreBackend) GetSummary(key string) string {
+	summary, err := b.store.GetSummary(context.Background(), key)
+	if err != nil {
+		b.warn("read", key, err)
+	}
+	return summary
+}
+
+func (b *StoreBackend) SetSummary(key string, summary string) {
+	if err := b.store.SetSummary(context.Background(), key, summary); err != nil {
+		b.warn("write", key, err)
+	}
+}
+
+func (b *StoreBackend) TruncateHistory(key string, keepLast int) {
+	if err := b.store.TruncateHistory(context.Background(), key, keepLast); err != nil {
+		b.warn("truncate", key, err)
+	}
+}
+
+func (b *StoreBackend) GetModel(key string) string {
+	model, err := b.store.GetModel(context.Background(), key)
+	if err != nil {
+		b.warn("read", key, err)
+	}
+	return model
+}
+
+func (b *StoreBackend) SetModel(key, model string) {
+	if err := b.store.SetModel(context.Background(), key, model); err != nil {
+		b.warn("write", key, err)
+	}
+}
+
+// Reset clears the messages and summary of a session. The model override is
+// kept.
+func (b *StoreBackend) Reset(key string) {
+	b.SetHistory(key, []providers.Message{})
+	b.SetSummary(key, "")
+}
+
+// Archive copies a session to storage/archive in the legacy JSON format and
+// then resets it. It returns the path of the archived file, or "" if there
+// was nothing to archive.
+func (b *StoreBackend) Archive(key string) (string, error) {
+	ctx := context.Background()
+	history, err := b.store.GetHistory(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	summary, err := b.store.GetSummary(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 && summary == "" {
+		return "", nil
+	}
+
+	var archivePath string
+	if b.storage != "" {
+		filename, err := sessionFilename(key)
+		if err != nil {
+			return "", err
+		}
+		model, err := b.store.GetModel(ctx, key)
+		if err != nil {
+			return "", err
+		}
+
+		now := time.Now()
+		data, err := json.MarshalIndent(Session{
+			Key:      key,
+			Messages: history,
+			Summary:  summary,
+			Model:    model,
+			Updated:  now,
+		}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		archiveDir := filepath.Join(b.storage, "archive")
+		if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+			return "", err
+		}
+		archivePath = filepath.Join(archiveDir, fmt.Sprintf("%s-%d.json", filename, now.UnixMilli()))
+		if err := fileutil.WriteFileAtomic(archivePath, data, 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	if err := b.store.SetHistory(ctx, key, []providers.Message{}); err != nil {
+		return archivePath, err
+	}
+	return archivePath, b.store.SetSummary(ctx, key, "")
+}
+
+// DropLastExchange removes the most recent user message and everything after
+// it. It returns the number of messages removed.
+func (b *StoreBackend) DropLastExchange(key string) int {
+	history := b.GetHistory(key)
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			if err := b.store.SetHistory(context.Background(), key, history[:i]); err != nil {
+				b.warn("write", key, err)
+				return 0
+			}
+			return len(history) - i
+		}
+	}
+	return 0
+}
+
+// Save compacts the session. Every write is already durable, so this only
+// reclaims the space of truncated messages.
+func (b *StoreBackend) Save(key string) error {
+	return b.store.Compact(context.Background(), key)
+}
+
+func (b *StoreBackend) Close() error {
+	return b.store.Close()
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestOpenStoreBackend_MigratesLegacySessions(t *testing.T) {
+	dir := t.TempDir()
+	legacy := NewSessionManager(dir)
+	key := "agent:main:telegram:direct:42"
+	legacy.AddMessage(key, "user", "hello")
+	legacy.AddMessage(key, "assistant", "hi")
+	legacy.SetSummary(key, "greetings")
+	legacy.SetModel(key, "fast")
+	if err := legacy.Save(key); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	b, err := OpenStoreBackend(dir, BackendJSONL)
+	if err != nil {
+		t.Fatalf("OpenStoreBackend() error = %v", err)
+	}
+	if got := len(b.GetHistory(key)); got != 2 {
+		t.Errorf("migrated history = %d messages, want 2", got)
+	}
+	if got := b.GetSummary(key); got != "greetings" {
+		t.Errorf("migrated summary = %q, want %q", got, "greetings")
+	}
+	if got := b.GetModel(key); got != "fast" {
+		t.Errorf("migrated model = %q, want %q", got, "fast")
+	}
+	if _, err := os.Stat(filepath.Join(dir, sanitizeFilename(key)+".json.migrated")); err != nil {
+		t.Errorf("legacy file not kept as backup: %v", err)
+	}
+
+	// Reopening must not migrate again or touch the store's own files.
+	b.AddMessage(key, "user", "again")
+	b2, err := OpenStoreBackend(dir, BackendJSONL)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if got := len(b2.GetHistory(key)); got != 3 {
+		t.Errorf("history after reopen = %d messages, want 3", got)
+	}
+}
+
+func TestStoreBackend_SessionCommands(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenStoreBackend(dir, BackendJSONL)
+	if err != nil {
+		t.Fatalf("OpenStoreBackend() error = %v", err)
+	}
+
+	key := "telegram:123456"
+	b.SetModel(key, "fast")
+	b.AddMessage(key, "user", "first")
+	b.AddMessage(key, "assistant", "one")
+	b.AddMessage(key, "user", "second")
+	b.AddFullMessage(key, providers.Message{Role: "tool", Content: "result", ToolCallID: "1"})
+	b.AddMessage(key, "assistant", "two")
+
+	if removed := b.DropLastExchange(key); removed != 3 {
+		t.Fatalf("DropLastExchange() removed %d, want 3", removed)
+	}
+
+	b.SetSummary(key, "greetings")
+	path, err := b.Archive(key)
+	if err != nil {
+		t.Fatalf("Archive() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("archived file missing: %v", err)
+	}
+	var archived Session
+	if err := json.Unmarshal(data, &archived); err != nil {
+		t.Fatalf("archived file is not a session: %v", err)
+	}
+	if archived.Key != key || len(archived.Messages) != 2 || archived.Summary != "greetings" {
+		t.Errorf("unexpected archived session: %+v", archived)
+	}
+
+	if got := len(b.GetHistory(key)); got != 0 {
+		t.Errorf("history after archive = %d messages, want 0", got)
+	}
+	if got := b.GetSummary(key); got != "" {
+		t.Errorf("summary after archive = %q, want empty", got)
+	}
+	if got := b.GetModel(key); got != "fast" {
+		t.Errorf("model after archive = %q, want it kept", got)
+	}
+	if path, err := b.Archive(key); err != nil || path != "" {
+		t.Errorf("Archive of empty session = (%q, %v), want no-op", path, err)
+	}
+}
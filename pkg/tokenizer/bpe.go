@@ -0,0 +1,207 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxBPEPiece is the longest piece, in bytes, that is merged exactly.
+// Merging is quadratic in the piece length, so longer runs, such as padding
+// or minified text in tool output, are estimated instead.
+const maxBPEPiece = 2048
+
+// BPE is a byte-level byte-pair encoding, as used by OpenAI models. Text is
+// split into pieces like tiktoken's cl100k_base pattern does, and each piece
+// is merged from single bytes by rank.
+type BPE struct {
+	name  string
+	ranks map[string]int
+}
+
+// NewBPE creates an encoding from its merge ranks: token bytes to rank,
+// lower ranks merging first. Every single byte must have a rank.
+func NewBPE(name string, ranks map[string]int) *BPE {
+	return &BPE{name: name, ranks: ranks}
+}
+
+// LoadRanks reads merge ranks in the .tiktoken format: one token per line,
+// base64 encoded, followed by a space and its rank.
+func LoadRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing rank", line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(b)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for b := 0; b < 256; b++ {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("no rank for byte %#x", b)
+		}
+	}
+	return ranks, nil
+}
+
+// Name returns the encoding name.
+func (e *BPE) Name() string {
+	return e.name
+}
+
+// Count returns the number of tokens in text.
+func (e *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range splitPieces(text) {
+		n += e.countPiece(piece)
+	}
+	return n
+}
+
+// countPiece merges the bytes of a piece pair by pair, lowest rank first,
+// and returns the number of parts left.
+func (e *BPE) countPiece(piece string) int {
+	if _, ok := e.ranks[piece]; ok {
+		return 1
+	}
+	if len(piece) > maxBPEPiece {
+		return openAIEstimator.Count(piece)
+	}
+	// bounds[i] is where part i starts; the last entry is len(piece).
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// splitPieces splits text the way the cl100k_base pattern does:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// Go's regexp has no lookahead, hence the hand-written scanner. o200k_base
+// splits letters on case changes as well; counting its pieces with this
+// split differs by a token at most on rare mixed-case words.
+func splitPieces(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		n := pieceLen(text[i:])
+		pieces = append(pieces, text[i:i+n])
+		i += n
+	}
+	return pieces
+}
+
+func pieceLen(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+
+	// Contractions.
+	if r == '\'' {
+		lower := strings.ToLower(s[1:min(len(s), 3)])
+		for _, c := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+			if strings.HasPrefix(lower, c) {
+				return 1 + len(c)
+			}
+		}
+	}
+
+	// An optional non-letter, non-digit, non-newline followed by letters.
+	if unicode.IsLetter(r) {
+		return size + runLen(s[size:], unicode.IsLetter)
+	}
+	if r != '\r' && r != '\n' && !unicode.IsNumber(r) {
+		if next, _ := utf8.DecodeRuneInString(s[size:]); unicode.IsLetter(next) {
+			return size + runLen(s[size:], unicode.IsLetter)
+		}
+	}
+
+	// One to three digits.
+	if unicode.IsNumber(r) {
+		n := size
+		for k := 1; k < 3; k++ {
+			next, nsize := utf8.DecodeRuneInString(s[n:])
+			if nsize == 0 || !unicode.IsNumber(next) {
+				break
+			}
+			n += nsize
+		}
+		return n
+	}
+
+	// An optional space, punctuation and trailing newlines.
+	start := 0
+	if r == ' ' {
+		start = 1
+	}
+	if n := runLen(s[start:], isPunct); n > 0 {
+		end := start + n
+		return end + runLen(s[end:], isNewline)
+	}
+
+	// Whitespace: up to the last newline if there is one; else all of it at
+	// the end of text, but one rune short of longer runs elsewhere so that
+	// the last space leads the next piece.
+	ws := runLen(s, unicode.IsSpace)
+	if last := strings.LastIndexAny(s[:ws], "\r\n"); last >= 0 {
+		return last + 1
+	}
+	if ws < len(s) {
+		if _, lastSize := utf8.DecodeLastRuneInString(s[:ws]); ws > lastSize {
+			return ws - lastSize
+		}
+	}
+	return ws
+}
+
+// runLen returns the byte length of the leading runes of s matching f.
+func runLen(s string, f func(rune) bool) int {
+	for i, r := range s {
+		if !f(r) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
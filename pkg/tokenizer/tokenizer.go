@@ -0,0 +1,105 @@
+// Package tokenizer counts the tokens a model sees in a piece of text. OpenAI
+// models are counted exactly with their byte-level BPE encodings when the
+// encoding files are available; other models use calibrated estimators.
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Tokenizer counts tokens.
+type Tokenizer interface {
+	// Name identifies the encoding or estimator, for logs.
+	Name() string
+	// Count returns the number of tokens in text.
+	Count(text string) int
+}
+
+// Registry picks the tokenizer for a model. BPE encodings are loaded lazily
+// from <dir>/<encoding>.tiktoken, the file format OpenAI publishes them in,
+// and cached; models whose encoding is missing fall back to an estimator.
+type Registry struct {
+	dir string
+
+	mu        sync.Mutex
+	encodings map[string]*BPE // nil value: file missing or invalid
+}
+
+// NewRegistry creates a Registry that loads encodings from dir. dir may be
+// empty, in which case only estimators are used.
+func NewRegistry(dir string) *Registry {
+	return &Registry{
+		dir:       dir,
+		encodings: make(map[string]*BPE),
+	}
+}
+
+// ForModel returns the tokenizer for a model ID, with or without a protocol
+// prefix ("openai/gpt-4o" and "gpt-4o" are the same model).
+func (r *Registry) ForModel(model string) Tokenizer {
+	model = strings.ToLower(model)
+	if _, id, ok := strings.Cut(model, "/"); ok {
+		model = id
+	}
+
+	if encoding := openAIEncoding(model); encoding != "" {
+		if bpe := r.encoding(encoding); bpe != nil {
+			return bpe
+		}
+	}
+	return estimatorFor(model)
+}
+
+func (r *Registry) encoding(name string) *BPE {
+	if r == nil || r.dir == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bpe, ok := r.encodings[name]; ok {
+		return bpe
+	}
+
+	var bpe *BPE
+	path := filepath.Join(r.dir, name+".tiktoken")
+	if f, err := os.Open(path); err == nil {
+		ranks, err := LoadRanks(f)
+		f.Close()
+		if err != nil {
+			logger.WarnCF("tokenizer", "Invalid encoding file, using estimator",
+				map[string]any{"path": path, "error": err.Error()})
+		} else {
+			bpe = NewBPE(name, ranks)
+		}
+	}
+	r.encodings[name] = bpe
+	return bpe
+}
+
+// openAIEncoding returns the BPE encoding of an OpenAI model, or "" for
+// models of other vendors.
+func openAIEncoding(model string) string {
+	switch {
+	case strings.HasPrefix(model, "gpt-4o"),
+		strings.HasPrefix(model, "gpt-4.1"),
+		strings.HasPrefix(model, "gpt-4.5"),
+		strings.HasPrefix(model, "gpt-5"),
+		strings.HasPrefix(model, "chatgpt-"),
+		strings.HasPrefix(model, "o1"),
+		strings.HasPrefix(model, "o3"),
+		strings.HasPrefix(model, "o4"),
+		strings.HasPrefix(model, "codex-"),
+		strings.HasPrefix(model, "gpt-oss"):
+		return "o200k_base"
+	case strings.HasPrefix(model, "gpt-4"),
+		strings.HasPrefix(model, "gpt-3.5"),
+		strings.HasPrefix(model, "text-embedding-"):
+		return "cl100k_base"
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPieces(t *testing.T) {
+	// Expected splits as produced by tiktoken's cl100k_base pattern.
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'm 12345 ok", []string{"I", "'m", " ", "123", "45", " ok"}},
+		{"They'LL go", []string{"They", "'LL", " go"}},
+		{"foo  bar", []string{"foo", " ", " bar"}},
+		{"a\n\n  b", []string{"a", "\n\n", " ", " b"}},
+		{"x = f(y);\n", []string{"x", " =", " f", "(y", ");\n"}},
+		{"end   ", []string{"end", "   "}},
+		{"你好，世界", []string{"你好", "，世界"}},
+		{"'quoted'", []string{"'quoted", "'"}},
+	}
+	for _, tt := range tests {
+		if got := splitPieces(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPieces(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// testRanks is a tiny encoding: all single bytes plus a few merges.
+func testRanks() map[string]int {
+	ranks := make(map[string]int)
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+	for i, merge := range []string{"he", "ll", "llo", "hello", " w", " wo", " wor"} {
+		ranks[merge] = 256 + i
+	}
+	return ranks
+}
+
+func TestBPE_Count(t *testing.T) {
+	bpe := NewBPE("test", testRanks())
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},       // he + llo, then hello
+		{"hello world", 4}, // hello, " wor", "l", "d"
+		{"help", 3},        // he l p
+		{"ab", 2},
+	}
+	for _, tt := range tests {
+		if got := bpe.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBPE_CountLongPiece(t *testing.T) {
+	bpe := NewBPE("test", testRanks())
+	// Merging is quadratic in the piece length; a long letter run is
+	// estimated instead.
+	long := strings.Repeat("x", maxBPEPiece+1)
+	if got, want := bpe.Count(long), openAIEstimator.Count(long); got != want {
+		t.Errorf("Count(long piece) = %d, want the estimate %d", got, want)
+	}
+	if got := bpe.Count(long[:maxBPEPiece]); got != maxBPEPiece {
+		t.Errorf("Count(piece at the limit) = %d, want the exact %d", got, maxBPEPiece)
+	}
+}
+
+func writeRanks(t *testing.T, path string, ranks map[string]int) {
+	t.Helper()
+	var sb strings.Builder
+	for token, rank := range ranks {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRanks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	writeRanks(t, path, testRanks())
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ranks, err := LoadRanks(f)
+	if err != nil {
+		t.Fatalf("LoadRanks() error = %v", err)
+	}
+	if !reflect.DeepEqual(ranks, testRanks()) {
+		t.Error("LoadRanks() did not round-trip the ranks")
+	}
+
+	if _, err := LoadRanks(strings.NewReader("aGk= 1\n")); err == nil {
+		t.Error("LoadRanks() without single bytes succeeded, want error")
+	}
+	if _, err := LoadRanks(strings.NewReader("aGk=\n")); err == nil {
+		t.Error("LoadRanks() without rank succeeded, want error")
+	}
+}
+
+func TestRegistry_ForModel(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry(dir)
+
+	// No encoding file yet: the OpenAI estimator stands in.
+	if got := r.ForModel("openai/gpt-4o").Name(); got != "estimate:openai" {
+		t.Errorf("ForModel(gpt-4o) without encoding = %q", got)
+	}
+
+	writeRanks(t, filepath.Join(dir, "cl100k_base.tiktoken"), testRanks())
+	r = NewRegistry(dir)
+	tests := map[string]string{
+		"openai/gpt-4":               "cl100k_base",
+		"gpt-3.5-turbo":              "cl100k_base",
+		"gpt-4o-mini":                "estimate:openai", // o200k_base is missing
+		"anthropic/claude-sonnet-4":  "estimate:claude",
+		"gemini/gemini-2.5-flash":    "estimate:gemini",
+		"openrouter/qwen/qwen3-235b": "estimate:zh",
+		"ollama/llama3":              "estimate",
+	}
+	for model, want := range tests {
+		if got := r.ForModel(model).Name(); got != want {
+			t.Errorf("ForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+	if got := r.ForModel("gpt-4").Count("hello world"); got != 4 {
+		t.Errorf("cl100k_base Count() = %d, want the BPE count 4", got)
+	}
+}
+
+func TestEstimator_Count(t *testing.T) {
+	prose := "The quick brown fox jumps over the lazy dog."
+	if got := openAIEstimator.Count(prose); got < 9 || got > 12 {
+		t.Errorf("prose estimate = %d, want about 10", got)
+	}
+
+	// CJK text is about a token per character, far more than a
+	// characters-per-token heuristic would suggest.
+	cjk := "今天天气很好，我们去公园散步吧。"
+	if got := openAIEstimator.Count(cjk); got < 14 || got > 20 {
+		t.Errorf("CJK estimate = %d, want about 16", got)
+	}
+	if zh, claude := chineseEstimator.Count(cjk), claudeEstimator.Count(cjk); zh >= claude {
+		t.Errorf("chinese-optimized estimate %d should be below claude's %d", zh, claude)
+	}
+
+	code := "func main() {\n\tfmt.Println(\"hi\")\n}\n"
+	if got := openAIEstimator.Count(code); got < 9 || got > 16 {
+		t.Errorf("code estimate = %d, want about 12", got)
+	}
+
+	if got := defaultEstimator.Count(""); got != 0 {
+		t.Errorf("empty estimate = %d, want 0", got)
+	}
+}
@@ -0,0 +1,247 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// DefaultApprovalTimeout is how long a tool call waits for a decision
+// before it is denied.
+const DefaultApprovalTimeout = 2 * time.Minute
+
+var (
+	// ErrApprovalTimeout is returned by an Approver when nobody answered in time.
+	ErrApprovalTimeout = errors.New("approval timed out")
+	// ErrNoApprover is returned by an Approver when there is nobody to ask,
+	// e.g. for turns started by cron or other internal channels.
+	ErrNoApprover = errors.New("no one available to approve")
+)
+
+// ApprovalRequest describes a tool call that needs a human decision.
+type ApprovalRequest struct {
+	Tool        string
+	Args        map[string]any
+	Channel     string
+	ChatID      string
+	RequestedBy string // sender ID of the turn, "" if unknown
+	Timeout     time.Duration
+}
+
+// Approver asks a human to approve a tool call. RequestApproval blocks until
+// the call is approved or denied, the request's timeout expires (returning
+// ErrApprovalTimeout) or ctx is canceled, and reports who decided.
+type Approver interface {
+	RequestApproval(ctx context.Context, req ApprovalRequest) (approved bool, decidedBy string, err error)
+}
+
+type approvalRule struct {
+	tool     string
+	arg      string
+	match    *regexp.Regexp
+	notMatch *regexp.Regexp
+}
+
+func (r *approvalRule) matches(tool string, args map[string]any) bool {
+	if r.tool != tool {
+		return false
+	}
+	if r.arg == "" {
+		return true
+	}
+	value, ok := args[r.arg]
+	if !ok {
+		return false
+	}
+	s, ok := value.(string)
+	if !ok {
+		s = fmt.Sprint(value)
+	}
+	if r.match != nil && !r.match.MatchString(s) {
+		return false
+	}
+	if r.notMatch != nil && r.notMatch.MatchString(s) {
+		return false
+	}
+	return true
+}
+
+// ApprovalPolicy decides which tool calls need human approval, obtains it
+// through an Approver and appends every decision to an audit log.
+type ApprovalPolicy struct {
+	rules     []approvalRule
+	timeout   time.Duration
+	approver  Approver
+	auditPath string
+	auditMu   sync.Mutex
+}
+
+// NewApprovalPolicy compiles the rules of cfg. Decisions are appended as JSON
+// lines to auditPath; an empty path disables the audit log.
+func NewApprovalPolicy(cfg config.ApprovalConfig, approver Approver, auditPath string) (*ApprovalPolicy, error) {
+	p := &ApprovalPolicy{
+		timeout:   DefaultApprovalTimeout,
+		approver:  approver,
+		auditPath: auditPath,
+	}
+	if cfg.TimeoutSeconds > 0 {
+		p.timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	for _, rc := range cfg.Rules {
+		rule := approvalRule{tool: rc.Tool, arg: rc.Arg}
+		if rc.Match != "" {
+			rule.match = regexp.MustCompile(rc.Match)
+		}
+		if rc.NotMatch != "" {
+			rule.notMatch = regexp.MustCompile(rc.NotMatch)
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	return p, nil
+}
+
+// Requires reports whether a call to tool with args needs approval.
+func (p *ApprovalPolicy) Requires(tool string, args map[string]any) bool {
+	for i := range p.rules {
+		if p.rules[i].matches(tool, args) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize returns nil if the call may proceed, or the error result to hand
+// back to the LLM in place of executing the tool.
+func (p *ApprovalPolicy) authorize(ctx context.Context, tool string, args map[string]any) *ToolResult {
+	if !p.Requires(tool, args) {
+		return nil
+	}
+
+	req := ApprovalRequest{
+		Tool:        tool,
+		Args:        args,
+		Channel:     ToolChannel(ctx),
+		ChatID:      ToolChatID(ctx),
+		RequestedBy: ToolSender(ctx),
+		Timeout:     p.timeout,
+	}
+
+	approved, decidedBy, err := false, "", ErrNoApprover
+	if p.approver != nil {
+		approved, decidedBy, err = p.approver.RequestApproval(ctx, req)
+	}
+
+	outcome := "denied"
+	switch {
+	case err == nil && approved:
+		outcome = "approved"
+	case errors.Is(err, ErrApprovalTimeout):
+		outcome = "timeout"
+	case errors.Is(err, ErrNoApprover):
+		outcome = "no_approver"
+	case err != nil && ctx.Err() != nil:
+		outcome = "canceled"
+	case err != nil:
+		outcome = "error"
+	}
+	p.audit(req, outcome, decidedBy, err)
+
+	logger.InfoCF("tool", "Tool call approval decided",
+		map[string]any{
+			"tool":       tool,
+			"channel":    req.Channel,
+			"chat_id":    req.ChatID,
+			"outcome":    outcome,
+			"decided_by": decidedBy,
+		})
+
+	switch outcome {
+	case "approved":
+		return nil
+	case "denied":
+		return ErrorResult(fmt.Sprintf("The user denied permission to run %s. Do not retry it; ask how to proceed instead.", tool)).
+			WithError(fmt.Errorf("tool call denied"))
+	case "timeout":
+		return ErrorResult(fmt.Sprintf("Approval to run %s was not given within %s, so it was not run.", tool, p.timeout)).
+			WithError(err)
+	default:
+		return ErrorResult(fmt.Sprintf("%s requires approval, which could not be obtained: %v", tool, err)).
+			WithError(err)
+	}
+}
+
+type approvalAuditRecord struct {
+	Time        time.Time      `json:"time"`
+	Tool        string         `json:"tool"`
+	Args        map[string]any `json:"args,omitempty"`
+	Channel     string         `json:"channel,omitempty"`
+	ChatID      string         `json:"chat_id,omitempty"`
+	RequestedBy string         `json:"requested_by,omitempty"`
+	Outcome     string         `json:"outcome"`
+	DecidedBy   string         `json:"decided_by,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+func (p *ApprovalPolicy) audit(req ApprovalRequest, outcome, decidedBy string, err error) {
+	if p.auditPath == "" {
+		return
+	}
+
+	rec := approvalAuditRecord{
+		Time:        time.Now().UTC(),
+		Tool:        req.Tool,
+		Args:        req.Args,
+		Channel:     req.Channel,
+		ChatID:      req.ChatID,
+		RequestedBy: req.RequestedBy,
+		Outcome:     outcome,
+		DecidedBy:   decidedBy,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if werr := p.appendAudit(rec); werr != nil {
+		logger.WarnCF("tool", "Failed to write approval audit record",
+			map[string]any{
+				"path":  p.auditPath,
+				"error": werr.Error(),
+			})
+	}
+}
+
+func (p *ApprovalPolicy) appendAudit(rec approvalAuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	p.auditMu.Lock()
+	defer p.auditMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(p.auditPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	f, err := os.OpenFile(p.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
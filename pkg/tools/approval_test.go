@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type stubApprover struct {
+	approved bool
+	err      error
+	delay    time.Duration // how long the human takes to answer
+	requests []ApprovalRequest
+}
+
+func (s *stubApprover) RequestApproval(ctx context.Context, req ApprovalRequest) (bool, string, error) {
+	s.requests = append(s.requests, req)
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return false, "", ctx.Err()
+	}
+	return s.approved, "owner", s.err
+}
+
+func TestApprovalPolicy_Requires(t *testing.T) {
+	p, err := NewApprovalPolicy(config.ApprovalConfig{
+		Rules: []config.ApprovalRule{
+			{Tool: "exec"},
+			{Tool: "write_file", Arg: "path", NotMatch: `^notes/`},
+			{Tool: "i2c", Arg: "action", Match: `^write$`},
+		},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("NewApprovalPolicy() error: %v", err)
+	}
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		want bool
+	}{
+		{"exec", map[string]any{"command": "ls"}, true},
+		{"read_file", map[string]any{"path": "x"}, false},
+		{"write_file", map[string]any{"path": "notes/todo.md"}, false},
+		{"write_file", map[string]any{"path": "/etc/passwd"}, true},
+		{"i2c", map[string]any{"action": "write"}, true},
+		{"i2c", map[string]any{"action": "scan"}, false},
+		{"i2c", map[string]any{}, false},
+	}
+	for _, tt := range tests {
+		if got := p.Requires(tt.tool, tt.args); got != tt.want {
+			t.Errorf("Requires(%s, %v) = %v, want %v", tt.tool, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestNewApprovalPolicy_InvalidRules(t *testing.T) {
+	for _, rule := range []config.ApprovalRule{
+		{},
+		{Tool: "exec", Arg: "command", Match: "("},
+		{Tool: "exec", Match: "rm"},
+	} {
+		if _, err := NewApprovalPolicy(config.ApprovalConfig{Rules: []config.ApprovalRule{rule}}, nil, ""); err == nil {
+			t.Errorf("expected error for rule %+v", rule)
+		}
+	}
+}
+
+func TestToolRegistry_ApprovalGatesExecution(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "state", "approvals.jsonl")
+	approver := &stubApprover{}
+	policy, err := NewApprovalPolicy(config.ApprovalConfig{
+		Rules: []config.ApprovalRule{{Tool: "danger"}},
+	}, approver, auditPath)
+	if err != nil {
+		t.Fatalf("NewApprovalPolicy() error: %v", err)
+	}
+
+	r := NewToolRegistry()
+	r.Register(newMockTool("danger", "needs approval"))
+	r.Register(newMockTool("safe", "no approval"))
+	r.SetApprovalPolicy(policy)
+
+	ctx := WithToolSender(context.Background(), "user1")
+
+	res := r.ExecuteWithContext(ctx, "danger", map[string]any{"x": 1}, "telegram", "chat1", nil)
+	if !res.IsError || !strings.Contains(res.ForLLM, "denied") {
+		t.Fatalf("expected denied result, got %+v", res)
+	}
+
+	approver.approved = true
+	if res := r.ExecuteWithContext(ctx, "danger", nil, "telegram", "chat1", nil); res.IsError {
+		t.Fatalf("expected approved call to run, got %q", res.ForLLM)
+	}
+	if res := r.ExecuteWithContext(ctx, "safe", nil, "telegram", "chat1", nil); res.IsError {
+		t.Fatalf("expected unrestricted call to run, got %q", res.ForLLM)
+	}
+
+	if len(approver.requests) != 2 {
+		t.Fatalf("approval requests = %d, want 2", len(approver.requests))
+	}
+	req := approver.requests[0]
+	if req.Channel != "telegram" || req.ChatID != "chat1" || req.RequestedBy != "user1" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit records = %d, want 2", len(lines))
+	}
+	var rec approvalAuditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("unmarshal audit record: %v", err)
+	}
+	if rec.Tool != "danger" || rec.Outcome != "denied" || rec.DecidedBy != "owner" {
+		t.Errorf("unexpected audit record: %+v", rec)
+	}
+}
+
+func TestToolRegistry_ApprovalWithoutApproverDenies(t *testing.T) {
+	policy, err := NewApprovalPolicy(config.ApprovalConfig{
+		Rules: []config.ApprovalRule{{Tool: "danger"}},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("NewApprovalPolicy() error: %v", err)
+	}
+
+	r := NewToolRegistry()
+	r.Register(newMockTool("danger", "needs approval"))
+	r.SetApprovalPolicy(policy)
+
+	res := r.ExecuteWithContext(context.Background(), "danger", nil, "cli", "direct", nil)
+	if !res.IsError || res.Err == nil {
+		t.Fatalf("expected error result, got %+v", res)
+	}
+}
+
+func TestExecuteBatch_ApprovalDoesNotCountAgainstTimeout(t *testing.T) {
+	approver := &stubApprover{approved: true, delay: 200 * time.Millisecond}
+	policy, err := NewApprovalPolicy(config.ApprovalConfig{
+		Rules: []config.ApprovalRule{{Tool: "slow"}},
+	}, approver, "")
+	if err != nil {
+		t.Fatalf("NewApprovalPolicy() error: %v", err)
+	}
+
+	r := NewToolRegistry()
+	r.Register(&sleepTool{name: "slow"})
+	r.SetApprovalPolicy(policy)
+	opts := BatchOptions{Timeout: 50 * time.Millisecond, Channel: "telegram", ChatID: "chat1"}
+	call := []providers.ToolCall{{ID: "1", Name: "slow", Arguments: map[string]any{"id": "a", "ms": 0}}}
+
+	if res := r.ExecuteBatch(context.Background(), call, opts)[0]; res.IsError || res.ForLLM != "done a" {
+		t.Fatalf("expected slowly approved call to run, got %q", res.ForLLM)
+	}
+
+	approver.approved = false
+	res := r.ExecuteBatch(context.Background(), call, opts)[0]
+	if !res.IsError || !strings.Contains(res.ForLLM, "denied") {
+		t.Fatalf("expected denied result, got %q", res.ForLLM)
+	}
+	if req := approver.requests[1]; req.Channel != "telegram" || req.ChatID != "chat1" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+}
@@ -23,6 +23,9 @@ type toolCtxKey struct{ name string }
 var (
 	ctxKeyChannel = &toolCtxKey{"channel"}
 	ctxKeyChatID  = &toolCtxKey{"chatID"}
+	ctxKeySender  = &toolCtxKey{"sender"}
+	ctxKeyPerson  = &toolCtxKey{"person"}
+	ctxKeySession = &toolCtxKey{"session"}
 )
 
 // WithToolContext returns a child context carrying channel and chatID.
@@ -44,6 +47,42 @@ func ToolChatID(ctx context.Context) string {
 	return v
 }
 
+// WithToolSender returns a child context carrying the sender ID of the
+// message that started the turn.
+func WithToolSender(ctx context.Context, senderID string) context.Context {
+	return context.WithValue(ctx, ctxKeySender, senderID)
+}
+
+// ToolSender extracts the sender ID from ctx, or "" if unset.
+func ToolSender(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeySender).(string)
+	return v
+}
+
+// WithToolPerson returns a child context carrying the profile key of the
+// person the turn comes from.
+func WithToolPerson(ctx context.Context, personID string) context.Context {
+	return context.WithValue(ctx, ctxKeyPerson, personID)
+}
+
+// ToolPerson extracts the person profile key from ctx, or "" if unset.
+func ToolPerson(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeyPerson).(string)
+	return v
+}
+
+// WithToolSession returns a child context carrying the session key of the
+// turn.
+func WithToolSession(ctx context.Context, sessionKey string) context.Context {
+	return context.WithValue(ctx, ctxKeySession, sessionKey)
+}
+
+// ToolSession extracts the session key from ctx, or "" if unset.
+func ToolSession(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeySession).(string)
+	return v
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
@@ -0,0 +1,403 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultFindResults = 200
+	defaultGrepResults = 100
+	maxSearchResults   = 1000
+	maxGrepContext     = 10
+	// maxGrepFileSize skips large files, which are rarely source or notes.
+	maxGrepFileSize = 1 << 20
+	maxGrepLineLen  = 500
+)
+
+// searchSkipDirs are never descended into: they hold tool state or
+// dependencies rather than the user's files.
+var searchSkipDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+	"__pycache__":  true,
+	".venv":        true,
+}
+
+var errSearchLimit = errors.New("search result limit reached")
+
+// walkFiles calls fn for every regular file below dir, in lexical order,
+// with its path joined to dir and its slash-separated path relative to dir.
+// Symlinks are not followed. It reads through fsys so searches obey the
+// same workspace restrictions as read_file.
+func walkFiles(ctx context.Context, fsys fileSystem, dir string, fn func(p, rel string, entry fs.DirEntry) error) error {
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			p := filepath.Join(dir, entry.Name())
+			r := path.Join(rel, entry.Name())
+			switch {
+			case entry.IsDir():
+				if searchSkipDirs[entry.Name()] {
+					continue
+				}
+				// Unreadable subdirectories are skipped, not fatal.
+				if err := walk(p, r); err != nil && (errors.Is(err, errSearchLimit) || ctx.Err() != nil) {
+					return err
+				}
+			case entry.Type().IsRegular():
+				if err := fn(p, r, entry); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk(dir, "")
+}
+
+// matchGlob reports whether the slash-separated path rel matches pattern.
+// "**" matches any number of directories. A pattern without a slash is
+// matched against the base name only, so "*.go" finds Go files anywhere.
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// searchDefaultDir is where searches start without a path: the workspace.
+// Restricted filesystems resolve relative paths against the workspace.
+func searchDefaultDir(workspace string, restrict bool) string {
+	if restrict || workspace == "" {
+		return "."
+	}
+	return workspace
+}
+
+// resultLimit reads a positive max_results argument capped at
+// maxSearchResults.
+func resultLimit(args map[string]any, def int) int {
+	if v, ok := args["max_results"].(float64); ok && v >= 1 {
+		return min(int(v), maxSearchResults)
+	}
+	return def
+}
+
+// FindFilesTool lists files whose path matches a glob pattern.
+type FindFilesTool struct {
+	fs         fileSystem
+	defaultDir string
+}
+
+func NewFindFilesTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *FindFilesTool {
+	var patterns []*regexp.Regexp
+	if len(allowPaths) > 0 {
+		patterns = allowPaths[0]
+	}
+	return &FindFilesTool{
+		fs:         buildFs(workspace, restrict, patterns),
+		defaultDir: searchDefaultDir(workspace, restrict),
+	}
+}
+
+func (t *FindFilesTool) Name() string {
+	return "find_files"
+}
+
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *FindFilesTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *FindFilesTool) Description() string {
+	return "Find files by glob pattern, e.g. \"*.go\" or \"docs/**/*.md\". Patterns without a slash match file names at any depth."
+}
+
+func (t *FindFilesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Glob pattern; ** matches any number of directories",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search (default: the workspace)",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of files to return (default %d)", defaultFindResults),
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *FindFilesTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if _, err := path.Match(pattern, ""); err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+	}
+	dir, _ := args["path"].(string)
+	if dir == "" {
+		dir = t.defaultDir
+	}
+	limit := resultLimit(args, defaultFindResults)
+
+	var matches []string
+	err := walkFiles(ctx, t.fs, dir, func(p, rel string, _ fs.DirEntry) error {
+		if !matchGlob(pattern, rel) {
+			return nil
+		}
+		if len(matches) == limit {
+			return errSearchLimit
+		}
+		matches = append(matches, p)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSearchLimit) {
+		return ErrorResult(fmt.Sprintf("failed to search %s: %v", dir, err))
+	}
+
+	if len(matches) == 0 {
+		return NewToolResult(fmt.Sprintf("No files matching %q", pattern))
+	}
+	result := strings.Join(matches, "\n")
+	if errors.Is(err, errSearchLimit) {
+		result += fmt.Sprintf("\n... (stopped at %d results; narrow the pattern or path)", limit)
+	}
+	return NewToolResult(result)
+}
+
+// GrepFilesTool searches file contents with a regular expression.
+type GrepFilesTool struct {
+	fs         fileSystem
+	defaultDir string
+}
+
+func NewGrepFilesTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *GrepFilesTool {
+	var patterns []*regexp.Regexp
+	if len(allowPaths) > 0 {
+		patterns = allowPaths[0]
+	}
+	return &GrepFilesTool{
+		fs:         buildFs(workspace, restrict, patterns),
+		defaultDir: searchDefaultDir(workspace, restrict),
+	}
+}
+
+func (t *GrepFilesTool) Name() string {
+	return "grep_files"
+}
+
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *GrepFilesTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *GrepFilesTool) Description() string {
+	return "Search file contents with a regular expression (RE2 syntax). Returns matching lines as path:line:text, with optional context lines."
+}
+
+func (t *GrepFilesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Regular expression to search for",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File or directory to search (default: the workspace)",
+			},
+			"glob": map[string]any{
+				"type":        "string",
+				"description": "Only search files matching this glob, e.g. \"*.py\"",
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "Match case-insensitively",
+			},
+			"context": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Lines of context before and after each match (max %d)", maxGrepContext),
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of matching lines (default %d)", defaultGrepResults),
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GrepFilesTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	expr := pattern
+	if ignoreCase, _ := args["ignore_case"].(bool); ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+	glob, _ := args["glob"].(string)
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+	if _, err := path.Match(glob, ""); err != nil {
+		return ErrorResult(fmt.Sprintf("invalid glob %q: %v", glob, err))
+	}
+	contextLines := 0
+	if v, ok := args["context"].(float64); ok && v > 0 {
+		contextLines = min(int(v), maxGrepContext)
+	}
+	g := &grepper{re: re, context: contextLines, limit: resultLimit(args, defaultGrepResults)}
+
+	dir, _ := args["path"].(string)
+	if dir == "" {
+		dir = t.defaultDir
+	}
+
+	// A path that is not a directory is searched as a single file.
+	if _, dirErr := t.fs.ReadDir(dir); dirErr != nil {
+		content, err := t.fs.ReadFile(dir)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to search %s: %v", dir, dirErr))
+		}
+		_ = g.search(dir, content)
+	} else {
+		err = walkFiles(ctx, t.fs, dir, func(p, rel string, entry fs.DirEntry) error {
+			if glob != "" && !matchGlob(glob, rel) {
+				return nil
+			}
+			if info, err := entry.Info(); err != nil || info.Size() > maxGrepFileSize {
+				return nil
+			}
+			content, err := t.fs.ReadFile(p)
+			if err != nil {
+				return nil
+			}
+			return g.search(p, content)
+		})
+		if err != nil && !errors.Is(err, errSearchLimit) {
+			return ErrorResult(fmt.Sprintf("failed to search %s: %v", dir, err))
+		}
+	}
+
+	if g.matches == 0 {
+		return NewToolResult(fmt.Sprintf("No matches for %q", pattern))
+	}
+	result := strings.TrimRight(g.out.String(), "\n")
+	if g.truncated {
+		result += fmt.Sprintf("\n... (stopped at %d matches; narrow the pattern or path)", g.limit)
+	}
+	return NewToolResult(result)
+}
+
+// grepper formats matching lines in the style of grep -n: matches as
+// path:line:text, context lines as path-line-text, and "--" between
+// separate groups.
+type grepper struct {
+	re      *regexp.Regexp
+	context int
+	limit   int
+
+	matches   int
+	truncated bool
+	out       bytes.Buffer
+}
+
+// search appends the matches in content. It returns errSearchLimit when
+// a match beyond limit is found.
+func (g *grepper) search(p string, content []byte) error {
+	// Skip binary files, recognized like grep by a NUL byte near the start.
+	if bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	printed := -1 // index of the last line written for this file
+	for i, line := range lines {
+		if !g.re.MatchString(line) {
+			continue
+		}
+		if g.matches == g.limit {
+			g.truncated = true
+			return errSearchLimit
+		}
+		from := max(i-g.context, printed+1)
+		if g.context > 0 && g.out.Len() > 0 && (printed < 0 || from > printed+1) {
+			g.out.WriteString("--\n")
+		}
+		for k := from; k < i; k++ {
+			g.writeLine(p, k, '-', lines[k])
+		}
+		g.writeLine(p, i, ':', line)
+		printed = i
+		g.matches++
+		// Trailing context stops at the next match, which prints itself.
+		for k := i + 1; k < len(lines) && k <= i+g.context && !g.re.MatchString(lines[k]); k++ {
+			g.writeLine(p, k, '-', lines[k])
+			printed = k
+		}
+	}
+	return nil
+}
+
+func (g *grepper) writeLine(p string, i int, sep byte, line string) {
+	line = strings.TrimRight(line, "\r")
+	if len(line) > maxGrepLineLen {
+		line = line[:maxGrepLineLen] + "..."
+	}
+	fmt.Fprintf(&g.out, "%s%c%d%c%s\n", p, sep, i+1, sep, line)
+}
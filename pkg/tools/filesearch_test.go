@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSearchTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/tools/shell.go", true},
+		{"*.go", "main.go.txt", false},
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/**/*.md", "docs/guide/deep/intro.md", true},
+		{"**/test_*.py", "a/b/test_x.py", true},
+		{"**", "anything/at/all", true},
+		{"src/*", "lib/x", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.rel), "%s vs %s", tt.pattern, tt.rel)
+	}
+}
+
+func TestFindFilesTool(t *testing.T) {
+	workspace := writeSearchTree(t, map[string]string{
+		"main.go":              "package main",
+		"pkg/util/util.go":     "package util",
+		"pkg/util/README.md":   "# util",
+		"node_modules/x/x.go":  "ignored",
+		".git/hooks/commit.go": "ignored",
+	})
+	tool := NewFindFilesTool(workspace, true)
+
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "*.go"})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "main.go\n"+filepath.Join("pkg", "util", "util.go"), result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "*.md", "path": "pkg"})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, filepath.Join("pkg", "util", "README.md"), result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "*", "max_results": float64(1)})
+	assert.Contains(t, result.ForLLM, "stopped at 1 results")
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "*.rs"})
+	assert.Contains(t, result.ForLLM, "No files matching")
+}
+
+func TestFindFilesTool_RestrictedToWorkspace(t *testing.T) {
+	workspace := writeSearchTree(t, map[string]string{"a.txt": "a"})
+	outside := writeSearchTree(t, map[string]string{"secret.txt": "s"})
+
+	result := NewFindFilesTool(workspace, true).Execute(context.Background(),
+		map[string]any{"pattern": "*.txt", "path": outside})
+	assert.True(t, result.IsError, result.ForLLM)
+
+	// Whitelisted paths outside the workspace are searchable like read_file.
+	allow := []*regexp.Regexp{regexp.MustCompile("^" + regexp.QuoteMeta(outside))}
+	result = NewFindFilesTool(workspace, true, allow).Execute(context.Background(),
+		map[string]any{"pattern": "*.txt", "path": outside})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, filepath.Join(outside, "secret.txt"), result.ForLLM)
+}
+
+func TestGrepFilesTool(t *testing.T) {
+	workspace := writeSearchTree(t, map[string]string{
+		"a.txt":   "one\ntwo\nneedle here\nfour\nfive\nsix\nseven\nNeedle again\n",
+		"b.go":    "// needle in go\n",
+		"bin.dat": "needle\x00binary",
+	})
+	tool := NewGrepFilesTool(workspace, true)
+
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "needle"})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "a.txt:3:needle here\nb.go:1:// needle in go", result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"pattern": "needle", "ignore_case": true, "context": float64(1), "glob": "*.txt",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, strings.Join([]string{
+		"a.txt-2-two",
+		"a.txt:3:needle here",
+		"a.txt-4-four",
+		"--",
+		"a.txt-7-seven",
+		"a.txt:8:Needle again",
+	}, "\n"), result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"pattern": "needle", "ignore_case": true, "max_results": float64(1),
+	})
+	assert.Contains(t, result.ForLLM, "a.txt:3:needle here")
+	assert.Contains(t, result.ForLLM, "stopped at 1 matches")
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "two", "path": "a.txt"})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "a.txt:2:two", result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "("})
+	assert.True(t, result.IsError)
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "x", "path": "../"})
+	assert.True(t, result.IsError)
+}
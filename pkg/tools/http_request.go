@@ -0,0 +1,415 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultHTTPRequestTimeout   = 30 * time.Second
+	defaultHTTPResponseLimit    = 1024 * 1024
+	httpRequestRedirectsAllowed = 5
+)
+
+// reSecretRef matches secret references such as {{secret:HA_TOKEN}}.
+var reSecretRef = regexp.MustCompile(`\{\{\s*secret:([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Response headers worth showing; the rest is noise for the model.
+var httpResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Location", "Retry-After", "ETag", "Last-Modified",
+	"WWW-Authenticate", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+}
+
+// HTTPSecret is a credential the http_request tool injects in place of
+// {{secret:NAME}} references. It is only sent to Hosts: host names,
+// "*.example.lan" for subdomains, or "*" for any host.
+type HTTPSecret struct {
+	Value string
+	Hosts []string
+}
+
+// HTTPRequestToolOptions configures NewHTTPRequestTool.
+type HTTPRequestToolOptions struct {
+	Timeout          time.Duration
+	MaxResponseBytes int64
+	Proxy            string
+	Secrets          map[string]HTTPSecret
+	// TrustedHosts are exempt from the block on loopback, private and
+	// metadata addresses, as for web_fetch. Hosts of secrets are trusted
+	// too, since those are the internal services the secrets are for.
+	TrustedHosts []string
+}
+
+// HTTPRequestTool calls HTTP APIs with arbitrary methods, headers and
+// bodies. Credentials stay in the config: the model references them by
+// name, they are injected when the request is sent, and their values are
+// redacted from everything returned.
+type HTTPRequestTool struct {
+	client           *http.Client
+	guard            *ssrfGuard
+	secrets          map[string]HTTPSecret
+	maxResponseBytes int64
+}
+
+func NewHTTPRequestTool(opts HTTPRequestToolOptions) (*HTTPRequestTool, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPRequestTimeout
+	}
+	client, err := createHTTPClient(opts.Proxy, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client for http_request: %w", err)
+	}
+
+	client.CheckRedirect = checkSecretRedirect
+
+	trusted := slices.Clone(opts.TrustedHosts)
+	for name, secret := range opts.Secrets {
+		if secret.Value == "" {
+			return nil, fmt.Errorf("secret %q has no value", name)
+		}
+		for _, host := range secret.Hosts {
+			if host != "*" {
+				trusted = append(trusted, host)
+			}
+		}
+	}
+	guard, err := newSSRFGuard(trusted)
+	if err != nil {
+		return nil, err
+	}
+	guard.install(client)
+
+	maxResponseBytes := opts.MaxResponseBytes
+	if maxResponseBytes <= 0 {
+		maxResponseBytes = defaultHTTPResponseLimit
+	}
+	return &HTTPRequestTool{
+		client:           client,
+		guard:            guard,
+		secrets:          opts.Secrets,
+		maxResponseBytes: maxResponseBytes,
+	}, nil
+}
+
+func (t *HTTPRequestTool) Name() string {
+	return "http_request"
+}
+
+func (t *HTTPRequestTool) Description() string {
+	desc := "Send an HTTP request to a REST API and return the status, main headers and body. " +
+		"Use this instead of curl. Reference credentials as {{secret:NAME}} in the URL, header values or body; " +
+		"they are filled in when the request is sent and are never shown."
+	if names := t.secretNames(); len(names) > 0 {
+		desc += " Available secrets: " + strings.Join(names, ", ") + "."
+	}
+	return desc
+}
+
+func (t *HTTPRequestTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"method": map[string]any{
+				"type":        "string",
+				"description": "HTTP method (default GET)",
+				"enum":        []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+			},
+			"url": map[string]any{
+				"type":        "string",
+				"description": "Request URL including any query string",
+			},
+			"headers": map[string]any{
+				"type":                 "object",
+				"description":          "Request headers, e.g. {\"Authorization\": \"Bearer {{secret:HA_TOKEN}}\"}",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+			"json": map[string]any{
+				"description": "JSON request body; sets Content-Type: application/json",
+			},
+			"body": map[string]any{
+				"type":        "string",
+				"description": "Raw request body, when not sending json",
+			},
+			"max_response_bytes": map[string]any{
+				"type":        "integer",
+				"description": "Read at most this many bytes of the response body",
+				"minimum":     1.0,
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+func (t *HTTPRequestTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	rawURL, _ := args["url"].(string)
+	if rawURL == "" {
+		return ErrorResult("url is required")
+	}
+	method := http.MethodGet
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = strings.ToUpper(m)
+	}
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodHead, http.MethodOptions:
+	default:
+		return ErrorResult(fmt.Sprintf("unsupported method %q", method))
+	}
+
+	used := make(map[string]bool)
+	// inject replaces secret references in s with the secret values,
+	// escaped by escape if it is set.
+	inject := func(s string, escape func(string) string) (string, error) {
+		var missing string
+		out := reSecretRef.ReplaceAllStringFunc(s, func(ref string) string {
+			name := reSecretRef.FindStringSubmatch(ref)[1]
+			secret, ok := t.secrets[name]
+			if !ok {
+				missing = name
+				return ref
+			}
+			used[name] = true
+			if escape != nil {
+				return escape(secret.Value)
+			}
+			return secret.Value
+		})
+		if missing != "" {
+			if names := t.secretNames(); len(names) > 0 {
+				return "", fmt.Errorf("unknown secret %q; configured secrets: %s", missing, strings.Join(names, ", "))
+			}
+			return "", fmt.Errorf("unknown secret %q; no secrets are configured", missing)
+		}
+		return out, nil
+	}
+
+	urlStr, err := inject(rawURL, nil)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("invalid URL: %v", err)))
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return ErrorResult("only http/https URLs are allowed")
+	}
+	if parsedURL.Host == "" {
+		return ErrorResult("missing host in URL")
+	}
+
+	var body io.Reader
+	contentType := ""
+	if payload, ok := args["json"]; ok && payload != nil {
+		// Models sometimes pass the JSON document as a string.
+		if s, isString := payload.(string); isString && json.Valid([]byte(s)) {
+			payload = json.RawMessage(s)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("invalid json body: %v", err))
+		}
+		injected, err := inject(string(data), jsonEscape)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		body = strings.NewReader(injected)
+		contentType = "application/json"
+	} else if s, ok := args["body"].(string); ok && s != "" {
+		injected, err := inject(s, nil)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		body = strings.NewReader(injected)
+	}
+
+	headers := make(http.Header)
+	if h, ok := args["headers"].(map[string]any); ok {
+		for key, v := range h {
+			value, err := inject(fmt.Sprint(v), nil)
+			if err != nil {
+				return ErrorResult(err.Error())
+			}
+			headers.Set(key, value)
+		}
+	}
+
+	for name := range used {
+		if !secretAllowed(t.secrets[name], parsedURL.Hostname()) {
+			return ErrorResult(fmt.Sprintf("secret %q may not be sent to %s; allowed hosts: %s",
+				name, parsedURL.Hostname(), strings.Join(t.secrets[name].Hosts, ", ")))
+		}
+	}
+	if err := t.guard.checkURL(ctx, parsedURL); err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, body)
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("failed to create request: %v", err)))
+	}
+	req.Header = headers
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "picoclaw-http-request")
+	}
+	if len(used) > 0 {
+		req = req.WithContext(withRedirectSecrets(req.Context(), t.secretsFor(used)))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if blocked, ok := blockedError(err); ok {
+			return ErrorResult(blocked.Error())
+		}
+		return ErrorResult(t.redact(fmt.Sprintf("request failed: %v", err)))
+	}
+	defer resp.Body.Close()
+
+	limit := t.maxResponseBytes
+	if n, ok := args["max_response_bytes"].(float64); ok && n >= 1 && int64(n) < limit {
+		limit = int64(n)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("failed to read response: %v", err)))
+	}
+	truncated := int64(len(data)) > limit
+	if truncated {
+		data = data[:limit]
+	}
+
+	return SilentResult(t.redact(formatHTTPResponse(resp, data, truncated)))
+}
+
+// jsonEscape escapes s for use inside a JSON string.
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// formatHTTPResponse renders the status line, the main headers and the
+// body, pretty-printing JSON and summarizing binary content.
+func formatHTTPResponse(resp *http.Response, data []byte, truncated bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\n", resp.Proto, resp.Status)
+	for _, key := range httpResponseHeaders {
+		if v := resp.Header.Get(key); v != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", key, v)
+		}
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return strings.TrimSuffix(sb.String(), "\n")
+	}
+	sb.WriteString("\n")
+
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	switch {
+	case len(data) == 0:
+		sb.WriteString("(empty body)")
+	case !utf8.Valid(data) && !truncated:
+		fmt.Fprintf(&sb, "(binary body, %d bytes)", len(data))
+	case strings.Contains(contentType, "json") && !truncated:
+		var indented bytes.Buffer
+		if json.Indent(&indented, data, "", "  ") == nil {
+			sb.Write(indented.Bytes())
+		} else {
+			sb.Write(data)
+		}
+	default:
+		sb.WriteString(strings.ToValidUTF8(string(data), "�"))
+	}
+	if truncated {
+		fmt.Fprintf(&sb, "\n\n[response truncated after %d bytes]", len(data))
+	}
+	return sb.String()
+}
+
+// redact replaces the value of every configured secret in s with its
+// reference, longest values first so that overlapping secrets are hidden.
+func (t *HTTPRequestTool) redact(s string) string {
+	names := t.secretNames()
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(t.secrets[names[i]].Value) > len(t.secrets[names[j]].Value)
+	})
+	for _, name := range names {
+		value := t.secrets[name].Value
+		ref := "{{secret:" + name + "}}"
+		s = strings.ReplaceAll(s, value, ref)
+		for _, encoded := range []string{url.QueryEscape(value), jsonEscape(value)} {
+			if encoded != value {
+				s = strings.ReplaceAll(s, encoded, ref)
+			}
+		}
+	}
+	return s
+}
+
+func (t *HTTPRequestTool) secretNames() []string {
+	names := make([]string, 0, len(t.secrets))
+	for name := range t.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *HTTPRequestTool) secretsFor(used map[string]bool) []HTTPSecret {
+	secrets := make([]HTTPSecret, 0, len(used))
+	for name := range used {
+		secrets = append(secrets, t.secrets[name])
+	}
+	return secrets
+}
+
+// secretAllowed reports whether secret may be sent to host.
+func secretAllowed(secret HTTPSecret, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, allowed := range secret.Hosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		switch {
+		case allowed == "*", allowed == host:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+type redirectSecretsKey struct{}
+
+// withRedirectSecrets records the secrets a request carries, so redirects
+// to hosts they may not be sent to are not followed.
+func withRedirectSecrets(ctx context.Context, secrets []HTTPSecret) context.Context {
+	return context.WithValue(ctx, redirectSecretsKey{}, secrets)
+}
+
+// checkSecretRedirect stops redirects that would carry secrets to hosts
+// they are not allowed for; the redirect response is returned instead.
+func checkSecretRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= httpRequestRedirectsAllowed {
+		return fmt.Errorf("stopped after %d redirects", httpRequestRedirectsAllowed)
+	}
+	secrets, _ := req.Context().Value(redirectSecretsKey{}).([]HTTPSecret)
+	for _, secret := range secrets {
+		if !secretAllowed(secret, req.URL.Hostname()) {
+			return http.ErrUseLastResponse
+		}
+	}
+	return nil
+}
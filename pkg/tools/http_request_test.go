@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHAToken = `s3cr3t-"token"`
+
+func newTestHTTPRequestTool(t *testing.T, maxResponseBytes int64) *HTTPRequestTool {
+	t.Helper()
+	tool, err := NewHTTPRequestTool(HTTPRequestToolOptions{
+		MaxResponseBytes: maxResponseBytes,
+		Secrets: map[string]HTTPSecret{
+			"HA_TOKEN": {Value: testHAToken, Hosts: []string{"127.0.0.1"}},
+			"OTHER":    {Value: "elsewhere-key", Hosts: []string{"api.example.com"}},
+		},
+		TrustedHosts: []string{"localhost"},
+	})
+	require.NoError(t, err)
+	return tool
+}
+
+func TestHTTPRequestTool_InjectsAndRedactsSecrets(t *testing.T) {
+	var gotAuth, gotBody, gotType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"echo":"` + strings.ReplaceAll(r.Header.Get("Authorization"), `"`, `\"`) + `"}`))
+	}))
+	defer server.Close()
+
+	tool := newTestHTTPRequestTool(t, 0)
+	result := tool.Execute(context.Background(), map[string]any{
+		"method":  "post",
+		"url":     server.URL + "/api/services/light/turn_on",
+		"headers": map[string]any{"Authorization": "Bearer {{secret:HA_TOKEN}}"},
+		"json":    map[string]any{"entity_id": "light.kitchen", "token": "{{ secret:HA_TOKEN }}"},
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.True(t, result.Silent)
+
+	assert.Equal(t, "Bearer "+testHAToken, gotAuth)
+	assert.Equal(t, "application/json", gotType)
+	assert.JSONEq(t, `{"entity_id":"light.kitchen","token":"s3cr3t-\"token\""}`, gotBody)
+
+	assert.Contains(t, result.ForLLM, "HTTP/1.1 200 OK")
+	assert.Contains(t, result.ForLLM, `"echo": "Bearer {{secret:HA_TOKEN}}"`)
+	assert.NotContains(t, result.ForLLM, "s3cr3t")
+}
+
+func TestHTTPRequestTool_SecretHostRestriction(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	tool := newTestHTTPRequestTool(t, 0)
+	result := tool.Execute(context.Background(), map[string]any{
+		"url":     server.URL,
+		"headers": map[string]any{"X-Api-Key": "{{secret:OTHER}}"},
+	})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, `secret "OTHER" may not be sent to 127.0.0.1`)
+
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL + "/?key={{secret:MISSING}}"})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, `unknown secret "MISSING"; configured secrets: HA_TOKEN, OTHER`)
+	assert.Zero(t, requests)
+}
+
+func TestHTTPRequestTool_DoesNotFollowRedirectsWithSecrets(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("X-Api-Key")
+	}))
+	defer other.Close()
+	redirect := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, redirect+"/steal", http.StatusFound)
+	}))
+	defer server.Close()
+
+	tool := newTestHTTPRequestTool(t, 0)
+	result := tool.Execute(context.Background(), map[string]any{
+		"url":     server.URL,
+		"headers": map[string]any{"X-Api-Key": "{{secret:HA_TOKEN}}"},
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "302 Found")
+	assert.Contains(t, result.ForLLM, "Location: "+redirect+"/steal")
+	assert.Empty(t, leaked)
+
+	// Without secrets the redirect is followed.
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "200 OK")
+}
+
+func TestHTTPRequestTool_ResponseLimitAndGuard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	tool := newTestHTTPRequestTool(t, 64)
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, strings.Repeat("x", 64)+"\n\n[response truncated after 64 bytes]")
+
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL, "max_response_bytes": 10.0})
+	assert.Contains(t, result.ForLLM, "[response truncated after 10 bytes]")
+
+	// Loopback is only reachable because a secret is scoped to it.
+	unguarded, err := NewHTTPRequestTool(HTTPRequestToolOptions{})
+	require.NoError(t, err)
+	result = unguarded.Execute(context.Background(), map[string]any{"url": server.URL})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "loopback address")
+
+	result = unguarded.Execute(context.Background(), map[string]any{"url": server.URL, "method": "TRACE"})
+	assert.True(t, result.IsError)
+}
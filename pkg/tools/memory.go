@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// MemorySearchTool lets the agent recall notes and past conversations on
+// demand instead of carrying all of them in the system prompt.
+type MemorySearchTool struct {
+	recall *memory.Recall
+}
+
+// NewMemorySearchTool creates a MemorySearchTool over recall.
+func NewMemorySearchTool(recall *memory.Recall) *MemorySearchTool {
+	return &MemorySearchTool{recall: recall}
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *MemorySearchTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *MemorySearchTool) Description() string {
+	return "Search long-term memory (MEMORY.md, daily notes and past conversations with the person you are talking to) by keywords. Use this to recall facts, preferences, decisions or earlier discussions before answering from scratch."
+}
+
+func (t *MemorySearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Keywords describing what to recall (e.g., 'alice birthday', 'router password')",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of results to return (1-20, default 5)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, ok := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if !ok || query == "" {
+		return ErrorResult("query is required and must be a non-empty string")
+	}
+
+	limit := 5
+	if l, ok := args["limit"].(float64); ok {
+		li := int(l)
+		if li >= 1 && li <= 20 {
+			limit = li
+		}
+	}
+
+	scope := memory.Scope{SessionKey: ToolSession(ctx), Person: ToolPerson(ctx)}
+	hits, err := t.recall.Search(ctx, scope, query, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("memory search failed: %v", err))
+	}
+	if len(hits) == 0 {
+		return SilentResult(fmt.Sprintf("No memories found for %q", query))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d memories for %q:\n", len(hits), query)
+	for i, h := range hits {
+		fmt.Fprintf(&sb, "\n%d. [%s, %s]\n%s\n", i+1, h.Source, h.Time.Format("2006-01-02"), utils.Truncate(h.Text, 1000))
+	}
+	return SilentResult(sb.String())
+}
+
+// MemoryNotes is the note storage memory_write appends to.
+type MemoryNotes interface {
+	AppendToday(content string) error
+	AppendLongTerm(content string) error
+	AppendProfile(personID, content string) error
+}
+
+// MemoryWriteTool lets the agent save facts to long-term memory or today's
+// daily note.
+type MemoryWriteTool struct {
+	notes MemoryNotes
+}
+
+// NewMemoryWriteTool creates a MemoryWriteTool appending to notes.
+func NewMemoryWriteTool(notes MemoryNotes) *MemoryWriteTool {
+	return &MemoryWriteTool{notes: notes}
+}
+
+func (t *MemoryWriteTool) Name() string {
+	return "memory_write"
+}
+
+func (t *MemoryWriteTool) Description() string {
+	return "Save something worth remembering. Use target 'profile' for facts and preferences about the person you are talking to (only shown in their conversations), 'long_term' for lasting facts shared by everyone (MEMORY.md) and 'daily' for events and progress of today (the daily note). Saved memories can be recalled later with memory_search."
+}
+
+func (t *MemoryWriteTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The text to remember, written so it makes sense on its own later",
+			},
+			"target": map[string]any{
+				"type":        "string",
+				"enum":        []string{"daily", "long_term", "profile"},
+				"description": "Where to save it (default 'daily')",
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *MemoryWriteTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	content, ok := args["content"].(string)
+	content = strings.TrimSpace(content)
+	if !ok || content == "" {
+		return ErrorResult("content is required and must be a non-empty string")
+	}
+
+	target, _ := args["target"].(string)
+	var err error
+	switch target {
+	case "", "daily":
+		target = "daily"
+		err = t.notes.AppendToday(content)
+	case "long_term":
+		err = t.notes.AppendLongTerm(content)
+	case "profile":
+		err = t.notes.AppendProfile(ToolPerson(ctx), content)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown target %q, use 'daily', 'long_term' or 'profile'", target))
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save memory: %v", err))
+	}
+	return SilentResult(fmt.Sprintf("Saved to %s memory", strings.ReplaceAll(target, "_", "-")))
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+type fakeNotes struct {
+	today, longTerm []string
+	profiles        map[string][]string
+}
+
+func (n *fakeNotes) AppendToday(content string) error {
+	n.today = append(n.today, content)
+	return nil
+}
+
+func (n *fakeNotes) AppendLongTerm(content string) error {
+	n.longTerm = append(n.longTerm, content)
+	return nil
+}
+
+func (n *fakeNotes) AppendProfile(personID, content string) error {
+	if personID == "" {
+		return fmt.Errorf("no person to save a profile for")
+	}
+	if n.profiles == nil {
+		n.profiles = make(map[string][]string)
+	}
+	n.profiles[personID] = append(n.profiles[personID], content)
+	return nil
+}
+
+func TestMemorySearchTool(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MEMORY.md"),
+		[]byte("Alice's birthday is on 3 May.\n\nThe garage code is 4711."), 0o644))
+	tool := NewMemorySearchTool(memory.NewRecall(dir, nil))
+
+	result := tool.Execute(context.Background(), map[string]any{"query": "alice birthday"})
+	assert.False(t, result.IsError)
+	assert.True(t, result.Silent)
+	assert.Contains(t, result.ForLLM, "3 May")
+	assert.NotContains(t, result.ForLLM, "4711")
+
+	result = tool.Execute(context.Background(), map[string]any{"query": "spaceship"})
+	assert.Contains(t, result.ForLLM, "No memories found")
+
+	result = tool.Execute(context.Background(), map[string]any{"query": " "})
+	assert.True(t, result.IsError)
+}
+
+func TestMemorySearchTool_OtherPeoplesSessions(t *testing.T) {
+	store, err := memory.NewJSONLStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.AddMessage(ctx, "dm:alice", "user", "keep my job interview at Acme secret"))
+
+	recall := memory.NewRecall(t.TempDir(), store)
+	require.NoError(t, recall.AddSessionPerson("dm:alice", "telegram:alice"))
+	tool := NewMemorySearchTool(recall)
+
+	aliceCtx := WithToolPerson(WithToolSession(ctx, "dm:alice"), "telegram:alice")
+	result := tool.Execute(aliceCtx, map[string]any{"query": "acme interview"})
+	assert.Contains(t, result.ForLLM, "job interview")
+
+	bobCtx := WithToolPerson(WithToolSession(ctx, "dm:bob"), "telegram:bob")
+	result = tool.Execute(bobCtx, map[string]any{"query": "what did alice say about acme interview"})
+	assert.NotContains(t, result.ForLLM, "job interview")
+	assert.Contains(t, result.ForLLM, "No memories found")
+}
+
+func TestMemoryWriteTool(t *testing.T) {
+	notes := &fakeNotes{}
+	tool := NewMemoryWriteTool(notes)
+
+	result := tool.Execute(context.Background(), map[string]any{"content": "went hiking"})
+	assert.False(t, result.IsError)
+	result = tool.Execute(context.Background(), map[string]any{"content": "prefers tea", "target": "long_term"})
+	assert.False(t, result.IsError)
+	assert.Equal(t, []string{"went hiking"}, notes.today)
+	assert.Equal(t, []string{"prefers tea"}, notes.longTerm)
+
+	ctx := WithToolPerson(context.Background(), "telegram:42")
+	result = tool.Execute(ctx, map[string]any{"content": "is vegetarian", "target": "profile"})
+	assert.False(t, result.IsError)
+	assert.Equal(t, map[string][]string{"telegram:42": {"is vegetarian"}}, notes.profiles)
+	result = tool.Execute(context.Background(), map[string]any{"content": "x", "target": "profile"})
+	assert.True(t, result.IsError)
+
+	result = tool.Execute(context.Background(), map[string]any{"content": "x", "target": "forever"})
+	assert.True(t, result.IsError)
+	result = tool.Execute(context.Background(), map[string]any{})
+	assert.True(t, result.IsError)
+}
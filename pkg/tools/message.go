@@ -3,14 +3,17 @@ package tools
 import (
 	"context"
 	"fmt"
-	"sync/atomic"
+	"sync"
 )
 
 type SendCallback func(channel, chatID, content string) error
 
 type MessageTool struct {
 	sendCallback SendCallback
-	sentInRound  atomic.Bool // Tracks whether a message was sent in the current processing round
+	// sentInRound tracks, per originating "channel:chatID", whether a message
+	// was sent in the current processing round. Keyed because rounds for
+	// different sessions run concurrently on the same tool instance.
+	sentInRound sync.Map
 }
 
 func NewMessageTool() *MessageTool {
@@ -46,15 +49,18 @@ func (t *MessageTool) Parameters() map[string]any {
 	}
 }
 
-// ResetSentInRound resets the per-round send tracker.
+// ResetSentInRound resets the per-round send tracker for the round
+// originating from channel/chatID.
 // Called by the agent loop at the start of each inbound message processing round.
-func (t *MessageTool) ResetSentInRound() {
-	t.sentInRound.Store(false)
+func (t *MessageTool) ResetSentInRound(channel, chatID string) {
+	t.sentInRound.Delete(channel + ":" + chatID)
 }
 
-// HasSentInRound returns true if the message tool sent a message during the current round.
-func (t *MessageTool) HasSentInRound() bool {
-	return t.sentInRound.Load()
+// HasSentInRound returns true if the message tool sent a message during the
+// current round originating from channel/chatID.
+func (t *MessageTool) HasSentInRound(channel, chatID string) bool {
+	_, sent := t.sentInRound.Load(channel + ":" + chatID)
+	return sent
 }
 
 func (t *MessageTool) SetSendCallback(callback SendCallback) {
@@ -70,11 +76,12 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 	channel, _ := args["channel"].(string)
 	chatID, _ := args["chat_id"].(string)
 
+	originChannel, originChatID := ToolChannel(ctx), ToolChatID(ctx)
 	if channel == "" {
-		channel = ToolChannel(ctx)
+		channel = originChannel
 	}
 	if chatID == "" {
-		chatID = ToolChatID(ctx)
+		chatID = originChatID
 	}
 
 	if channel == "" || chatID == "" {
@@ -93,7 +100,7 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		}
 	}
 
-	t.sentInRound.Store(true)
+	t.sentInRound.Store(originChannel+":"+originChatID, struct{}{})
 	// Silent: user already received the message directly
 	return &ToolResult{
 		ForLLM: fmt.Sprintf("Message sent to %s:%s", channel, chatID),
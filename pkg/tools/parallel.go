@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// DefaultMaxParallelCalls bounds how many concurrency-safe tool calls of
+	// one LLM iteration run at the same time.
+	DefaultMaxParallelCalls = 4
+
+	// DefaultCallTimeout bounds a single tool call. Tools with their own,
+	// shorter timeouts (e.g. exec) still apply them.
+	DefaultCallTimeout = 5 * time.Minute
+)
+
+// ConcurrencySafe is an optional interface for tools whose calls have no side
+// effects that could conflict with other calls of the same LLM iteration, such
+// as read-only filesystem access or web requests. Calls to tools that do not
+// implement it, or report false, run alone and in the order the model
+// requested them.
+type ConcurrencySafe interface {
+	ConcurrencySafe() bool
+}
+
+// BatchOptions configures ExecuteBatch.
+type BatchOptions struct {
+	Channel string
+	ChatID  string
+
+	// MaxParallel bounds concurrently running calls; <= 0 uses DefaultMaxParallelCalls.
+	MaxParallel int
+	// Timeout bounds each call; <= 0 uses DefaultCallTimeout.
+	Timeout time.Duration
+	// AsyncCallback, if set, returns the completion callback passed to
+	// async tools for the given call.
+	AsyncCallback func(tc providers.ToolCall) AsyncCallback
+}
+
+// ExecuteBatch executes the tool calls of one LLM iteration and returns their
+// results in call order. Consecutive concurrency-safe calls run in parallel on
+// a bounded pool; any other call waits for the calls before it and runs alone,
+// so side effects keep the order the model asked for.
+func (r *ToolRegistry) ExecuteBatch(
+	ctx context.Context,
+	calls []providers.ToolCall,
+	opts BatchOptions,
+) []*ToolResult {
+	maxParallel := opts.MaxParallel
+	if maxParallel <= 0 {
+		maxParallel = DefaultMaxParallelCalls
+	}
+
+	results := make([]*ToolResult, len(calls))
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+
+	for i, tc := range calls {
+		if !r.isConcurrencySafe(tc.Name) {
+			wg.Wait()
+			results[i] = r.executeCall(ctx, tc, opts)
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(idx int, tc providers.ToolCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[idx] = r.executeCall(ctx, tc, opts)
+		}(i, tc)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (r *ToolRegistry) isConcurrencySafe(name string) bool {
+	tool, ok := r.Get(name)
+	if !ok {
+		return true // resolves to a "not found" error without side effects
+	}
+	cs, ok := tool.(ConcurrencySafe)
+	return ok && cs.ConcurrencySafe()
+}
+
+// executeCall runs one call under the per-call timeout. A tool that ignores
+// its context is abandoned once the timeout expires and reported as timed out.
+// The timeout starts once the call is approved: waiting for a human does not
+// count against the tool.
+func (r *ToolRegistry) executeCall(ctx context.Context, tc providers.ToolCall, opts BatchOptions) *ToolResult {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCallTimeout
+	}
+	ctx = WithToolContext(ctx, opts.Channel, opts.ChatID)
+	if denied := r.authorize(ctx, tc.Name, tc.Arguments); denied != nil {
+		return denied
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cb AsyncCallback
+	if opts.AsyncCallback != nil {
+		cb = opts.AsyncCallback(tc)
+	}
+
+	done := make(chan *ToolResult, 1)
+	go func() {
+		done <- r.execute(callCtx, tc.Name, tc.Arguments, cb)
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-callCtx.Done():
+	}
+
+	// Prefer the tool's own result if it returned right at the deadline,
+	// e.g. exec reporting a killed command.
+	select {
+	case result := <-done:
+		return result
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if ctx.Err() != nil {
+		return ErrorResult(fmt.Sprintf("tool %q canceled", tc.Name)).WithError(ctx.Err())
+	}
+	return ErrorResult(fmt.Sprintf("tool %q timed out after %s", tc.Name, timeout)).
+		WithError(callCtx.Err())
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// sleepTool sleeps for args["ms"] milliseconds, honoring ctx, and tracks how
+// many of its calls run at the same time.
+type sleepTool struct {
+	name string
+	safe bool
+
+	mu      sync.Mutex
+	running int
+	peak    int
+	order   []string
+}
+
+func (s *sleepTool) Name() string               { return s.name }
+func (s *sleepTool) Description() string        { return "sleeps" }
+func (s *sleepTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (s *sleepTool) ConcurrencySafe() bool      { return s.safe }
+
+func (s *sleepTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	s.mu.Lock()
+	s.running++
+	s.peak = max(s.peak, s.running)
+	s.order = append(s.order, args["id"].(string))
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	ms, _ := args["ms"].(int)
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return NewToolResult("done " + args["id"].(string))
+	case <-ctx.Done():
+		return ErrorResult("interrupted")
+	}
+}
+
+func sleepCalls(name string, n, ms int) []providers.ToolCall {
+	calls := make([]providers.ToolCall, n)
+	for i := range calls {
+		calls[i] = providers.ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      name,
+			Arguments: map[string]any{"id": fmt.Sprint(i), "ms": ms},
+		}
+	}
+	return calls
+}
+
+func TestExecuteBatch_SafeCallsRunInParallelInOrder(t *testing.T) {
+	tool := &sleepTool{name: "fetch", safe: true}
+	r := NewToolRegistry()
+	r.Register(tool)
+
+	// Later calls finish first; results must still follow call order.
+	calls := sleepCalls("fetch", 6, 0)
+	for i := range calls {
+		calls[i].Arguments["ms"] = 60 - i*10
+	}
+
+	start := time.Now()
+	results := r.ExecuteBatch(context.Background(), calls, BatchOptions{MaxParallel: 3})
+	elapsed := time.Since(start)
+
+	for i, res := range results {
+		if want := fmt.Sprintf("done %d", i); res.ForLLM != want {
+			t.Errorf("results[%d] = %q, want %q", i, res.ForLLM, want)
+		}
+	}
+	if tool.peak != 3 {
+		t.Errorf("peak concurrency = %d, want 3", tool.peak)
+	}
+	if elapsed >= 200*time.Millisecond {
+		t.Errorf("batch took %v, expected parallel execution", elapsed)
+	}
+}
+
+func TestExecuteBatch_UnsafeCallsRunAloneInOrder(t *testing.T) {
+	unsafe := &sleepTool{name: "write"}
+	safe := &sleepTool{name: "read", safe: true}
+	r := NewToolRegistry()
+	r.Register(unsafe)
+	r.Register(safe)
+
+	calls := []providers.ToolCall{
+		{ID: "1", Name: "write", Arguments: map[string]any{"id": "w1", "ms": 20}},
+		{ID: "2", Name: "write", Arguments: map[string]any{"id": "w2", "ms": 0}},
+		{ID: "3", Name: "read", Arguments: map[string]any{"id": "r1", "ms": 20}},
+		{ID: "4", Name: "write", Arguments: map[string]any{"id": "w3", "ms": 0}},
+	}
+	results := r.ExecuteBatch(context.Background(), calls, BatchOptions{})
+
+	if unsafe.peak != 1 {
+		t.Errorf("unsafe peak concurrency = %d, want 1", unsafe.peak)
+	}
+	if got := fmt.Sprint(unsafe.order); got != "[w1 w2 w3]" {
+		t.Errorf("unsafe call order = %s, want [w1 w2 w3]", got)
+	}
+	if results[2].ForLLM != "done r1" || results[3].ForLLM != "done w3" {
+		t.Errorf("unexpected results: %q, %q", results[2].ForLLM, results[3].ForLLM)
+	}
+}
+
+func TestExecuteBatch_PerCallTimeout(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(&sleepTool{name: "slow", safe: true})
+
+	calls := []providers.ToolCall{
+		{ID: "1", Name: "slow", Arguments: map[string]any{"id": "a", "ms": 5000}},
+		{ID: "2", Name: "slow", Arguments: map[string]any{"id": "b", "ms": 0}},
+	}
+	results := r.ExecuteBatch(context.Background(), calls, BatchOptions{Timeout: 50 * time.Millisecond})
+
+	if !results[0].IsError {
+		t.Errorf("expected timed out call to fail, got %q", results[0].ForLLM)
+	}
+	if results[1].IsError || results[1].ForLLM != "done b" {
+		t.Errorf("expected second call to succeed, got %q", results[1].ForLLM)
+	}
+}
+
+func TestExecuteBatch_AbandonsToolIgnoringContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	tool := newMockTool("stuck", "ignores ctx")
+	r := NewToolRegistry()
+	r.Register(&stuckTool{mockRegistryTool: *tool, block: block})
+
+	start := time.Now()
+	results := r.ExecuteBatch(context.Background(),
+		[]providers.ToolCall{{ID: "1", Name: "stuck", Arguments: map[string]any{}}},
+		BatchOptions{Timeout: 50 * time.Millisecond})
+
+	if time.Since(start) > time.Second {
+		t.Fatal("ExecuteBatch waited for a tool that ignores its context")
+	}
+	if !results[0].IsError || results[0].Err == nil {
+		t.Errorf("expected timeout error, got %+v", results[0])
+	}
+}
+
+type stuckTool struct {
+	mockRegistryTool
+	block chan struct{}
+}
+
+func (s *stuckTool) Execute(_ context.Context, _ map[string]any) *ToolResult {
+	<-s.block
+	return s.result
+}
@@ -0,0 +1,614 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPatchFuzz is how many context lines may be dropped from each end of
+// a hunk that does not match in full, like the fuzz factor of patch(1).
+const maxPatchFuzz = 2
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ApplyPatchTool applies a unified diff or a batch of find/replace edits
+// to one or more files. Either every change applies or no file is
+// touched.
+type ApplyPatchTool struct {
+	fs fileSystem
+}
+
+func NewApplyPatchTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *ApplyPatchTool {
+	var patterns []*regexp.Regexp
+	if len(allowPaths) > 0 {
+		patterns = allowPaths[0]
+	}
+	return &ApplyPatchTool{fs: buildFs(workspace, restrict, patterns)}
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Apply several changes to one or more files in one call, either as a unified diff " +
+		"(--- a/path / +++ b/path headers and @@ hunks; /dev/null creates or deletes a file) " +
+		"or as a list of exact find/replace edits. Hunks tolerate shifted line numbers and " +
+		"whitespace differences. All changes apply or none do."
+}
+
+func (t *ApplyPatchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "Unified diff to apply",
+			},
+			"edits": map[string]any{
+				"type":        "array",
+				"description": "Find/replace edits applied in order; each old_text must occur exactly once",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path":     map[string]any{"type": "string"},
+						"old_text": map[string]any{"type": "string"},
+						"new_text": map[string]any{"type": "string"},
+					},
+					"required": []string{"path", "old_text", "new_text"},
+				},
+			},
+		},
+	}
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	patch, _ := args["patch"].(string)
+	edits, _ := args["edits"].([]any)
+	if strings.TrimSpace(patch) == "" && len(edits) == 0 {
+		return ErrorResult("patch or edits is required")
+	}
+
+	tx := &patchTx{fs: t.fs}
+	var report []string
+	var failures []string
+
+	if strings.TrimSpace(patch) != "" {
+		files, err := parseUnifiedDiff(patch)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("invalid patch: %v", err))
+		}
+		for _, fp := range files {
+			notes, err := tx.applyFilePatch(fp)
+			if err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+			report = append(report, notes)
+		}
+	}
+
+	for i, raw := range edits {
+		edit, _ := raw.(map[string]any)
+		path, _ := edit["path"].(string)
+		oldText, okOld := edit["old_text"].(string)
+		newText, okNew := edit["new_text"].(string)
+		if path == "" || !okOld || !okNew {
+			failures = append(failures, fmt.Sprintf("edit %d: path, old_text and new_text are required", i+1))
+			continue
+		}
+		if err := tx.applyEdit(path, oldText, newText); err != nil {
+			failures = append(failures, fmt.Sprintf("edit %d (%s): %v", i+1, path, err))
+			continue
+		}
+		report = append(report, fmt.Sprintf("%s: edit %d applied", path, i+1))
+	}
+
+	if len(failures) > 0 {
+		return ErrorResult("Patch not applied; no files were changed.\n\n" + strings.Join(failures, "\n\n"))
+	}
+	if err := tx.commit(); err != nil {
+		return ErrorResult(err.Error())
+	}
+	return SilentResult("Patch applied:\n" + strings.Join(report, "\n"))
+}
+
+// patchTx holds the new content of every file a patch touches until all
+// changes have been computed, so that a failure leaves no file modified.
+type patchTx struct {
+	fs    fileSystem
+	files map[string]*patchFile
+	order []string
+}
+
+type patchFile struct {
+	original []byte
+	existed  bool
+	content  string
+	deleted  bool
+}
+
+// load returns the pending state of path, reading it on first use.
+func (tx *patchTx) load(path string) (*patchFile, error) {
+	if f, ok := tx.files[path]; ok {
+		return f, nil
+	}
+	data, err := tx.fs.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	f := &patchFile{original: data, existed: err == nil, content: string(data), deleted: err != nil}
+	if tx.files == nil {
+		tx.files = make(map[string]*patchFile)
+	}
+	tx.files[path] = f
+	tx.order = append(tx.order, path)
+	return f, nil
+}
+
+func (tx *patchTx) applyEdit(path, oldText, newText string) error {
+	f, err := tx.load(path)
+	if err != nil {
+		return err
+	}
+	if f.deleted {
+		return errors.New("file not found")
+	}
+	content, err := replaceEditContent([]byte(f.content), oldText, newText)
+	if err != nil {
+		return err
+	}
+	f.content = string(content)
+	return nil
+}
+
+// applyFilePatch applies the hunks of fp and returns a summary line.
+func (tx *patchTx) applyFilePatch(fp *filePatch) (string, error) {
+	path := fp.newPath
+	if path == "" {
+		path = fp.oldPath
+	}
+	f, err := tx.load(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	switch {
+	case fp.oldPath == "" && !f.deleted:
+		return "", fmt.Errorf("%s: the patch creates the file, but it already exists", path)
+	case fp.oldPath != "" && f.deleted:
+		return "", fmt.Errorf("%s: file not found", path)
+	}
+
+	if fp.newPath == "" && len(fp.hunks) == 0 {
+		f.content, f.deleted = "", true
+		return fmt.Sprintf("%s: deleted", path), nil
+	}
+	content, notes, err := applyHunks(f.content, fp.hunks)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+
+	if fp.newPath == "" {
+		if strings.TrimSpace(content) != "" {
+			return "", fmt.Errorf("%s: the patch deletes the file, but its hunks leave content behind", path)
+		}
+		f.content, f.deleted = "", true
+		return fmt.Sprintf("%s: deleted", path), nil
+	}
+	created := f.deleted
+	f.content, f.deleted = content, false
+
+	summary := fmt.Sprintf("%s: %d hunk(s) applied", path, len(fp.hunks))
+	if created {
+		summary = fmt.Sprintf("%s: created", path)
+	}
+	if len(notes) > 0 {
+		summary += "\n  " + strings.Join(notes, "\n  ")
+	}
+	return summary, nil
+}
+
+// commit writes all pending files. If a write fails, the files already
+// written are restored.
+func (tx *patchTx) commit() error {
+	var done []string
+	for _, path := range tx.order {
+		f := tx.files[path]
+		var err error
+		switch {
+		case f.deleted && f.existed:
+			err = tx.fs.Remove(path)
+		case f.deleted:
+			continue
+		case f.existed && f.content == string(f.original):
+			continue
+		default:
+			err = tx.fs.WriteFile(path, []byte(f.content))
+		}
+		if err != nil {
+			tx.rollback(done)
+			return fmt.Errorf("failed to write %s: %v; no files were changed", path, err)
+		}
+		done = append(done, path)
+	}
+	return nil
+}
+
+func (tx *patchTx) rollback(paths []string) {
+	for _, path := range paths {
+		f := tx.files[path]
+		if f.existed {
+			_ = tx.fs.WriteFile(path, f.original)
+		} else {
+			_ = tx.fs.Remove(path)
+		}
+	}
+}
+
+// filePatch is the part of a unified diff that changes one file. A path
+// is "" for /dev/null: oldPath for created files, newPath for deleted ones.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []*patchHunk
+}
+
+type patchHunk struct {
+	header   string
+	oldStart int // 1-based; 0 if the header carries no line numbers
+	lines    []hunkLine
+	oldNoEOL bool // "\ No newline at end of file" after the old side
+	newNoEOL bool // likewise for the new side
+}
+
+type hunkLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// parseUnifiedDiff splits a unified diff into per-file patches. Line
+// counts in hunk headers are not trusted, since models often get them
+// wrong; a hunk ends at the next hunk or file header.
+func parseUnifiedDiff(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []*filePatch
+	var cur *filePatch
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case isFileHeader(lines, i):
+			oldPath := parsePatchPath(strings.TrimPrefix(line, "--- "))
+			newPath := parsePatchPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			if oldPath == "" && newPath == "" {
+				return nil, fmt.Errorf("line %d: both sides are /dev/null", i+1)
+			}
+			// Strip the a/ and b/ prefixes of git diffs.
+			if (oldPath == "" || strings.HasPrefix(oldPath, "a/")) &&
+				(newPath == "" || strings.HasPrefix(newPath, "b/")) {
+				oldPath = strings.TrimPrefix(oldPath, "a/")
+				newPath = strings.TrimPrefix(newPath, "b/")
+			}
+			cur = &filePatch{oldPath: oldPath, newPath: newPath}
+			files = append(files, cur)
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk before any --- / +++ file header", i+1)
+			}
+			hunk := &patchHunk{header: line}
+			if m := hunkHeaderPattern.FindStringSubmatch(line); m != nil {
+				hunk.oldStart, _ = strconv.Atoi(m[1])
+			}
+			i = parseHunkBody(lines, i+1, hunk) - 1
+			if len(hunk.lines) == 0 {
+				return nil, fmt.Errorf("line %d: empty hunk", i+1)
+			}
+			cur.hunks = append(cur.hunks, hunk)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no --- / +++ file headers found")
+	}
+	for _, fp := range files {
+		if len(fp.hunks) == 0 && fp.newPath != "" {
+			return nil, fmt.Errorf("%s: no hunks", fp.newPath)
+		}
+	}
+	return files, nil
+}
+
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// parseHunkBody reads hunk lines starting at lines[i] and returns the index
+// of the first line after the hunk.
+func parseHunkBody(lines []string, i int, hunk *patchHunk) int {
+	blankTail := 0 // trailing lines that were empty rather than " "
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff ") || isFileHeader(lines, i) {
+			break
+		}
+		if line == "" {
+			// Editors and models often strip the space of empty context lines.
+			hunk.lines = append(hunk.lines, hunkLine{op: ' '})
+			blankTail++
+			continue
+		}
+		switch line[0] {
+		case ' ', '-', '+':
+			hunk.lines = append(hunk.lines, hunkLine{op: line[0], text: line[1:]})
+			blankTail = 0
+			continue
+		case '\\':
+			if n := len(hunk.lines); n > 0 {
+				switch hunk.lines[n-1].op {
+				case '-':
+					hunk.oldNoEOL = true
+				case '+':
+					hunk.newNoEOL = true
+				default:
+					hunk.oldNoEOL, hunk.newNoEOL = true, true
+				}
+			}
+			continue
+		}
+		break
+	}
+	hunk.lines = hunk.lines[:len(hunk.lines)-blankTail]
+	return i
+}
+
+// parsePatchPath extracts the path from a --- or +++ header, dropping a
+// trailing timestamp. It returns "" for /dev/null.
+func parsePatchPath(s string) string {
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab]
+	}
+	s = strings.TrimSpace(s)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// Match levels, from strictest to loosest.
+const (
+	matchExact = iota
+	matchTrailingSpace
+	matchSpace
+)
+
+func linesEqual(a, b string, level int) bool {
+	switch level {
+	case matchTrailingSpace:
+		return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t")
+	case matchSpace:
+		return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+	default:
+		return a == b
+	}
+}
+
+// applyHunks applies hunks to content in order. Each hunk is placed where
+// its old lines match closest to the position its header names, loosening
+// whitespace and then dropping outer context lines if needed. notes
+// describe hunks that did not apply exactly as written.
+func applyHunks(content string, hunks []*patchHunk) (string, []string, error) {
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	endsWithNewline := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	var notes []string
+	var failures []string
+	delta, from := 0, 0
+	for n, hunk := range hunks {
+		// The header names the first old line, or for a hunk without old
+		// lines the line to insert after.
+		base := hunk.oldStart - 1
+		if hunkOldLen(hunk.lines) == 0 {
+			base = hunk.oldStart
+		}
+		expected := from
+		if hunk.oldStart > 0 {
+			expected = max(base+delta, from)
+		}
+
+		m, ok := findHunk(lines, hunk.lines, expected, from)
+		if !ok {
+			failures = append(failures, hunkFailure(n+1, hunk, lines, expected))
+			continue
+		}
+
+		body := hunk.lines[m.front : len(hunk.lines)-m.back]
+		var replaced []string
+		at := m.pos
+		for _, hl := range body {
+			switch hl.op {
+			case ' ':
+				// Keep the file's version of context lines.
+				replaced = append(replaced, lines[at])
+				at++
+			case '-':
+				at++
+			case '+':
+				replaced = append(replaced, hl.text)
+			}
+		}
+		lines = append(lines[:m.pos], append(replaced, lines[at:]...)...)
+
+		offset := 0
+		if hunk.oldStart > 0 {
+			offset = m.pos - m.front - (base + delta)
+			delta = m.pos - m.front - base + len(replaced) - (at - m.pos)
+		}
+		if offset != 0 || m.level > matchExact || m.front+m.back > 0 {
+			notes = append(notes, hunkNote(n+1, m, offset))
+		}
+		from = m.pos + len(replaced)
+
+		if hunk.newNoEOL {
+			endsWithNewline = false
+		} else if hunk.oldNoEOL {
+			endsWithNewline = true
+		}
+	}
+	if len(failures) > 0 {
+		return "", nil, errors.New(strings.Join(failures, "\n"))
+	}
+
+	if len(lines) == 0 {
+		return "", notes, nil
+	}
+	result := strings.Join(lines, eol)
+	if endsWithNewline {
+		result += eol
+	}
+	return result, notes, nil
+}
+
+func hunkOldLen(lines []hunkLine) int {
+	n := 0
+	for _, hl := range lines {
+		if hl.op != '+' {
+			n++
+		}
+	}
+	return n
+}
+
+// hunkMatch is where a hunk applies: its old lines, without front and back
+// context lines, start at lines[pos] when compared at level.
+type hunkMatch struct {
+	pos, level  int
+	front, back int
+}
+
+// findHunk locates the old lines of a hunk in lines at or after from,
+// preferring the strictest match and then the one closest to expected.
+func findHunk(lines []string, hunk []hunkLine, expected, from int) (hunkMatch, bool) {
+	lead, trail := 0, 0
+	for lead < len(hunk) && hunk[lead].op == ' ' {
+		lead++
+	}
+	for trail < len(hunk)-lead && hunk[len(hunk)-1-trail].op == ' ' {
+		trail++
+	}
+
+	prevFront, prevBack := -1, -1
+	for fuzz := 0; fuzz <= maxPatchFuzz; fuzz++ {
+		front, back := min(fuzz, lead), min(fuzz, trail)
+		if front == prevFront && back == prevBack {
+			continue // no more context to drop
+		}
+		prevFront, prevBack = front, back
+		var old []string
+		for _, hl := range hunk[front : len(hunk)-back] {
+			if hl.op != '+' {
+				old = append(old, hl.text)
+			}
+		}
+		if len(old) == 0 {
+			if fuzz > 0 {
+				break
+			}
+			return hunkMatch{pos: min(max(expected, from), len(lines))}, true
+		}
+		for level := matchExact; level <= matchSpace; level++ {
+			best := -1
+			for pos := from; pos+len(old) <= len(lines); pos++ {
+				if !blockMatches(lines[pos:pos+len(old)], old, level) {
+					continue
+				}
+				if best < 0 || abs(pos-(expected+front)) < abs(best-(expected+front)) {
+					best = pos
+				}
+			}
+			if best >= 0 {
+				return hunkMatch{pos: best, level: level, front: front, back: back}, true
+			}
+		}
+	}
+	return hunkMatch{}, false
+}
+
+func blockMatches(lines, old []string, level int) bool {
+	for i := range old {
+		if !linesEqual(lines[i], old[i], level) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func hunkNote(n int, m hunkMatch, offset int) string {
+	var parts []string
+	if offset != 0 {
+		parts = append(parts, fmt.Sprintf("offset %+d lines", offset))
+	}
+	switch m.level {
+	case matchTrailingSpace:
+		parts = append(parts, "ignoring trailing whitespace")
+	case matchSpace:
+		parts = append(parts, "ignoring whitespace")
+	}
+	if m.front+m.back > 0 {
+		parts = append(parts, fmt.Sprintf("without %d context line(s)", m.front+m.back))
+	}
+	return fmt.Sprintf("hunk %d applied at line %d (%s)", n, m.pos+1, strings.Join(parts, ", "))
+}
+
+// hunkFailure explains why hunk n did not apply, showing the lines it
+// expected and what the file holds where the header points.
+func hunkFailure(n int, hunk *patchHunk, lines []string, expected int) string {
+	const show = 6
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "hunk %d (%s) failed: its context and removed lines were not found.\nExpected:\n", n, hunk.header)
+	shown := 0
+	for _, hl := range hunk.lines {
+		if hl.op == '+' {
+			continue
+		}
+		if shown == show {
+			sb.WriteString("  ...\n")
+			break
+		}
+		fmt.Fprintf(&sb, "  | %s\n", hl.text)
+		shown++
+	}
+	if len(lines) == 0 {
+		sb.WriteString("The file is empty.")
+		return sb.String()
+	}
+	start := min(max(expected, 0), len(lines)-1)
+	fmt.Fprintf(&sb, "File at line %d:\n", start+1)
+	for i := start; i < min(start+show, len(lines)); i++ {
+		fmt.Fprintf(&sb, "  %d| %s\n", i+1, lines[i])
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestApplyPatchTool_UnifiedDiff(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "config.yaml"),
+		[]byte("name: demo\nport: 8080\ndebug: false\nworkers: 2\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "old.txt"), []byte("bye\n"), 0o644))
+	tool := NewApplyPatchTool(workspace, true)
+
+	patch := `diff --git a/config.yaml b/config.yaml
+--- a/config.yaml
++++ b/config.yaml
+@@ -1,3 +1,3 @@
+ name: demo
+-port: 8080
++port: 9090
+ debug: false
+@@ -4,1 +4,2 @@
+ workers: 2
++timeout: 30
+--- /dev/null
++++ b/notes/new.md
+@@ -0,0 +1,2 @@
++# Notes
++hello
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "config.yaml: 2 hunk(s) applied")
+	assert.Contains(t, result.ForLLM, "notes/new.md: created")
+	assert.Contains(t, result.ForLLM, "old.txt: deleted")
+
+	assert.Equal(t, "name: demo\nport: 9090\ndebug: false\nworkers: 2\ntimeout: 30\n",
+		readTestFile(t, filepath.Join(workspace, "config.yaml")))
+	assert.Equal(t, "# Notes\nhello\n", readTestFile(t, filepath.Join(workspace, "notes", "new.md")))
+	_, err := os.Stat(filepath.Join(workspace, "old.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestApplyPatchTool_FuzzyMatching(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "run.sh")
+	original := "#!/bin/sh\n# added header\n# another line\nset -e\n\techo start  \nmake build\necho done\n"
+	require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+	tool := NewApplyPatchTool(workspace, true)
+
+	// Wrong line numbers, lost indentation and a stale first context line.
+	patch := `--- a/run.sh
++++ b/run.sh
+@@ -1,4 +1,4 @@
+ #!/bin/bash
+ set -e
+ echo start
+-make build
++make build test
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "hunk 1 applied at line 4")
+	assert.Contains(t, result.ForLLM, "ignoring whitespace")
+	assert.Contains(t, result.ForLLM, "without 1 context line(s)")
+	// Context lines keep the file's own whitespace.
+	assert.Equal(t, strings.Replace(original, "make build\n", "make build test\n", 1), readTestFile(t, path))
+}
+
+func TestApplyPatchTool_AllOrNothing(t *testing.T) {
+	workspace := t.TempDir()
+	a := filepath.Join(workspace, "a.txt")
+	b := filepath.Join(workspace, "b.txt")
+	require.NoError(t, os.WriteFile(a, []byte("alpha\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("beta\ngamma\n"), 0o644))
+	tool := NewApplyPatchTool(workspace, true)
+
+	patch := `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ beta
+-delta
++DELTA
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "no files were changed")
+	assert.Contains(t, result.ForLLM, "b.txt: hunk 1 (@@ -1,2 +1,2 @@) failed")
+	assert.Contains(t, result.ForLLM, "  | delta")
+	assert.Contains(t, result.ForLLM, "  1| beta")
+	assert.Equal(t, "alpha\n", readTestFile(t, a))
+	assert.Equal(t, "beta\ngamma\n", readTestFile(t, b))
+}
+
+func TestApplyPatchTool_Edits(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "app.ini")
+	require.NoError(t, os.WriteFile(path, []byte("[server]\nhost=localhost\nport=80\n"), 0o644))
+	tool := NewApplyPatchTool(workspace, true)
+
+	result := tool.Execute(context.Background(), map[string]any{"edits": []any{
+		map[string]any{"path": "app.ini", "old_text": "host=localhost", "new_text": "host=0.0.0.0"},
+		map[string]any{"path": "app.ini", "old_text": "port=80", "new_text": "port=8080"},
+	}})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "[server]\nhost=0.0.0.0\nport=8080\n", readTestFile(t, path))
+
+	result = tool.Execute(context.Background(), map[string]any{"edits": []any{
+		map[string]any{"path": "app.ini", "old_text": "port=8080", "new_text": "port=1"},
+		map[string]any{"path": "app.ini", "old_text": "missing", "new_text": "x"},
+	}})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "edit 2 (app.ini): old_text not found")
+	assert.Equal(t, "[server]\nhost=0.0.0.0\nport=8080\n", readTestFile(t, path))
+}
+
+func TestApplyPatchTool_RestrictedToWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewApplyPatchTool(workspace, true)
+
+	patch := "--- /dev/null\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+x\n"
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	assert.True(t, result.IsError, result.ForLLM)
+	_, err := os.Stat(filepath.Join(filepath.Dir(workspace), "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestApplyHunks_LineEndings(t *testing.T) {
+	hunks := []*patchHunk{{
+		header:   "@@ -1,2 +1,2 @@",
+		oldStart: 1,
+		lines:    []hunkLine{{' ', "a"}, {'-', "b"}, {'+', "B"}},
+		oldNoEOL: true,
+		newNoEOL: true,
+	}}
+	got, _, err := applyHunks("a\r\nb", hunks)
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\nB", got)
+
+	hunks[0].newNoEOL = false
+	got, _, err = applyHunks("a\r\nb", hunks)
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\nB\r\n", got)
+}
+
+func TestParseUnifiedDiff_Errors(t *testing.T) {
+	for _, patch := range []string{
+		"just some text",
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"--- a/x\n+++ b/x\n",
+	} {
+		_, err := parseUnifiedDiff(patch)
+		assert.Error(t, err, patch)
+	}
+}
@@ -12,8 +12,9 @@ import (
 )
 
 type ToolRegistry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools    map[string]Tool
+	approval *ApprovalPolicy
+	mu       sync.RWMutex
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -40,6 +41,14 @@ func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	return tool, ok
 }
 
+// SetApprovalPolicy installs a policy that pauses matching tool calls until a
+// human approves them. A nil policy disables approvals.
+func (r *ToolRegistry) SetApprovalPolicy(p *ApprovalPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approval = p
+}
+
 func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string]any) *ToolResult {
 	return r.ExecuteWithContext(ctx, name, args, "", "", nil)
 }
@@ -54,6 +63,35 @@ func (r *ToolRegistry) ExecuteWithContext(
 	args map[string]any,
 	channel, chatID string,
 	asyncCallback AsyncCallback,
+) *ToolResult {
+	// Inject channel/chatID into ctx so tools read them via ToolChannel(ctx)/ToolChatID(ctx).
+	// Always inject — tools validate what they require.
+	ctx = WithToolContext(ctx, channel, chatID)
+	if denied := r.authorize(ctx, name, args); denied != nil {
+		return denied
+	}
+	return r.execute(ctx, name, args, asyncCallback)
+}
+
+// authorize asks the approval policy, if any, whether the call may run. It
+// returns nil if so, or the result to hand back in place of the tool's.
+func (r *ToolRegistry) authorize(ctx context.Context, name string, args map[string]any) *ToolResult {
+	r.mu.RLock()
+	approval := r.approval
+	_, ok := r.tools[name]
+	r.mu.RUnlock()
+	if approval == nil || !ok {
+		return nil
+	}
+	return approval.authorize(ctx, name, args)
+}
+
+// execute runs an authorized call. ctx already carries the tool context.
+func (r *ToolRegistry) execute(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	asyncCallback AsyncCallback,
 ) *ToolResult {
 	logger.InfoCF("tool", "Tool execution started",
 		map[string]any{
@@ -70,10 +108,6 @@ func (r *ToolRegistry) ExecuteWithContext(
 		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
 	}
 
-	// Inject channel/chatID into ctx so tools read them via ToolChannel(ctx)/ToolChatID(ctx).
-	// Always inject — tools validate what they require.
-	ctx = WithToolContext(ctx, channel, chatID)
-
 	// If tool implements AsyncExecutor and callback is provided, use ExecuteAsync.
 	// The callback is a call parameter, not mutable state on the tool instance.
 	var result *ToolResult
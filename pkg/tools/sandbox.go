@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// SandboxPolicy is what a sandboxed command may do.
+type SandboxPolicy struct {
+	ReadPaths    []string // readable besides the system directories
+	WritePaths   []string // readable and writable
+	AllowNetwork bool
+	MaxMemory    uint64        // bytes of address space per process, 0 for no limit
+	MaxCPU       time.Duration // CPU time per process, 0 for no limit
+	MaxProcesses int           // processes of the user, 0 for no limit
+}
+
+// Sandbox confines the commands of the exec tool.
+type Sandbox interface {
+	Name() string
+	// Prepare rewrites cmd, which has not been started, to run confined.
+	// cleanup must be called once cmd has exited.
+	Prepare(cmd *exec.Cmd) (cleanup func(), err error)
+}
+
+// NewSandbox creates the sandbox selected by cfg for commands run in
+// workspace. It returns nil if sandboxing is disabled.
+func NewSandbox(cfg config.ExecSandboxConfig, workspace string) (Sandbox, error) {
+	policy := SandboxPolicy{
+		AllowNetwork: cfg.AllowNetwork,
+		MaxMemory:    uint64(max(cfg.MaxMemoryMB, 0)) << 20,
+		MaxCPU:       time.Duration(max(cfg.MaxCPUSeconds, 0)) * time.Second,
+		MaxProcesses: max(cfg.MaxProcesses, 0),
+	}
+	for _, p := range cfg.ReadPaths {
+		policy.ReadPaths = append(policy.ReadPaths, expandPath(p))
+	}
+	if workspace != "" {
+		policy.WritePaths = append(policy.WritePaths, workspace)
+	}
+	for _, p := range cfg.WritePaths {
+		policy.WritePaths = append(policy.WritePaths, expandPath(p))
+	}
+
+	switch cfg.Backend {
+	case "", "none":
+		return nil, nil
+	case "linux":
+		return newLinuxSandbox(policy)
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q", cfg.Backend)
+	}
+}
+
+// expandPath makes p absolute, expanding a leading ~.
+func expandPath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
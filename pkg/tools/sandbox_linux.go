@@ -0,0 +1,349 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// The linux sandbox re-executes the picoclaw binary under these names. The
+// helper confines itself with Landlock, seccomp and resource limits, then
+// executes the real command; see runSandboxHelper.
+const (
+	sandboxHelperArg0 = "picoclaw-sandbox"
+	sandboxProbeArg0  = "picoclaw-sandbox-probe"
+	sandboxSpecEnv    = "PICOCLAW_SANDBOX_SPEC"
+)
+
+// sandboxReadPaths are readable by every sandboxed command so that shells,
+// interpreters and their libraries work. Missing paths are skipped.
+//
+// The sandbox does not mount its own procfs, so /proc as a whole would list
+// every host process with its command line and environment. Only the
+// system-wide files are readable, plus /proc/self, which resolves to the
+// helper and so to the command it executes; processes the command starts
+// cannot read their own /proc entries.
+var sandboxReadPaths = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc", "/opt", "/nix", "/sys",
+	"/proc/cpuinfo", "/proc/meminfo", "/proc/stat", "/proc/loadavg", "/proc/uptime",
+	"/proc/version", "/proc/filesystems", "/proc/sys", "/proc/self",
+}
+
+// sandboxDevices are the device files sandboxed commands may open.
+var sandboxDevices = []string{
+	"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty",
+}
+
+func init() {
+	if len(os.Args) == 0 {
+		return
+	}
+	switch os.Args[0] {
+	case sandboxHelperArg0:
+		runSandboxHelper()
+	case sandboxProbeArg0:
+		os.Exit(0)
+	}
+}
+
+// sandboxSpec is passed from the exec tool to the helper.
+type sandboxSpec struct {
+	Path         string   `json:"path"`
+	Args         []string `json:"args"`
+	ReadPaths    []string `json:"read_paths"`
+	WritePaths   []string `json:"write_paths"`
+	AllowNetwork bool     `json:"allow_network"`
+	MaxMemory    uint64   `json:"max_memory,omitempty"`
+	MaxCPU       uint64   `json:"max_cpu,omitempty"` // seconds
+	MaxProcesses uint64   `json:"max_processes,omitempty"`
+}
+
+// linuxSandbox runs commands in fresh user, PID, IPC, UTS and (unless
+// network is allowed) network namespaces, with filesystem access limited by
+// Landlock and dangerous syscalls blocked by seccomp. Namespaces are skipped
+// where unprivileged user namespaces are disabled; Landlock is required.
+type linuxSandbox struct {
+	policy SandboxPolicy
+	self   string
+
+	probe      sync.Once
+	probeErr   error
+	namespaces bool
+}
+
+func newLinuxSandbox(policy SandboxPolicy) (Sandbox, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: locate executable: %w", err)
+	}
+	return &linuxSandbox{policy: policy, self: self}, nil
+}
+
+func (s *linuxSandbox) Name() string {
+	return "linux"
+}
+
+func (s *linuxSandbox) Prepare(cmd *exec.Cmd) (func(), error) {
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	s.probe.Do(s.probeSupport)
+	if s.probeErr != nil {
+		return nil, s.probeErr
+	}
+
+	tmp, err := os.MkdirTemp("", "picoclaw-sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+
+	spec := sandboxSpec{
+		Path:         cmd.Path,
+		Args:         cmd.Args,
+		ReadPaths:    append(append([]string(nil), sandboxReadPaths...), s.policy.ReadPaths...),
+		WritePaths:   append(append([]string(nil), s.policy.WritePaths...), tmp),
+		AllowNetwork: s.policy.AllowNetwork,
+		MaxMemory:    s.policy.MaxMemory,
+		MaxCPU:       uint64(s.policy.MaxCPU.Seconds()),
+		MaxProcesses: uint64(s.policy.MaxProcesses),
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, "TMPDIR="+tmp, sandboxSpecEnv+"="+string(data))
+	cmd.Path = s.self
+	cmd.Args = []string{sandboxHelperArg0}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if s.namespaces {
+		s.setNamespaces(cmd.SysProcAttr)
+	}
+	return cleanup, nil
+}
+
+func (s *linuxSandbox) setNamespaces(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !s.policy.AllowNetwork {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// probeSupport checks once that the kernel supports Landlock and whether
+// unprivileged namespaces can be created.
+func (s *linuxSandbox) probeSupport() {
+	if landlockABI() < 1 {
+		s.probeErr = errors.New("sandbox: the kernel does not support Landlock (Linux 5.13+ with landlock in the lsm list)")
+		return
+	}
+
+	probe := &exec.Cmd{Path: s.self, Args: []string{sandboxProbeArg0}, SysProcAttr: &syscall.SysProcAttr{}}
+	s.setNamespaces(probe.SysProcAttr)
+	if err := probe.Run(); err != nil {
+		logger.WarnCF("tools", "Sandbox runs without namespaces",
+			map[string]any{"error": err.Error()})
+		if !s.policy.AllowNetwork && !seccompSupported {
+			s.probeErr = fmt.Errorf("sandbox: cannot block network on %s without namespaces: %w", runtime.GOARCH, err)
+		}
+		return
+	}
+	s.namespaces = true
+}
+
+// runSandboxHelper confines the process and executes the command of the
+// spec in its place. It never returns.
+func runSandboxHelper() {
+	// Landlock and seccomp restrict the calling thread, which then execs.
+	runtime.LockOSThread()
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		fail(fmt.Errorf("invalid spec: %w", err))
+	}
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, sandboxSpecEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_AS, spec.MaxMemory},
+		{unix.RLIMIT_CPU, spec.MaxCPU},
+		{unix.RLIMIT_NPROC, spec.MaxProcesses},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			fail(fmt.Errorf("set resource limit: %w", err))
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		fail(fmt.Errorf("set no_new_privs: %w", err))
+	}
+	if err := restrictFilesystem(spec.ReadPaths, spec.WritePaths); err != nil {
+		fail(err)
+	}
+	if err := installSeccomp(spec.AllowNetwork); err != nil {
+		fail(err)
+	}
+
+	err := syscall.Exec(spec.Path, spec.Args, env)
+	fail(fmt.Errorf("exec %s: %w", spec.Path, err))
+}
+
+// Landlock filesystem rights.
+const (
+	landlockFileRights = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	landlockReadRights = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockDeviceRights = unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+)
+
+// landlockABI returns the Landlock ABI version of the kernel, 0 if
+// Landlock is unavailable.
+func landlockABI() int {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(v)
+}
+
+// landlockHandled returns the filesystem rights a Landlock ABI version
+// knows about.
+func landlockHandled(abi int) uint64 {
+	rights := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		rights |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		rights |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		rights |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return rights
+}
+
+// restrictFilesystem limits the calling thread to reading readPaths and
+// reading and writing writePaths and a few device files.
+func restrictFilesystem(readPaths, writePaths []string) error {
+	abi := landlockABI()
+	if abi < 1 {
+		return errors.New("landlock is not supported")
+	}
+	handled := landlockHandled(abi)
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: create ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	for _, p := range readPaths {
+		if err := landlockAllow(ruleset, p, landlockReadRights&handled); err != nil {
+			return err
+		}
+	}
+	for _, p := range sandboxDevices {
+		if err := landlockAllow(ruleset, p, landlockDeviceRights&handled); err != nil {
+			return err
+		}
+	}
+	for _, p := range writePaths {
+		if err := landlockAllow(ruleset, p, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("landlock: restrict: %w", errno)
+	}
+	return nil
+}
+
+// landlockAllow grants rights below path. Missing paths are skipped.
+func landlockAllow(ruleset int, path string, rights uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EACCES) {
+			return nil
+		}
+		return fmt.Errorf("landlock: open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("landlock: stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		rights &= landlockFileRights
+	}
+
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: rights, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset),
+		unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: allow %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package tools
+
+import "golang.org/x/sys/unix"
+
+const (
+	seccompAuditArch = unix.AUDIT_ARCH_X86_64
+	seccompX32Bit    = 0x40000000 // x32 ABI syscalls share the arch value
+)
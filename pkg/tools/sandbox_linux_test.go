@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newSandboxedExecTool(t *testing.T, sandbox config.ExecSandboxConfig) (*ExecTool, string) {
+	t.Helper()
+	if landlockABI() < 1 {
+		t.Skip("landlock is not available")
+	}
+	workspace := t.TempDir()
+	cfg := config.DefaultConfig()
+	sandbox.Backend = "linux"
+	cfg.Tools.Exec.Sandbox = sandbox
+	tool, err := NewExecToolWithConfig(workspace, false, cfg)
+	require.NoError(t, err)
+	return tool, workspace
+}
+
+func TestLinuxSandbox_Filesystem(t *testing.T) {
+	tool, workspace := newSandboxedExecTool(t, config.ExecSandboxConfig{})
+	outside := t.TempDir()
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "echo inside > inside.txt && cat inside.txt",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "inside")
+	_, err := os.Stat(filepath.Join(workspace, "inside.txt"))
+	assert.NoError(t, err)
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"command": "echo outside > " + filepath.Join(outside, "outside.txt"),
+	})
+	assert.True(t, result.IsError, result.ForLLM)
+	_, err = os.Stat(filepath.Join(outside, "outside.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"command": `echo scratch > "$TMPDIR/scratch" && cat "$TMPDIR/scratch"`,
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "scratch")
+}
+
+func TestLinuxSandbox_Confinement(t *testing.T) {
+	tool, _ := newSandboxedExecTool(t, config.ExecSandboxConfig{MaxMemoryMB: 512})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		// The shell reads /proc/self itself; its children cannot.
+		"command": "while read -r line; do case $line in NoNewPrivs:*|Seccomp:*) echo \"$line\";; esac; done < /proc/self/status; ulimit -v",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "NoNewPrivs:\t1")
+	assert.Contains(t, result.ForLLM, "Seccomp:\t2")
+	assert.Contains(t, result.ForLLM, "524288")
+}
+
+func TestLinuxSandbox_ReadPaths(t *testing.T) {
+	shared := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(shared, "notes.txt"), []byte("shared notes"), 0o644))
+	tool, _ := newSandboxedExecTool(t, config.ExecSandboxConfig{ReadPaths: []string{shared}})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "cat " + filepath.Join(shared, "notes.txt"),
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "shared notes")
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"command": "touch " + filepath.Join(shared, "new.txt"),
+	})
+	assert.True(t, result.IsError, result.ForLLM)
+}
+
+func TestLinuxSandbox_HostProcesses(t *testing.T) {
+	tool, _ := newSandboxedExecTool(t, config.ExecSandboxConfig{})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "cat /proc/1/cmdline",
+	})
+	assert.True(t, result.IsError, result.ForLLM)
+}
@@ -0,0 +1,11 @@
+//go:build !amd64 && !arm64 && !riscv64
+
+package tools
+
+// Seccomp filters are only built for amd64, arm64 and riscv64. Elsewhere the
+// sandbox relies on Landlock and namespaces alone.
+const seccompSupported = false
+
+func installSeccomp(allowNetwork bool) error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package tools
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newLinuxSandbox(SandboxPolicy) (Sandbox, error) {
+	return nil, fmt.Errorf("the linux sandbox is not available on %s", runtime.GOOS)
+}
@@ -0,0 +1,138 @@
+//go:build amd64 || arm64 || riscv64
+
+package tools
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const seccompSupported = true
+
+// seccompDenied are syscalls no sandboxed command needs: they change the
+// system, escape or inspect other processes, or widen the kernel attack
+// surface. They fail with EPERM.
+var seccompDenied = []uint32{
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_CHROOT,
+	unix.SYS_UNSHARE,
+	unix.SYS_SETNS,
+	unix.SYS_REBOOT,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_KEYCTL,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_IO_URING_SETUP,
+}
+
+// seccompBlockedDomains are the socket families blocked without network
+// access. Unix sockets count as network: they reach local daemons such as
+// Docker.
+var seccompBlockedDomains = []uint32{unix.AF_INET, unix.AF_INET6, unix.AF_PACKET, unix.AF_UNIX}
+
+// seccompNamespaceFlags are the clone flags that create namespaces. clone
+// with any of them fails like unshare; with user namespaces it would give
+// the command capabilities over fresh mount and network namespaces.
+const seccompNamespaceFlags = unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWNET |
+	unix.CLONE_NEWPID | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS | unix.CLONE_NEWCGROUP
+
+// Offsets into struct seccomp_data.
+const (
+	seccompNrOffset   = 0
+	seccompArchOffset = 4
+	seccompArg0Offset = 16 // low 32 bits on little-endian
+)
+
+// seccompFilter builds a BPF program that denies seccompDenied, clone with
+// seccompNamespaceFlags and, unless allowNetwork is set, socket() for
+// seccompBlockedDomains. clone3 fails with ENOSYS: its flags are in memory
+// the filter cannot read, and libc falls back to clone.
+func seccompFilter(allowNetwork bool) []unix.SockFilter {
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	const (
+		load = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq  = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge  = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		jset = unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K
+		ret  = unix.BPF_RET | unix.BPF_K
+	)
+
+	prog := []unix.SockFilter{
+		// Kill anything not using the native syscall ABI.
+		stmt(load, seccompArchOffset),
+		jump(jeq, seccompAuditArch, 1, 0),
+		stmt(ret, unix.SECCOMP_RET_KILL_PROCESS),
+		stmt(load, seccompNrOffset),
+	}
+	if seccompX32Bit != 0 {
+		prog = append(prog,
+			jump(jge, seccompX32Bit, 0, 1),
+			stmt(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+		)
+	}
+	prog = append(prog,
+		jump(jeq, unix.SYS_CLONE3, 0, 1),
+		stmt(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+		// clone: deny namespace flags, allow the rest without further checks.
+		jump(jeq, unix.SYS_CLONE, 0, 4),
+		stmt(load, seccompArg0Offset),
+		jump(jset, seccompNamespaceFlags, 0, 1),
+		stmt(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM)),
+		stmt(ret, unix.SECCOMP_RET_ALLOW),
+	)
+	for _, nr := range seccompDenied {
+		prog = append(prog,
+			jump(jeq, nr, 0, 1),
+			stmt(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM)),
+		)
+	}
+	if !allowNetwork {
+		n := uint8(len(seccompBlockedDomains))
+		prog = append(prog,
+			jump(jeq, unix.SYS_SOCKET, 0, n+2),
+			stmt(load, seccompArg0Offset),
+		)
+		for i, domain := range seccompBlockedDomains {
+			// Jump to the deny below on a match; fall through otherwise.
+			left := n - 1 - uint8(i)
+			prog = append(prog, jump(jeq, domain, left, 0))
+		}
+		// No family matched: skip the deny.
+		prog[len(prog)-1].Jf = 1
+		prog = append(prog, stmt(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.EACCES)))
+	}
+	return append(prog, stmt(ret, unix.SECCOMP_RET_ALLOW))
+}
+
+// installSeccomp applies seccompFilter to the calling thread. no_new_privs
+// must already be set.
+func installSeccomp(allowNetwork bool) error {
+	filter := seccompFilter(allowNetwork)
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("seccomp: %w", err)
+	}
+	return nil
+}
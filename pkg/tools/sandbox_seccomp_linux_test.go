@@ -0,0 +1,43 @@
+//go:build amd64 || arm64 || riscv64
+
+package tools
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSeccompFilter_Namespaces(t *testing.T) {
+	type result struct {
+		err                 error
+		clone, clone3, fork unix.Errno
+	}
+	done := make(chan result, 1)
+	go func() {
+		// The filter applies to this thread only. The thread stays locked,
+		// so it exits with the goroutine.
+		runtime.LockOSThread()
+		var r result
+		if r.err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); r.err == nil {
+			r.err = installSeccomp(true)
+		}
+		if r.err == nil {
+			// Both flag sets are invalid, so the kernel fails them with
+			// EINVAL instead of creating a process.
+			_, _, r.clone = unix.RawSyscall(unix.SYS_CLONE, unix.CLONE_NEWUSER|unix.CLONE_FS, 0, 0)
+			_, _, r.fork = unix.RawSyscall(unix.SYS_CLONE, unix.CLONE_THREAD, 0, 0)
+			_, _, r.clone3 = unix.RawSyscall(unix.SYS_CLONE3, 0, 0, 0)
+		}
+		done <- r
+	}()
+
+	r := <-done
+	require.NoError(t, r.err)
+	assert.Equal(t, unix.EPERM, r.clone)
+	assert.Equal(t, unix.EINVAL, r.fork)
+	assert.Equal(t, unix.ENOSYS, r.clone3)
+}
@@ -0,0 +1,26 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewSandbox_Backends(t *testing.T) {
+	for _, backend := range []string{"", "none"} {
+		sb, err := NewSandbox(config.ExecSandboxConfig{Backend: backend}, t.TempDir())
+		require.NoError(t, err)
+		assert.Nil(t, sb, "backend %q", backend)
+	}
+
+	_, err := NewSandbox(config.ExecSandboxConfig{Backend: "docker"}, t.TempDir())
+	assert.ErrorContains(t, err, "unknown sandbox backend")
+
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox.Backend = "bogus"
+	_, err = NewExecToolWithConfig(t.TempDir(), true, cfg)
+	assert.ErrorContains(t, err, "exec sandbox")
+}
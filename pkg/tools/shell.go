@@ -23,6 +23,8 @@ type ExecTool struct {
 	allowPatterns       []*regexp.Regexp
 	customAllowPatterns []*regexp.Regexp
 	restrictToWorkspace bool
+	sandbox             Sandbox // nil runs commands unconfined
+	jobs                *JobManager
 }
 
 var (
@@ -136,6 +138,18 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		timeout = time.Duration(config.Tools.Exec.TimeoutSeconds) * time.Second
 	}
 
+	var sandbox Sandbox
+	var maxJobs, jobOutput int
+	if config != nil {
+		var err error
+		sandbox, err = NewSandbox(config.Tools.Exec.Sandbox, workingDir)
+		if err != nil {
+			return nil, fmt.Errorf("exec sandbox: %w", err)
+		}
+		maxJobs = config.Tools.Exec.MaxBackgroundJobs
+		jobOutput = config.Tools.Exec.BackgroundOutputKB << 10
+	}
+
 	return &ExecTool{
 		workingDir:          workingDir,
 		timeout:             timeout,
@@ -143,6 +157,8 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		allowPatterns:       nil,
 		customAllowPatterns: customAllowPatterns,
 		restrictToWorkspace: restrict,
+		sandbox:             sandbox,
+		jobs:                NewJobManager(maxJobs, jobOutput),
 	}, nil
 }
 
@@ -151,7 +167,9 @@ func (t *ExecTool) Name() string {
 }
 
 func (t *ExecTool) Description() string {
-	return "Execute a shell command and return its output. Use with caution."
+	return "Execute a shell command and return its output. Use with caution. " +
+		"Set background=true for long-running commands (builds, downloads, servers); " +
+		"it returns a job ID to use with exec_job."
 }
 
 func (t *ExecTool) Parameters() map[string]any {
@@ -166,6 +184,10 @@ func (t *ExecTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional working directory for the command",
 			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command detached without a timeout and return a job ID",
+			},
 		},
 		"required": []string{"command"},
 	}
@@ -201,6 +223,10 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		return ErrorResult(guardError)
 	}
 
+	if background, _ := args["background"].(bool); background {
+		return t.startJob(ctx, command, cwd)
+	}
+
 	// timeout == 0 means no timeout
 	var cmdCtx context.Context
 	var cancel context.CancelFunc
@@ -211,17 +237,11 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 	defer cancel()
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
-	} else {
-		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
-	}
-	if cwd != "" {
-		cmd.Dir = cwd
+	cmd, cleanup, err := t.newCommand(cmdCtx, command, cwd)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
 	}
-
-	prepareCommandForTermination(cmd)
+	defer cleanup()
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -236,7 +256,6 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		done <- cmd.Wait()
 	}()
 
-	var err error
 	select {
 	case err = <-done:
 	case <-cmdCtx.Done():
@@ -265,6 +284,14 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 				IsError: true,
 			}
 		}
+		if errors.Is(cmdCtx.Err(), context.Canceled) {
+			msg := "Command canceled"
+			return &ToolResult{
+				ForLLM:  msg,
+				ForUser: msg,
+				IsError: true,
+			}
+		}
 		output += fmt.Sprintf("\nExit code: %v", err)
 	}
 
@@ -292,6 +319,47 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
+// newCommand builds the shell invocation of command in cwd, confined by the
+// sandbox if one is configured. cleanup must be called once it has exited.
+func (t *ExecTool) newCommand(ctx context.Context, command, cwd string) (*exec.Cmd, func(), error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	if cwd != "" {
+		cmd.Dir = cwd
+	}
+
+	prepareCommandForTermination(cmd)
+	cleanup := func() {}
+	if t.sandbox != nil {
+		var err error
+		if cleanup, err = t.sandbox.Prepare(cmd); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cmd, cleanup, nil
+}
+
+// startJob runs command as a background job of the session in ctx.
+func (t *ExecTool) startJob(ctx context.Context, command, cwd string) *ToolResult {
+	// The job outlives the turn, so it must not inherit its cancellation.
+	cmd, cleanup, err := t.newCommand(context.WithoutCancel(ctx), command, cwd)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
+	}
+	job, err := t.jobs.start(ToolSession(ctx), command, cmd, cleanup)
+	if err != nil {
+		cleanup()
+		return ErrorResult(fmt.Sprintf("failed to start background job: %v", err))
+	}
+	return SilentResult(fmt.Sprintf(
+		"Started background job %s (pid %d). Use exec_job with action=poll to read its output.",
+		job.id, cmd.Process.Pid))
+}
+
 func (t *ExecTool) guardCommand(command, cwd string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
@@ -370,6 +438,15 @@ func (t *ExecTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+func (t *ExecTool) SetSandbox(sandbox Sandbox) {
+	t.sandbox = sandbox
+}
+
+// Jobs returns the manager of the background jobs started by the tool.
+func (t *ExecTool) Jobs() *JobManager {
+	return t.jobs
+}
+
 func (t *ExecTool) SetAllowPatterns(patterns []string) error {
 	t.allowPatterns = make([]*regexp.Regexp, 0, len(patterns))
 	for _, p := range patterns {
@@ -0,0 +1,478 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxBackgroundJobs = 4
+	defaultJobOutputBytes    = 64 << 10
+
+	// maxJobPollChars caps the output returned by one poll; the rest is
+	// returned by the next.
+	maxJobPollChars = 10000
+	maxJobPollWait  = 60 * time.Second
+	jobKillGrace    = 2 * time.Second
+
+	// finishedJobTTL is how long a finished job is kept when its final
+	// output is never polled.
+	finishedJobTTL = 30 * time.Minute
+)
+
+// jobOutput keeps the last max bytes of a job's combined stdout and stderr.
+type jobOutput struct {
+	mu      sync.Mutex
+	data    []byte
+	start   int64 // stream offset of data[0]
+	max     int
+	changed chan struct{} // closed and replaced on every write
+}
+
+func newJobOutput(max int) *jobOutput {
+	return &jobOutput{max: max, changed: make(chan struct{})}
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.data = append(o.data, p...)
+	if over := len(o.data) - o.max; over > 0 {
+		o.data = append(o.data[:0], o.data[over:]...)
+		o.start += int64(over)
+	}
+	close(o.changed)
+	o.changed = make(chan struct{})
+	return len(p), nil
+}
+
+// readFrom returns up to limit bytes from stream offset off, the offset
+// after them, and how many bytes before them were already discarded.
+func (o *jobOutput) readFrom(off int64, limit int) (data []byte, next, dropped int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if off < o.start {
+		dropped = o.start - off
+		off = o.start
+	}
+	data = o.data[off-o.start:]
+	if len(data) > limit {
+		data = data[:limit]
+	}
+	return append([]byte(nil), data...), off + int64(len(data)), dropped
+}
+
+// end returns the stream offset after the last byte written and a channel
+// closed on the next write.
+func (o *jobOutput) end() (int64, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.start + int64(len(o.data)), o.changed
+}
+
+// execJob is a command started by the exec tool in the background.
+type execJob struct {
+	id      string
+	session string
+	command string
+	started time.Time
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	output *jobOutput
+	done   chan struct{} // closed once the command has exited
+
+	mu         sync.Mutex
+	readOffset int64
+	killed     bool
+	waitErr    error
+	finished   time.Time
+
+	// stdinMu serializes writes, which block while the pipe is full.
+	stdinMu     sync.Mutex
+	stdinClosed bool
+}
+
+func (j *execJob) exited() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// status describes the state of the job in one line.
+func (j *execJob) status() string {
+	if !j.exited() {
+		return fmt.Sprintf("running for %s (pid %d)",
+			time.Since(j.started).Round(time.Second), j.cmd.Process.Pid)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	took := j.finished.Sub(j.started).Round(time.Second)
+	var exitErr *exec.ExitError
+	switch {
+	case j.killed:
+		return fmt.Sprintf("killed after %s", took)
+	case j.waitErr == nil:
+		return fmt.Sprintf("exited with code 0 after %s", took)
+	case errors.As(j.waitErr, &exitErr):
+		return fmt.Sprintf("exited with code %d after %s", exitErr.ExitCode(), took)
+	default:
+		return fmt.Sprintf("failed after %s: %v", took, j.waitErr)
+	}
+}
+
+// JobManager tracks the background jobs of the exec tool. Jobs belong to the
+// session that started them and outlive the turn; they are killed by
+// Shutdown. A finished job is forgotten once its remaining output has been
+// polled, or finishedJobTTL after it exited.
+type JobManager struct {
+	mu          sync.Mutex
+	jobs        map[string]*execJob
+	seq         int
+	maxJobs     int // per session
+	maxOutput   int // bytes kept per job
+	finishedTTL time.Duration
+	closed      bool
+}
+
+// NewJobManager creates a JobManager allowing maxJobs jobs per session and
+// keeping the last maxOutput bytes of output of each. Non-positive values
+// select the defaults.
+func NewJobManager(maxJobs, maxOutput int) *JobManager {
+	if maxJobs <= 0 {
+		maxJobs = defaultMaxBackgroundJobs
+	}
+	if maxOutput <= 0 {
+		maxOutput = defaultJobOutputBytes
+	}
+	return &JobManager{
+		jobs:        make(map[string]*execJob),
+		maxJobs:     maxJobs,
+		maxOutput:   maxOutput,
+		finishedTTL: finishedJobTTL,
+	}
+}
+
+// start runs cmd, which has not been started, as a job of session. cleanup,
+// if not nil, is called once the command has exited.
+func (m *JobManager) start(session, command string, cmd *exec.Cmd, cleanup func()) (*execJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errors.New("background jobs are shut down")
+	}
+	if err := m.makeRoomLocked(session); err != nil {
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	output := newJobOutput(m.maxOutput)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	m.seq++
+	job := &execJob{
+		id:      fmt.Sprintf("job-%d", m.seq),
+		session: session,
+		command: command,
+		started: time.Now(),
+		cmd:     cmd,
+		stdin:   stdin,
+		output:  output,
+		done:    make(chan struct{}),
+	}
+	m.jobs[job.id] = job
+
+	go func() {
+		err := cmd.Wait()
+		job.mu.Lock()
+		job.waitErr = err
+		job.finished = time.Now()
+		job.mu.Unlock()
+		if cleanup != nil {
+			cleanup()
+		}
+		close(job.done)
+		time.AfterFunc(m.finishedTTL, func() { m.forget(job) })
+	}()
+	return job, nil
+}
+
+// makeRoomLocked forgets the oldest finished jobs of session until another
+// job fits. It fails if all slots hold running jobs.
+func (m *JobManager) makeRoomLocked(session string) error {
+	jobs := m.sessionJobsLocked(session)
+	n := len(jobs)
+	for _, job := range jobs {
+		if n < m.maxJobs {
+			break
+		}
+		if job.exited() {
+			delete(m.jobs, job.id)
+			n--
+		}
+	}
+	if n >= m.maxJobs {
+		return fmt.Errorf("too many background jobs (limit %d); kill one first", m.maxJobs)
+	}
+	return nil
+}
+
+// forget drops job, which has exited, with its output.
+func (m *JobManager) forget(job *execJob) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs[job.id] == job {
+		delete(m.jobs, job.id)
+	}
+}
+
+// sessionJobsLocked returns the jobs of session, oldest first.
+func (m *JobManager) sessionJobsLocked(session string) []*execJob {
+	var jobs []*execJob
+	for _, job := range m.jobs {
+		if job.session == session {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].started.Before(jobs[k].started) })
+	return jobs
+}
+
+// get returns job id if it belongs to session.
+func (m *JobManager) get(session, id string) (*execJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || job.session != session {
+		return nil, false
+	}
+	return job, true
+}
+
+func (m *JobManager) list(session string) []*execJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessionJobsLocked(session)
+}
+
+// kill terminates the process tree of job and waits briefly for it to exit.
+func (m *JobManager) kill(job *execJob) {
+	if job.exited() {
+		return
+	}
+	job.mu.Lock()
+	job.killed = true
+	job.mu.Unlock()
+	_ = terminateProcessTree(job.cmd)
+	select {
+	case <-job.done:
+	case <-time.After(jobKillGrace):
+	}
+}
+
+// Shutdown kills all running jobs. Jobs cannot be started afterwards.
+func (m *JobManager) Shutdown() {
+	m.mu.Lock()
+	m.closed = true
+	jobs := make([]*execJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.kill(job)
+		}()
+	}
+	wg.Wait()
+}
+
+// poll returns the output of job written since the previous poll. With a
+// positive wait it first waits up to that long for output or exit. Once a
+// poll has returned all output of a finished job, the job is forgotten.
+func (m *JobManager) poll(ctx context.Context, job *execJob, wait time.Duration) string {
+	job.mu.Lock()
+	off := job.readOffset
+	job.mu.Unlock()
+
+	if wait > 0 {
+		end, changed := job.output.end()
+		if end == off && !job.exited() {
+			timer := time.NewTimer(wait)
+			select {
+			case <-changed:
+			case <-job.done:
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			timer.Stop()
+		}
+	}
+
+	// Output is complete once the job has exited.
+	exited := job.exited()
+	data, next, dropped := job.output.readFrom(off, maxJobPollChars)
+	job.mu.Lock()
+	job.readOffset = next
+	job.mu.Unlock()
+	end, _ := job.output.end()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Job %s %s\n", job.id, job.status())
+	if dropped > 0 {
+		fmt.Fprintf(&sb, "... (%d bytes of older output dropped)\n", dropped)
+	}
+	if len(data) == 0 {
+		sb.WriteString("(no new output)")
+	} else {
+		sb.Write(data)
+	}
+	if more := end - next; more > 0 {
+		fmt.Fprintf(&sb, "\n... (%d more bytes; poll again)", more)
+	} else if exited {
+		m.forget(job)
+	}
+	return sb.String()
+}
+
+// write sends input to the stdin of job, closing stdin afterwards if
+// closeStdin is set.
+func (m *JobManager) write(job *execJob, input string, closeStdin bool) error {
+	job.stdinMu.Lock()
+	defer job.stdinMu.Unlock()
+	if job.stdinClosed {
+		return errors.New("stdin is closed")
+	}
+	if input != "" {
+		if _, err := io.WriteString(job.stdin, input); err != nil {
+			return err
+		}
+	}
+	if closeStdin {
+		job.stdinClosed = true
+		return job.stdin.Close()
+	}
+	return nil
+}
+
+// ExecJobTool inspects and controls the background jobs started with
+// exec's "background" option.
+type ExecJobTool struct {
+	jobs *JobManager
+}
+
+func NewExecJobTool(jobs *JobManager) *ExecJobTool {
+	return &ExecJobTool{jobs: jobs}
+}
+
+func (t *ExecJobTool) Name() string {
+	return "exec_job"
+}
+
+func (t *ExecJobTool) Description() string {
+	return "Manage background commands started by exec with background=true: list jobs, poll new output, write to stdin, or kill a job."
+}
+
+func (t *ExecJobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "poll", "write", "kill"},
+				"description": "list: show jobs; poll: get output since the last poll; write: send input to stdin; kill: stop the job",
+			},
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "Job ID returned by exec (required except for list)",
+			},
+			"wait_seconds": map[string]any{
+				"type":        "integer",
+				"description": "For poll: wait up to this many seconds (max 60) for new output or exit",
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "For write: text to send; include \\n to end a line",
+			},
+			"close_stdin": map[string]any{
+				"type":        "boolean",
+				"description": "For write: close stdin after writing (sends EOF)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ExecJobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	session := ToolSession(ctx)
+
+	if action == "list" {
+		jobs := t.jobs.list(session)
+		if len(jobs) == 0 {
+			return SilentResult("No background jobs.")
+		}
+		var sb strings.Builder
+		for _, job := range jobs {
+			fmt.Fprintf(&sb, "%s: %s — %s\n", job.id, job.command, job.status())
+		}
+		return SilentResult(strings.TrimRight(sb.String(), "\n"))
+	}
+
+	id, _ := args["job_id"].(string)
+	if id == "" {
+		return ErrorResult("job_id is required")
+	}
+	job, ok := t.jobs.get(session, id)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("no background job %q", id))
+	}
+
+	switch action {
+	case "poll":
+		var wait time.Duration
+		if secs, ok := args["wait_seconds"].(float64); ok && secs > 0 {
+			wait = min(time.Duration(secs*float64(time.Second)), maxJobPollWait)
+		}
+		return SilentResult(t.jobs.poll(ctx, job, wait))
+	case "write":
+		input, _ := args["input"].(string)
+		closeStdin, _ := args["close_stdin"].(bool)
+		if input == "" && !closeStdin {
+			return ErrorResult("input or close_stdin is required")
+		}
+		if job.exited() {
+			return ErrorResult(fmt.Sprintf("job %s has already exited", id))
+		}
+		if err := t.jobs.write(job, input, closeStdin); err != nil {
+			return ErrorResult(fmt.Sprintf("write to job %s: %v", id, err))
+		}
+		return SilentResult(fmt.Sprintf("Wrote %d bytes to job %s", len(input), id))
+	case "kill":
+		t.jobs.kill(job)
+		return SilentResult(fmt.Sprintf("Job %s %s", id, job.status()))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q", action))
+	}
+}
@@ -0,0 +1,148 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jobIDPattern = regexp.MustCompile(`job-\d+`)
+
+func startTestJob(t *testing.T, tool *ExecTool, ctx context.Context, command string) string {
+	t.Helper()
+	result := tool.Execute(ctx, map[string]any{"command": command, "background": true})
+	require.False(t, result.IsError, result.ForLLM)
+	id := jobIDPattern.FindString(result.ForLLM)
+	require.NotEmpty(t, id, result.ForLLM)
+	return id
+}
+
+func TestExecJob_PollIncrementalOutput(t *testing.T) {
+	tool, err := NewExecTool(t.TempDir(), false)
+	require.NoError(t, err)
+	t.Cleanup(tool.Jobs().Shutdown)
+	jobTool := NewExecJobTool(tool.Jobs())
+	ctx := WithToolSession(context.Background(), "s1")
+
+	id := startTestJob(t, tool, ctx, "echo first; read line; echo got $line")
+
+	result := jobTool.Execute(ctx, map[string]any{"action": "poll", "job_id": id, "wait_seconds": float64(5)})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "running")
+	assert.Contains(t, result.ForLLM, "first")
+
+	result = jobTool.Execute(ctx, map[string]any{"action": "write", "job_id": id, "input": "hello\n"})
+	require.False(t, result.IsError, result.ForLLM)
+
+	deadline := time.Now().Add(5 * time.Second)
+	var output string
+	for time.Now().Before(deadline) {
+		result = jobTool.Execute(ctx, map[string]any{"action": "poll", "job_id": id, "wait_seconds": float64(1)})
+		output += result.ForLLM
+		if strings.Contains(result.ForLLM, "exited") {
+			break
+		}
+	}
+	assert.Contains(t, output, "got hello")
+	assert.NotContains(t, output, "first", "output must not be returned twice")
+	assert.Contains(t, output, "exited with code 0")
+}
+
+func TestExecJob_SessionIsolationAndKill(t *testing.T) {
+	tool, err := NewExecTool(t.TempDir(), false)
+	require.NoError(t, err)
+	t.Cleanup(tool.Jobs().Shutdown)
+	jobTool := NewExecJobTool(tool.Jobs())
+	ctx := WithToolSession(context.Background(), "s1")
+	other := WithToolSession(context.Background(), "s2")
+
+	id := startTestJob(t, tool, ctx, "sleep 30")
+
+	result := jobTool.Execute(other, map[string]any{"action": "poll", "job_id": id})
+	assert.True(t, result.IsError)
+	result = jobTool.Execute(other, map[string]any{"action": "list"})
+	assert.Contains(t, result.ForLLM, "No background jobs")
+
+	result = jobTool.Execute(ctx, map[string]any{"action": "list"})
+	assert.Contains(t, result.ForLLM, id+": sleep 30")
+
+	result = jobTool.Execute(ctx, map[string]any{"action": "kill", "job_id": id})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "killed")
+}
+
+func TestExecJob_LimitAndShutdown(t *testing.T) {
+	tool, err := NewExecTool(t.TempDir(), false)
+	require.NoError(t, err)
+	tool.jobs = NewJobManager(2, 0)
+	ctx := WithToolSession(context.Background(), "s1")
+
+	first := startTestJob(t, tool, ctx, "sleep 30")
+	startTestJob(t, tool, ctx, "sleep 30")
+	result := tool.Execute(ctx, map[string]any{"command": "sleep 30", "background": true})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "too many background jobs")
+
+	// A finished job frees its slot for the next one.
+	job, ok := tool.Jobs().get("s1", first)
+	require.True(t, ok)
+	tool.Jobs().kill(job)
+	startTestJob(t, tool, ctx, "sleep 30")
+
+	tool.Jobs().Shutdown()
+	for _, job := range tool.Jobs().list("s1") {
+		assert.True(t, job.exited(), job.id)
+	}
+	result = tool.Execute(ctx, map[string]any{"command": "true", "background": true})
+	assert.True(t, result.IsError)
+}
+
+func TestJobOutput_KeepsTail(t *testing.T) {
+	out := newJobOutput(8)
+	_, _ = out.Write([]byte("0123456789"))
+	_, _ = out.Write([]byte("ab"))
+
+	data, next, dropped := out.readFrom(0, 100)
+	assert.Equal(t, "456789ab", string(data))
+	assert.Equal(t, int64(12), next)
+	assert.Equal(t, int64(4), dropped)
+
+	data, next, dropped = out.readFrom(6, 3)
+	assert.Equal(t, "678", string(data))
+	assert.Equal(t, int64(9), next)
+	assert.Zero(t, dropped)
+}
+
+func TestExecJob_ForgetsFinishedJobs(t *testing.T) {
+	tool, err := NewExecTool(t.TempDir(), false)
+	require.NoError(t, err)
+	t.Cleanup(tool.Jobs().Shutdown)
+	jobTool := NewExecJobTool(tool.Jobs())
+	ctx := WithToolSession(context.Background(), "s1")
+
+	// Polling the final output drops the job.
+	id := startTestJob(t, tool, ctx, "echo done")
+	result := jobTool.Execute(ctx, map[string]any{"action": "poll", "job_id": id, "wait_seconds": float64(5)})
+	for !strings.Contains(result.ForLLM, "exited") {
+		result = jobTool.Execute(ctx, map[string]any{"action": "poll", "job_id": id, "wait_seconds": float64(1)})
+	}
+	result = jobTool.Execute(ctx, map[string]any{"action": "poll", "job_id": id})
+	assert.True(t, result.IsError, result.ForLLM)
+
+	// A finished job that is never polled is dropped after the TTL.
+	tool.jobs = NewJobManager(0, 0)
+	tool.jobs.finishedTTL = 10 * time.Millisecond
+	t.Cleanup(tool.jobs.Shutdown)
+	id = startTestJob(t, tool, ctx, "true")
+	assert.Eventually(t, func() bool {
+		_, ok := tool.Jobs().get("s1", id)
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
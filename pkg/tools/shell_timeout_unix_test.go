@@ -63,3 +63,44 @@ func TestShellTool_TimeoutKillsChildProcess(t *testing.T) {
 
 	t.Fatalf("child process %d is still running after timeout", childPID)
 }
+
+func TestShellTool_CancelKillsChildProcess(t *testing.T) {
+	tool, err := NewExecTool(t.TempDir(), false)
+	if err != nil {
+		t.Errorf("unable to configure exec tool: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	args := map[string]any{
+		"command": "sleep 60 & echo $! > child.pid; wait",
+	}
+
+	result := tool.Execute(ctx, args)
+	if !result.IsError {
+		t.Fatalf("expected cancel error, got success: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "canceled") {
+		t.Fatalf("expected cancel message, got: %s", result.ForLLM)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tool.workingDir, "child.pid"))
+	if err != nil {
+		t.Fatalf("failed to read child pid file: %v", err)
+	}
+	childPID, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("failed to parse child pid: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if !processExists(childPID) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("child process %d is still running after cancel", childPID)
+}
@@ -24,6 +24,11 @@ func (t *SpawnTool) Name() string {
 	return "spawn"
 }
 
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *SpawnTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *SpawnTool) Description() string {
 	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done."
 }
@@ -104,3 +109,19 @@ func (t *SpawnTool) execute(ctx context.Context, args map[string]any, cb AsyncCa
 	// Return AsyncResult since the task runs in background
 	return AsyncResult(result)
 }
+
+// CancelTasks cancels the running subagents spawned from channel/chatID and
+// returns how many were canceled. Used by the agent loop's /stop command.
+func (t *SpawnTool) CancelTasks(channel, chatID string) int {
+	if t.manager == nil {
+		return 0
+	}
+	return t.manager.CancelByOrigin(channel, chatID)
+}
+
+// Shutdown cancels all running subagents. Used when the agent loop stops.
+func (t *SpawnTool) Shutdown() {
+	if t.manager != nil {
+		t.manager.Shutdown()
+	}
+}
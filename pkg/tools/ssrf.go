@@ -0,0 +1,255 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Address ranges that are not covered by the netip predicates but must not
+// be reachable from the web tools either.
+var ssrfBlockedPrefixes = []struct {
+	prefix netip.Prefix
+	reason string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), "unspecified"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "shared (carrier-grade NAT)"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "reserved"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "reserved"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "reserved"},
+	{netip.MustParsePrefix("64:ff9b::/96"), "NAT64"},
+}
+
+// Cloud metadata services, named separately in errors because they are
+// the usual target of SSRF.
+var ssrfMetadataAddrs = map[netip.Addr]bool{
+	netip.MustParseAddr("169.254.169.254"): true,
+	netip.MustParseAddr("fd00:ec2::254"):   true,
+	netip.MustParseAddr("100.100.100.200"): true,
+}
+
+// BlockedAddressError reports a connection refused by the SSRF guard.
+type BlockedAddressError struct {
+	Host   string
+	Addr   netip.Addr
+	Reason string
+}
+
+func (e *BlockedAddressError) Error() string {
+	target := e.Addr.String()
+	if e.Host != "" && e.Host != target {
+		target = fmt.Sprintf("%s (%s)", e.Host, e.Addr)
+	}
+	return fmt.Sprintf("access to %s is blocked: %s address; add it to tools.web.trusted_hosts if it is trusted",
+		target, e.Reason)
+}
+
+// blockedReason returns why ip must not be reached, or "" if it may be.
+func blockedReason(ip netip.Addr) string {
+	ip = ip.Unmap()
+	switch {
+	case ssrfMetadataAddrs[ip]:
+		return "cloud metadata"
+	case ip.IsLoopback():
+		return "loopback"
+	case ip.IsPrivate():
+		return "private"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return "link-local"
+	case ip.IsUnspecified():
+		return "unspecified"
+	case ip.IsMulticast(), ip.IsInterfaceLocalMulticast():
+		return "multicast"
+	case ip.Is4() && ip == netip.AddrFrom4([4]byte{255, 255, 255, 255}):
+		return "broadcast"
+	}
+	for _, p := range ssrfBlockedPrefixes {
+		if p.prefix.Contains(ip) {
+			return p.reason
+		}
+	}
+	return ""
+}
+
+// ssrfGuard keeps outbound HTTP tools away from loopback, private,
+// link-local and metadata addresses. Every address a connection is made
+// to is checked, so DNS answers and redirects cannot route around it.
+// Trusted hosts, addresses and networks are exempt.
+type ssrfGuard struct {
+	trustedHosts    map[string]bool
+	trustedSuffixes []string // from "*.example.lan" entries
+	trustedNets     []netip.Prefix
+
+	// proxies holds the host:port of proxies the transport has chosen.
+	// Those are dialed unchecked; the proxy resolves the target itself,
+	// so requests through it are checked by checkURL instead.
+	proxies sync.Map
+}
+
+// newSSRFGuard creates a guard exempting trusted, a list of host names
+// ("*.example.lan" matches subdomains), IP addresses and CIDR ranges.
+func newSSRFGuard(trusted []string) (*ssrfGuard, error) {
+	g := &ssrfGuard{trustedHosts: make(map[string]bool)}
+	for _, entry := range trusted {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted network %q: %w", entry, err)
+			}
+			g.trustedNets = append(g.trustedNets, prefix.Masked())
+		case strings.HasPrefix(entry, "*."):
+			g.trustedSuffixes = append(g.trustedSuffixes, entry[1:])
+		default:
+			if ip, err := netip.ParseAddr(strings.Trim(entry, "[]")); err == nil {
+				g.trustedNets = append(g.trustedNets, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+				continue
+			}
+			g.trustedHosts[strings.TrimSuffix(entry, ".")] = true
+		}
+	}
+	return g, nil
+}
+
+func (g *ssrfGuard) hostTrusted(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if g.trustedHosts[host] {
+		return true
+	}
+	for _, suffix := range g.trustedSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddr fails if ip, which host resolved to, may not be reached.
+func (g *ssrfGuard) checkAddr(host string, ip netip.Addr) error {
+	ip = ip.Unmap()
+	for _, prefix := range g.trustedNets {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	if reason := blockedReason(ip); reason != "" {
+		return &BlockedAddressError{Host: host, Addr: ip, Reason: reason}
+	}
+	return nil
+}
+
+// checkURL resolves the host of u and fails if any of its addresses may
+// not be reached. Connections are checked again when they are made; this
+// covers requests sent through a proxy and fails redirects early with a
+// clear error. Names that do not resolve locally are left to the dialer.
+func (g *ssrfGuard) checkURL(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if host == "" || g.hostTrusted(host) {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return g.checkAddr(host, ip)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range addrs {
+		if err := g.checkAddr(host, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dialContext returns a dial function that checks the address of every
+// connection dialer makes, after name resolution.
+func (g *ssrfGuard) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := g.proxies.Load(addr); ok || g.hostTrusted(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		guarded := *dialer
+		guarded.Control = func(_, address string, _ syscall.RawConn) error {
+			ipStr, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(ipStr)
+			if err != nil {
+				return err
+			}
+			return g.checkAddr(host, ip)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+}
+
+// install guards the connections and redirects of client, which must use
+// an *http.Transport.
+func (g *ssrfGuard) install(client *http.Client) {
+	transport := client.Transport.(*http.Transport)
+	transport.DialContext = g.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	if proxy := transport.Proxy; proxy != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := proxy(req)
+			if u != nil {
+				g.proxies.Store(proxyDialAddr(u), true)
+			}
+			return u, err
+		}
+	}
+
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if checkRedirect != nil {
+			if err := checkRedirect(req, via); err != nil {
+				return err
+			}
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+		}
+		return g.checkURL(req.Context(), req.URL)
+	}
+}
+
+// proxyDialAddr returns the host:port the transport dials for proxy u.
+func proxyDialAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// blockedError returns the guard's error inside err, if any, so tools can
+// report it without the surrounding dial and URL errors.
+func blockedError(err error) (*BlockedAddressError, bool) {
+	var blocked *BlockedAddressError
+	ok := errors.As(err, &blocked)
+	return blocked, ok
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockedReason(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"127.0.0.1", "loopback"},
+		{"::1", "loopback"},
+		{"10.1.2.3", "private"},
+		{"192.168.1.1", "private"},
+		{"fd12::1", "private"},
+		{"169.254.169.254", "cloud metadata"},
+		{"169.254.10.1", "link-local"},
+		{"fe80::1", "link-local"},
+		{"100.64.0.1", "shared (carrier-grade NAT)"},
+		{"0.0.0.0", "unspecified"},
+		{"::ffff:127.0.0.1", "loopback"},
+		{"8.8.8.8", ""},
+		{"2606:4700::1111", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, blockedReason(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestSSRFGuard_Trusted(t *testing.T) {
+	guard, err := newSSRFGuard([]string{"NAS.local", "*.corp.lan", "10.0.5.0/24", "192.168.1.10"})
+	require.NoError(t, err)
+
+	check := func(rawURL string) error {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		return guard.checkURL(context.Background(), u)
+	}
+	assert.NoError(t, check("http://nas.local/"))
+	assert.NoError(t, check("http://wiki.corp.lan/"))
+	assert.NoError(t, check("http://10.0.5.20/"))
+	assert.NoError(t, check("http://192.168.1.10:8080/"))
+	assert.Error(t, check("http://192.168.1.11/"))
+	assert.Error(t, check("http://10.0.6.1/"))
+
+	err = check("http://169.254.169.254/latest/meta-data/")
+	blocked, ok := blockedError(err)
+	require.True(t, ok, "expected BlockedAddressError, got %v", err)
+	assert.Equal(t, "cloud metadata", blocked.Reason)
+
+	_, err = newSSRFGuard([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestWebFetchTool_BlocksInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	tool, err := NewWebFetchTool(50000, testFetchLimit)
+	require.NoError(t, err)
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "loopback address")
+
+	// localhost is resolved and blocked the same way.
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	result = tool.Execute(context.Background(), map[string]any{"url": "http://localhost:" + u.Port() + "/"})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "is blocked")
+
+	tool, err = newLocalWebFetchTool(50000)
+	require.NoError(t, err)
+	result = tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, "internal")
+}
+
+func TestWebFetchTool_BlocksRedirectToInternalAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	tool, err := newLocalWebFetchTool(50000)
+	require.NoError(t, err)
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	require.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "access to 169.254.169.254 is blocked: cloud metadata address")
+}
@@ -20,6 +20,8 @@ type SubagentTask struct {
 	Status        string
 	Result        string
 	Created       int64
+
+	cancel context.CancelFunc
 }
 
 type SubagentManager struct {
@@ -36,6 +38,10 @@ type SubagentManager struct {
 	hasMaxTokens   bool
 	hasTemperature bool
 	nextID         int
+
+	// ctx is canceled by Shutdown and stops every spawned task.
+	ctx      context.Context
+	shutdown context.CancelFunc
 }
 
 func NewSubagentManager(
@@ -43,6 +49,7 @@ func NewSubagentManager(
 	defaultModel, workspace string,
 	bus *bus.MessageBus,
 ) *SubagentManager {
+	ctx, shutdown := context.WithCancel(context.Background())
 	return &SubagentManager{
 		tasks:         make(map[string]*SubagentTask),
 		provider:      provider,
@@ -52,9 +59,17 @@ func NewSubagentManager(
 		tools:         NewToolRegistry(),
 		maxIterations: 10,
 		nextID:        1,
+		ctx:           ctx,
+		shutdown:      shutdown,
 	}
 }
 
+// Shutdown cancels all spawned tasks. Canceled tasks do not announce their
+// results.
+func (sm *SubagentManager) Shutdown() {
+	sm.shutdown()
+}
+
 // SetLLMOptions sets max tokens and temperature for subagent LLM calls.
 func (sm *SubagentManager) SetLLMOptions(maxTokens int, temperature float64) {
 	sm.mu.Lock()
@@ -103,8 +118,18 @@ func (sm *SubagentManager) Spawn(
 	}
 	sm.tasks[taskID] = subagentTask
 
+	// The task outlives the turn that spawned it, so detach it from the
+	// turn's cancellation; it is stopped explicitly via CancelByOrigin or
+	// by Shutdown.
+	taskCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopOnShutdown := context.AfterFunc(sm.ctx, cancel)
+	subagentTask.cancel = func() {
+		stopOnShutdown()
+		cancel()
+	}
+
 	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	go sm.runTask(taskCtx, subagentTask, callback)
 
 	if label != "" {
 		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
@@ -113,8 +138,9 @@ func (sm *SubagentManager) Spawn(
 }
 
 func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
-	task.Status = "running"
-	task.Created = time.Now().UnixMilli()
+	if task.cancel != nil {
+		defer task.cancel()
+	}
 
 	// Build system prompt for subagent
 	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
@@ -182,14 +208,21 @@ After completing the task, provide a clear summary of what was done.`
 		}
 	}()
 
-	if err != nil {
-		task.Status = "failed"
-		task.Result = fmt.Sprintf("Error: %v", err)
-		// Check if it was canceled
-		if ctx.Err() != nil {
+	switch {
+	case task.Status == "canceled" || ctx.Err() != nil:
+		// Keep the reason CancelByOrigin recorded, if it was the one.
+		if task.Status != "canceled" {
 			task.Status = "canceled"
 			task.Result = "Task canceled during execution"
 		}
+		result = &ToolResult{
+			ForLLM:  task.Result,
+			IsError: true,
+			Err:     ctx.Err(),
+		}
+	case err != nil:
+		task.Status = "failed"
+		task.Result = fmt.Sprintf("Error: %v", err)
 		result = &ToolResult{
 			ForLLM:  task.Result,
 			ForUser: "",
@@ -198,7 +231,7 @@ After completing the task, provide a clear summary of what was done.`
 			Async:   false,
 			Err:     err,
 		}
-	} else {
+	default:
 		task.Status = "completed"
 		task.Result = loopResult.Content
 		result = &ToolResult{
@@ -215,8 +248,9 @@ After completing the task, provide a clear summary of what was done.`
 		}
 	}
 
-	// Send announce message back to main agent
-	if sm.bus != nil {
+	// Send announce message back to main agent. Canceled tasks stay quiet:
+	// the user asked for them to stop.
+	if sm.bus != nil && task.Status != "canceled" {
 		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
 		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer pubCancel()
@@ -230,6 +264,27 @@ After completing the task, provide a clear summary of what was done.`
 	}
 }
 
+// CancelByOrigin cancels all running tasks spawned from the given chat and
+// returns how many were canceled.
+func (sm *SubagentManager) CancelByOrigin(originChannel, originChatID string) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	canceled := 0
+	for _, task := range sm.tasks {
+		if task.Status != "running" || task.OriginChannel != originChannel || task.OriginChatID != originChatID {
+			continue
+		}
+		task.Status = "canceled"
+		task.Result = "Task canceled by user"
+		if task.cancel != nil {
+			task.cancel()
+		}
+		canceled++
+	}
+	return canceled
+}
+
 func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -265,6 +320,11 @@ func (t *SubagentTool) Name() string {
 	return "subagent"
 }
 
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *SubagentTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *SubagentTool) Description() string {
 	return "Execute a subagent task synchronously and return the result. Use this for delegating specific tasks to an independent agent instance. Returns execution summary to user and full details to LLM."
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -330,3 +331,145 @@ func TestSubagentTool_ForUserTruncation(t *testing.T) {
 		t.Error("ForLLM should contain reference to original task")
 	}
 }
+
+// blockingLLMProvider blocks until the call context is canceled.
+type blockingLLMProvider struct{}
+
+func (p *blockingLLMProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *blockingLLMProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+func TestSubagentManager_CancelByOrigin(t *testing.T) {
+	manager := NewSubagentManager(&blockingLLMProvider{}, "test-model", "/tmp/test", nil)
+
+	// The spawning turn's context ends right away; the task must survive it.
+	turnCtx, endTurn := context.WithCancel(context.Background())
+	if _, err := manager.Spawn(turnCtx, "long task", "a", "", "telegram", "chat1", nil); err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	if _, err := manager.Spawn(turnCtx, "other chat", "b", "", "telegram", "chat2", nil); err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	endTurn()
+
+	task, _ := manager.GetTask("subagent-1")
+	time.Sleep(50 * time.Millisecond)
+	manager.mu.RLock()
+	status := task.Status
+	manager.mu.RUnlock()
+	if status != "running" {
+		t.Fatalf("task status after turn ended = %q, want running", status)
+	}
+
+	if n := manager.CancelByOrigin("telegram", "chat1"); n != 1 {
+		t.Fatalf("CancelByOrigin() = %d, want 1", n)
+	}
+
+	other, _ := manager.GetTask("subagent-2")
+	manager.mu.RLock()
+	status, otherStatus := task.Status, other.Status
+	manager.mu.RUnlock()
+	if status != "canceled" {
+		t.Errorf("canceled task status = %q, want canceled", status)
+	}
+	if otherStatus != "running" {
+		t.Errorf("task from another chat status = %q, want running", otherStatus)
+	}
+
+	manager.CancelByOrigin("telegram", "chat2")
+}
+
+func TestSubagentManager_Shutdown(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	manager := NewSubagentManager(&blockingLLMProvider{}, "test-model", "/tmp/test", msgBus)
+
+	if _, err := manager.Spawn(context.Background(), "long task", "a", "", "telegram", "chat1", nil); err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	manager.Shutdown()
+
+	task, _ := manager.GetTask("subagent-1")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		manager.mu.RLock()
+		status := task.Status
+		manager.mu.RUnlock()
+		if status == "canceled" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status after Shutdown = %q, want canceled", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if msg, ok := msgBus.ConsumeInbound(ctx); ok {
+		t.Errorf("canceled task announced its result: %+v", msg)
+	}
+}
+
+// releasedLLMProvider answers once release is closed, ignoring cancellation.
+type releasedLLMProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *releasedLLMProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	close(p.started)
+	<-p.release
+	return &providers.LLMResponse{Content: "done anyway"}, nil
+}
+
+func (p *releasedLLMProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+func TestSubagentManager_CanceledTaskKeepsStatus(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	provider := &releasedLLMProvider{started: make(chan struct{}), release: make(chan struct{})}
+	manager := NewSubagentManager(provider, "test-model", "/tmp/test", msgBus)
+
+	done := make(chan *ToolResult, 1)
+	if _, err := manager.Spawn(context.Background(), "task", "a", "", "telegram", "chat1",
+		func(_ context.Context, result *ToolResult) { done <- result }); err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	<-provider.started
+	manager.CancelByOrigin("telegram", "chat1")
+	close(provider.release)
+	<-done
+
+	task, _ := manager.GetTask("subagent-1")
+	manager.mu.RLock()
+	status, result := task.Status, task.Result
+	manager.mu.RUnlock()
+	if status != "canceled" || result != "Task canceled by user" {
+		t.Errorf("task = %q (%q), want canceled by user", status, result)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if msg, ok := msgBus.ConsumeInbound(ctx); ok {
+		t.Errorf("canceled task announced its result: %+v", msg)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -122,40 +121,38 @@ func RunToolLoop(
 		}
 		messages = append(messages, assistantMsg)
 
-		// 7. Execute tool calls in parallel
+		// 7. Execute tool calls; concurrency-safe tools run in parallel
+		for _, tc := range normalizedToolCalls {
+			argsJSON, _ := json.Marshal(tc.Arguments)
+			argsPreview := utils.Truncate(string(argsJSON), 200)
+			logger.InfoCF("toolloop", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+				map[string]any{
+					"tool":      tc.Name,
+					"iteration": iteration,
+				})
+		}
+
 		type indexedResult struct {
 			result *ToolResult
 			tc     providers.ToolCall
 		}
 
 		results := make([]indexedResult, len(normalizedToolCalls))
-		var wg sync.WaitGroup
-
+		var toolResults []*ToolResult
+		if config.Tools != nil {
+			toolResults = config.Tools.ExecuteBatch(ctx, normalizedToolCalls, BatchOptions{
+				Channel: channel,
+				ChatID:  chatID,
+			})
+		}
 		for i, tc := range normalizedToolCalls {
 			results[i].tc = tc
-
-			wg.Add(1)
-			go func(idx int, tc providers.ToolCall) {
-				defer wg.Done()
-
-				argsJSON, _ := json.Marshal(tc.Arguments)
-				argsPreview := utils.Truncate(string(argsJSON), 200)
-				logger.InfoCF("toolloop", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-					map[string]any{
-						"tool":      tc.Name,
-						"iteration": iteration,
-					})
-
-				var toolResult *ToolResult
-				if config.Tools != nil {
-					toolResult = config.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, channel, chatID, nil)
-				} else {
-					toolResult = ErrorResult("No tools available")
-				}
-				results[idx].result = toolResult
-			}(i, tc)
+			if toolResults != nil {
+				results[i].result = toolResults[i]
+			} else {
+				results[i].result = ErrorResult("No tools available")
+			}
 		}
-		wg.Wait()
 
 		// Append results in original order
 		for _, r := range results {
@@ -12,6 +12,9 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 const (
@@ -34,6 +37,11 @@ var (
 	reWhitespace = regexp.MustCompile(`[^\S\n]+`)
 	reBlankLines = regexp.MustCompile(`\n{3,}`)
 
+	// Numbered result lists in LLM answers (Perplexity)
+	reNumberedItem = regexp.MustCompile(`^\d+[.)]\s+(.+)$`)
+	reBareURL      = regexp.MustCompile(`https?://[^\s<>"'\]]+`)
+	reMarkdownLink = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+
 	// DuckDuckGo result extraction
 	reDDGLink    = regexp.MustCompile(`<a[^>]*class="[^"]*result__a[^"]*"[^>]*href="([^"]+)"[^>]*>([\s\S]*?)</a>`)
 	reDDGSnippet = regexp.MustCompile(`<a class="result__snippet[^"]*".*?>([\s\S]*?)</a>`)
@@ -80,6 +88,46 @@ type SearchProvider interface {
 	Search(ctx context.Context, query string, count int) (string, error)
 }
 
+// SearchResult is a single hit returned by a search provider.
+type SearchResult struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// resultSearchProvider is implemented by the built-in providers, which can
+// return individual results for aggregated search to merge.
+type resultSearchProvider interface {
+	SearchProvider
+	searchResults(ctx context.Context, query string, count int) ([]SearchResult, error)
+}
+
+// formatWebResults renders up to count results in the common result
+// format, naming the provider when via is set.
+func formatWebResults(query, via string, results []SearchResult, count int) string {
+	if len(results) == 0 {
+		return fmt.Sprintf("No results for: %s", query)
+	}
+
+	var lines []string
+	if via != "" {
+		lines = append(lines, fmt.Sprintf("Results for: %s (via %s)", query, via))
+	} else {
+		lines = append(lines, fmt.Sprintf("Results for: %s", query))
+	}
+	for i, item := range results {
+		if i >= count {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.URL))
+		if item.Snippet != "" {
+			lines = append(lines, fmt.Sprintf("   %s", item.Snippet))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 type BraveSearchProvider struct {
 	apiKey string
 	proxy  string
@@ -87,12 +135,20 @@ type BraveSearchProvider struct {
 }
 
 func (p *BraveSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	results, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatWebResults(query, "", results, count), nil
+}
+
+func (p *BraveSearchProvider) searchResults(ctx context.Context, query string, count int) ([]SearchResult, error) {
 	searchURL := fmt.Sprintf("https://api.search.brave.com/res/v1/web/search?q=%s&count=%d",
 		url.QueryEscape(query), count)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
@@ -100,17 +156,17 @@ func (p *BraveSearchProvider) Search(ctx context.Context, query string, count in
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("brave api error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("brave api error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var searchResp struct {
@@ -126,27 +182,14 @@ func (p *BraveSearchProvider) Search(ctx context.Context, query string, count in
 	if err := json.Unmarshal(body, &searchResp); err != nil {
 		// Log error body for debugging
 		fmt.Printf("Brave API Error Body: %s\n", string(body))
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	results := searchResp.Web.Results
-	if len(results) == 0 {
-		return fmt.Sprintf("No results for: %s", query), nil
+	results := make([]SearchResult, 0, len(searchResp.Web.Results))
+	for _, item := range searchResp.Web.Results {
+		results = append(results, SearchResult{Title: item.Title, URL: item.URL, Snippet: item.Description})
 	}
-
-	var lines []string
-	lines = append(lines, fmt.Sprintf("Results for: %s", query))
-	for i, item := range results {
-		if i >= count {
-			break
-		}
-		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.URL))
-		if item.Description != "" {
-			lines = append(lines, fmt.Sprintf("   %s", item.Description))
-		}
-	}
-
-	return strings.Join(lines, "\n"), nil
+	return results, nil
 }
 
 type TavilySearchProvider struct {
@@ -157,6 +200,14 @@ type TavilySearchProvider struct {
 }
 
 func (p *TavilySearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	results, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatWebResults(query, "Tavily", results, count), nil
+}
+
+func (p *TavilySearchProvider) searchResults(ctx context.Context, query string, count int) ([]SearchResult, error) {
 	searchURL := p.baseURL
 	if searchURL == "" {
 		searchURL = "https://api.tavily.com/search"
@@ -174,12 +225,12 @@ func (p *TavilySearchProvider) Search(ctx context.Context, query string, count i
 
 	bodyBytes, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", searchURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -187,17 +238,17 @@ func (p *TavilySearchProvider) Search(ctx context.Context, query string, count i
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("tavily api error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("tavily api error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var searchResp struct {
@@ -209,27 +260,14 @@ func (p *TavilySearchProvider) Search(ctx context.Context, query string, count i
 	}
 
 	if err := json.Unmarshal(body, &searchResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	results := searchResp.Results
-	if len(results) == 0 {
-		return fmt.Sprintf("No results for: %s", query), nil
+	results := make([]SearchResult, 0, len(searchResp.Results))
+	for _, item := range searchResp.Results {
+		results = append(results, SearchResult{Title: item.Title, URL: item.URL, Snippet: item.Content})
 	}
-
-	var lines []string
-	lines = append(lines, fmt.Sprintf("Results for: %s (via Tavily)", query))
-	for i, item := range results {
-		if i >= count {
-			break
-		}
-		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.URL))
-		if item.Content != "" {
-			lines = append(lines, fmt.Sprintf("   %s", item.Content))
-		}
-	}
-
-	return strings.Join(lines, "\n"), nil
+	return results, nil
 }
 
 type DuckDuckGoSearchProvider struct {
@@ -238,30 +276,41 @@ type DuckDuckGoSearchProvider struct {
 }
 
 func (p *DuckDuckGoSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	results, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No results found or extraction failed. Query: %s", query), nil
+	}
+	return formatWebResults(query, "DuckDuckGo", results, count), nil
+}
+
+func (p *DuckDuckGoSearchProvider) searchResults(ctx context.Context, query string, count int) ([]SearchResult, error) {
 	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", userAgent)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return p.extractResults(string(body), count, query)
+	return p.extractResults(string(body), count), nil
 }
 
-func (p *DuckDuckGoSearchProvider) extractResults(html string, count int, query string) (string, error) {
+func (p *DuckDuckGoSearchProvider) extractResults(html string, count int) []SearchResult {
 	// Simple regex based extraction for DDG HTML
 	// Strategy: Find all result containers or key anchors directly
 
@@ -271,11 +320,10 @@ func (p *DuckDuckGoSearchProvider) extractResults(html string, count int, query
 	matches := reDDGLink.FindAllStringSubmatch(html, count+5)
 
 	if len(matches) == 0 {
-		return fmt.Sprintf("No results found or extraction failed. Query: %s", query), nil
+		return nil
 	}
 
-	var lines []string
-	lines = append(lines, fmt.Sprintf("Results for: %s (via DuckDuckGo)", query))
+	var results []SearchResult
 
 	// Pre-compile snippet regex to run inside the loop
 	// We'll search for snippets relative to the link position or just globally if needed
@@ -304,19 +352,16 @@ func (p *DuckDuckGoSearchProvider) extractResults(html string, count int, query
 			}
 		}
 
-		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, title, urlStr))
+		result := SearchResult{Title: title, URL: urlStr}
 
 		// Attempt to attach snippet if available and index aligns
 		if i < len(snippetMatches) {
-			snippet := stripTags(snippetMatches[i][1])
-			snippet = strings.TrimSpace(snippet)
-			if snippet != "" {
-				lines = append(lines, fmt.Sprintf("   %s", snippet))
-			}
+			result.Snippet = strings.TrimSpace(stripTags(snippetMatches[i][1]))
 		}
+		results = append(results, result)
 	}
 
-	return strings.Join(lines, "\n"), nil
+	return results
 }
 
 func stripTags(content string) string {
@@ -330,6 +375,31 @@ type PerplexitySearchProvider struct {
 }
 
 func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	content, err := p.complete(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return fmt.Sprintf("No results for: %s", query), nil
+	}
+	return fmt.Sprintf("Results for: %s (via Perplexity)\n%s", query, content), nil
+}
+
+// searchResults parses the numbered list the model is prompted to answer
+// with. An answer in any other shape becomes a single result without URL.
+func (p *PerplexitySearchProvider) searchResults(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	content, err := p.complete(ctx, query, count)
+	if err != nil || content == "" {
+		return nil, err
+	}
+	if results := parseNumberedResults(content); len(results) > 0 {
+		return results, nil
+	}
+	return []SearchResult{{Title: "Perplexity answer", Snippet: content}}, nil
+}
+
+// complete asks the model for search results and returns its answer.
+func (p *PerplexitySearchProvider) complete(ctx context.Context, query string, count int) (string, error) {
 	searchURL := "https://api.perplexity.ai/chat/completions"
 
 	payload := map[string]any{
@@ -389,10 +459,47 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 	}
 
 	if len(searchResp.Choices) == 0 {
-		return fmt.Sprintf("No results for: %s", query), nil
+		return "", nil
+	}
+	return searchResp.Choices[0].Message.Content, nil
+}
+
+// parseNumberedResults parses "1. Title\n   URL\n   Description" lists,
+// tolerating Markdown emphasis and links around the title and URL.
+func parseNumberedResults(content string) []SearchResult {
+	var results []SearchResult
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if m := reNumberedItem.FindStringSubmatch(line); m != nil {
+			result := SearchResult{Title: m[1]}
+			if link := reMarkdownLink.FindStringSubmatch(m[1]); link != nil {
+				result.Title, result.URL = link[1], link[2]
+			}
+			result.Title = strings.Trim(result.Title, "*_ ")
+			results = append(results, result)
+			continue
+		}
+		if len(results) == 0 || line == "" {
+			continue
+		}
+		last := &results[len(results)-1]
+		if u := reBareURL.FindString(line); last.URL == "" && u != "" {
+			last.URL = strings.TrimRight(u, ".,;)>]")
+			continue
+		}
+		if last.Snippet != "" {
+			last.Snippet += " "
+		}
+		last.Snippet += line
 	}
 
-	return fmt.Sprintf("Results for: %s (via Perplexity)\n%s", query, searchResp.Choices[0].Message.Content), nil
+	valid := results[:0]
+	for _, r := range results {
+		if r.URL != "" {
+			valid = append(valid, r)
+		}
+	}
+	return valid
 }
 
 type SearXNGSearchProvider struct {
@@ -400,24 +507,32 @@ type SearXNGSearchProvider struct {
 }
 
 func (p *SearXNGSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	results, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatWebResults(query, "SearXNG", results, count), nil
+}
+
+func (p *SearXNGSearchProvider) searchResults(ctx context.Context, query string, count int) ([]SearchResult, error) {
 	searchURL := fmt.Sprintf("%s/search?q=%s&format=json&categories=general",
 		strings.TrimSuffix(p.baseURL, "/"),
 		url.QueryEscape(query))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("SearXNG returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("SearXNG returned status %d", resp.StatusCode)
 	}
 
 	var result struct {
@@ -431,30 +546,14 @@ func (p *SearXNGSearchProvider) Search(ctx context.Context, query string, count
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	if len(result.Results) == 0 {
-		return fmt.Sprintf("No results for: %s", query), nil
-	}
-
-	// Limit results to requested count
-	if len(result.Results) > count {
-		result.Results = result.Results[:count]
-	}
-
-	// Format results in standard PicoClaw format
-	var b strings.Builder
-	b.WriteString(fmt.Sprintf("Results for: %s (via SearXNG)\n", query))
-	for i, r := range result.Results {
-		b.WriteString(fmt.Sprintf("%d. %s\n", i+1, r.Title))
-		b.WriteString(fmt.Sprintf("   %s\n", r.URL))
-		if r.Content != "" {
-			b.WriteString(fmt.Sprintf("   %s\n", r.Content))
-		}
+	results := make([]SearchResult, 0, len(result.Results))
+	for _, r := range result.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content})
 	}
-
-	return b.String(), nil
+	return results, nil
 }
 
 type GLMSearchProvider struct {
@@ -466,6 +565,14 @@ type GLMSearchProvider struct {
 }
 
 func (p *GLMSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	results, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatWebResults(query, "GLM Search", results, count), nil
+}
+
+func (p *GLMSearchProvider) searchResults(ctx context.Context, query string, count int) ([]SearchResult, error) {
 	searchURL := p.baseURL
 	if searchURL == "" {
 		searchURL = "https://open.bigmodel.cn/api/paas/v4/web_search"
@@ -481,12 +588,12 @@ func (p *GLMSearchProvider) Search(ctx context.Context, query string, count int)
 
 	bodyBytes, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", searchURL, bytes.NewReader(bodyBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -494,17 +601,17 @@ func (p *GLMSearchProvider) Search(ctx context.Context, query string, count int)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GLM Search API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("GLM Search API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var searchResp struct {
@@ -516,27 +623,14 @@ func (p *GLMSearchProvider) Search(ctx context.Context, query string, count int)
 	}
 
 	if err := json.Unmarshal(body, &searchResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	results := searchResp.SearchResult
-	if len(results) == 0 {
-		return fmt.Sprintf("No results for: %s", query), nil
+	results := make([]SearchResult, 0, len(searchResp.SearchResult))
+	for _, item := range searchResp.SearchResult {
+		results = append(results, SearchResult{Title: item.Title, URL: item.Link, Snippet: item.Content})
 	}
-
-	var lines []string
-	lines = append(lines, fmt.Sprintf("Results for: %s (via GLM Search)", query))
-	for i, item := range results {
-		if i >= count {
-			break
-		}
-		lines = append(lines, fmt.Sprintf("%d. %s\n   %s", i+1, item.Title, item.Link))
-		if item.Content != "" {
-			lines = append(lines, fmt.Sprintf("   %s", item.Content))
-		}
-	}
-
-	return strings.Join(lines, "\n"), nil
+	return results, nil
 }
 
 type WebSearchTool struct {
@@ -566,92 +660,157 @@ type WebSearchToolOptions struct {
 	GLMSearchMaxResults  int
 	GLMSearchEnabled     bool
 	Proxy                string
+
+	// Providers, when set, switches to aggregated search over these
+	// providers ("brave", "tavily", "duckduckgo", "perplexity", "searxng",
+	// "glm_search"), tried in order; see AggregateSearchProvider.
+	Providers      []string
+	MergeProviders int
+	CacheTTL       time.Duration
 }
 
 func NewWebSearchTool(opts WebSearchToolOptions) (*WebSearchTool, error) {
-	var provider SearchProvider
-	maxResults := 5
+	if len(opts.Providers) > 0 {
+		return newAggregateWebSearchTool(opts)
+	}
 
 	// Priority: Perplexity > Brave > SearXNG > Tavily > DuckDuckGo > GLM Search
-	if opts.PerplexityEnabled && opts.PerplexityAPIKey != "" {
+	for _, name := range []string{"perplexity", "brave", "searxng", "tavily", "duckduckgo", "glm_search"} {
+		provider, maxResults, err := newSearchProvider(name, opts)
+		if err != nil {
+			return nil, err
+		}
+		if provider != nil {
+			return &WebSearchTool{
+				provider:   provider,
+				maxResults: maxResults,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func newAggregateWebSearchTool(opts WebSearchToolOptions) (*WebSearchTool, error) {
+	var list []namedSearchProvider
+	maxResults := 0
+	for _, name := range opts.Providers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		provider, providerMax, err := newSearchProvider(name, opts)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			logger.WarnCF("tool", "Web search provider is not enabled or configured, skipping",
+				map[string]any{"provider": name})
+			continue
+		}
+		list = append(list, namedSearchProvider{name: name, provider: provider})
+		maxResults = max(maxResults, providerMax)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("none of the web search providers %v is enabled and configured", opts.Providers)
+	}
+	return &WebSearchTool{
+		provider:   newAggregateSearchProvider(list, opts.MergeProviders, opts.CacheTTL),
+		maxResults: maxResults,
+	}, nil
+}
+
+// newSearchProvider creates the named provider and returns it with its
+// default result count, or nil if it is not enabled and configured.
+func newSearchProvider(name string, opts WebSearchToolOptions) (resultSearchProvider, int, error) {
+	maxResults := func(n int) int {
+		if n > 0 {
+			return n
+		}
+		return 5
+	}
+
+	switch name {
+	case "perplexity":
+		if !opts.PerplexityEnabled || opts.PerplexityAPIKey == "" {
+			return nil, 0, nil
+		}
 		client, err := createHTTPClient(opts.Proxy, perplexityTimeout)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP client for Perplexity: %w", err)
+			return nil, 0, fmt.Errorf("failed to create HTTP client for Perplexity: %w", err)
 		}
-		provider = &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey, proxy: opts.Proxy, client: client}
-		if opts.PerplexityMaxResults > 0 {
-			maxResults = opts.PerplexityMaxResults
+		provider := &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey, proxy: opts.Proxy, client: client}
+		return provider, maxResults(opts.PerplexityMaxResults), nil
+	case "brave":
+		if !opts.BraveEnabled || opts.BraveAPIKey == "" {
+			return nil, 0, nil
 		}
-	} else if opts.BraveEnabled && opts.BraveAPIKey != "" {
 		client, err := createHTTPClient(opts.Proxy, searchTimeout)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP client for Brave: %w", err)
+			return nil, 0, fmt.Errorf("failed to create HTTP client for Brave: %w", err)
 		}
-		provider = &BraveSearchProvider{apiKey: opts.BraveAPIKey, proxy: opts.Proxy, client: client}
-		if opts.BraveMaxResults > 0 {
-			maxResults = opts.BraveMaxResults
+		provider := &BraveSearchProvider{apiKey: opts.BraveAPIKey, proxy: opts.Proxy, client: client}
+		return provider, maxResults(opts.BraveMaxResults), nil
+	case "searxng":
+		if !opts.SearXNGEnabled || opts.SearXNGBaseURL == "" {
+			return nil, 0, nil
 		}
-	} else if opts.SearXNGEnabled && opts.SearXNGBaseURL != "" {
-		provider = &SearXNGSearchProvider{baseURL: opts.SearXNGBaseURL}
-		if opts.SearXNGMaxResults > 0 {
-			maxResults = opts.SearXNGMaxResults
+		provider := &SearXNGSearchProvider{baseURL: opts.SearXNGBaseURL}
+		return provider, maxResults(opts.SearXNGMaxResults), nil
+	case "tavily":
+		if !opts.TavilyEnabled || opts.TavilyAPIKey == "" {
+			return nil, 0, nil
 		}
-	} else if opts.TavilyEnabled && opts.TavilyAPIKey != "" {
 		client, err := createHTTPClient(opts.Proxy, searchTimeout)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP client for Tavily: %w", err)
+			return nil, 0, fmt.Errorf("failed to create HTTP client for Tavily: %w", err)
 		}
-		provider = &TavilySearchProvider{
+		provider := &TavilySearchProvider{
 			apiKey:  opts.TavilyAPIKey,
 			baseURL: opts.TavilyBaseURL,
 			proxy:   opts.Proxy,
 			client:  client,
 		}
-		if opts.TavilyMaxResults > 0 {
-			maxResults = opts.TavilyMaxResults
+		return provider, maxResults(opts.TavilyMaxResults), nil
+	case "duckduckgo":
+		if !opts.DuckDuckGoEnabled {
+			return nil, 0, nil
 		}
-	} else if opts.DuckDuckGoEnabled {
 		client, err := createHTTPClient(opts.Proxy, searchTimeout)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP client for DuckDuckGo: %w", err)
+			return nil, 0, fmt.Errorf("failed to create HTTP client for DuckDuckGo: %w", err)
 		}
-		provider = &DuckDuckGoSearchProvider{proxy: opts.Proxy, client: client}
-		if opts.DuckDuckGoMaxResults > 0 {
-			maxResults = opts.DuckDuckGoMaxResults
+		provider := &DuckDuckGoSearchProvider{proxy: opts.Proxy, client: client}
+		return provider, maxResults(opts.DuckDuckGoMaxResults), nil
+	case "glm_search":
+		if !opts.GLMSearchEnabled || opts.GLMSearchAPIKey == "" {
+			return nil, 0, nil
 		}
-	} else if opts.GLMSearchEnabled && opts.GLMSearchAPIKey != "" {
 		client, err := createHTTPClient(opts.Proxy, searchTimeout)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP client for GLM Search: %w", err)
+			return nil, 0, fmt.Errorf("failed to create HTTP client for GLM Search: %w", err)
 		}
 		searchEngine := opts.GLMSearchEngine
 		if searchEngine == "" {
 			searchEngine = "search_std"
 		}
-		provider = &GLMSearchProvider{
+		provider := &GLMSearchProvider{
 			apiKey:       opts.GLMSearchAPIKey,
 			baseURL:      opts.GLMSearchBaseURL,
 			searchEngine: searchEngine,
 			proxy:        opts.Proxy,
 			client:       client,
 		}
-		if opts.GLMSearchMaxResults > 0 {
-			maxResults = opts.GLMSearchMaxResults
-		}
-	} else {
-		return nil, nil
+		return provider, maxResults(opts.GLMSearchMaxResults), nil
 	}
-
-	return &WebSearchTool{
-		provider:   provider,
-		maxResults: maxResults,
-	}, nil
+	return nil, 0, fmt.Errorf("unknown web search provider %q", name)
 }
 
 func (t *WebSearchTool) Name() string {
 	return "web_search"
 }
 
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *WebSearchTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *WebSearchTool) Description() string {
 	return "Search the web for current information. Returns titles, URLs, and snippets from search results."
 }
@@ -703,19 +862,39 @@ type WebFetchTool struct {
 	maxChars        int
 	proxy           string
 	client          *http.Client
+	guard           *ssrfGuard
 	fetchLimitBytes int64
 }
 
+// WebFetchToolOptions configures NewWebFetchToolWithOptions.
+type WebFetchToolOptions struct {
+	MaxChars        int
+	Proxy           string
+	FetchLimitBytes int64
+	// TrustedHosts are host names, IPs and CIDR ranges exempt from the
+	// block on loopback, private, link-local and metadata addresses.
+	TrustedHosts []string
+}
+
 func NewWebFetchTool(maxChars int, fetchLimitBytes int64) (*WebFetchTool, error) {
 	// createHTTPClient cannot fail with an empty proxy string.
 	return NewWebFetchToolWithProxy(maxChars, "", fetchLimitBytes)
 }
 
 func NewWebFetchToolWithProxy(maxChars int, proxy string, fetchLimitBytes int64) (*WebFetchTool, error) {
+	return NewWebFetchToolWithOptions(WebFetchToolOptions{
+		MaxChars:        maxChars,
+		Proxy:           proxy,
+		FetchLimitBytes: fetchLimitBytes,
+	})
+}
+
+func NewWebFetchToolWithOptions(opts WebFetchToolOptions) (*WebFetchTool, error) {
+	maxChars := opts.MaxChars
 	if maxChars <= 0 {
 		maxChars = defaultMaxChars
 	}
-	client, err := createHTTPClient(proxy, fetchTimeout)
+	client, err := createHTTPClient(opts.Proxy, fetchTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP client for web fetch: %w", err)
 	}
@@ -725,13 +904,21 @@ func NewWebFetchToolWithProxy(maxChars int, proxy string, fetchLimitBytes int64)
 		}
 		return nil
 	}
+	guard, err := newSSRFGuard(opts.TrustedHosts)
+	if err != nil {
+		return nil, err
+	}
+	guard.install(client)
+
+	fetchLimitBytes := opts.FetchLimitBytes
 	if fetchLimitBytes <= 0 {
 		fetchLimitBytes = 10 * 1024 * 1024 // Security Fallback
 	}
 	return &WebFetchTool{
 		maxChars:        maxChars,
-		proxy:           proxy,
+		proxy:           opts.Proxy,
 		client:          client,
+		guard:           guard,
 		fetchLimitBytes: fetchLimitBytes,
 	}, nil
 }
@@ -740,8 +927,16 @@ func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
+// ConcurrencySafe implements ConcurrencySafe.
+func (t *WebFetchTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL and extract readable content. HTML pages are reduced to their main content and converted " +
+		"to Markdown with links, headings and tables; JSON and plain text are returned as is. Long documents are " +
+		"returned in pages: pass next_offset from a truncated result as offset to continue. Use this to get weather " +
+		"info, news, articles, or any web content."
 }
 
 func (t *WebFetchTool) Parameters() map[string]any {
@@ -757,6 +952,11 @@ func (t *WebFetchTool) Parameters() map[string]any {
 				"description": "Maximum characters to extract",
 				"minimum":     100.0,
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Character offset to start reading from, for paging through long documents",
+				"minimum":     0.0,
+			},
 		},
 		"required": []string{"url"},
 	}
@@ -781,12 +981,20 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("missing domain in URL")
 	}
 
+	if err := t.guard.checkURL(ctx, parsedURL); err != nil {
+		return ErrorResult(err.Error())
+	}
+
 	maxChars := t.maxChars
 	if mc, ok := args["maxChars"].(float64); ok {
 		if int(mc) > 100 {
 			maxChars = int(mc)
 		}
 	}
+	offset := 0
+	if o, ok := args["offset"].(float64); ok && o > 0 {
+		offset = int(o)
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
@@ -797,6 +1005,9 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 
 	resp, err := t.client.Do(req)
 	if err != nil {
+		if blocked, ok := blockedError(err); ok {
+			return ErrorResult(blocked.Error())
+		}
 		return ErrorResult(fmt.Sprintf("request failed: %v", err))
 	}
 
@@ -813,11 +1024,11 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult(fmt.Sprintf("failed to read response: %v", err))
 	}
 
-	contentType := resp.Header.Get("Content-Type")
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
 
-	var text, extractor string
+	var title, text, extractor string
 
-	if strings.Contains(contentType, "application/json") {
+	if strings.Contains(contentType, "json") {
 		var jsonData any
 		if err := json.Unmarshal(body, &jsonData); err == nil {
 			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
@@ -827,27 +1038,44 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 			text = string(body)
 			extractor = "raw"
 		}
-	} else if strings.Contains(contentType, "text/html") || len(body) > 0 &&
-		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
-		text = t.extractText(string(body))
-		extractor = "text"
+	} else if strings.Contains(contentType, "text/html") || strings.Contains(contentType, "application/xhtml") ||
+		len(body) > 0 && (strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
+		var readable bool
+		title, text, readable = extractReadable(string(body), resp.Request.URL)
+		extractor = "markdown"
+		if readable {
+			extractor = "readability"
+		}
+		if text == "" {
+			text = t.extractText(string(body))
+			extractor = "text"
+		}
 	} else {
 		text = string(body)
 		extractor = "raw"
 	}
 
-	truncated := len(text) > maxChars
-	if truncated {
-		text = text[:maxChars]
+	page, nextOffset, total := pageText(text, offset, maxChars)
+	if offset > 0 && offset >= total {
+		return ErrorResult(fmt.Sprintf("offset %d is past the end of the content (%d characters)", offset, total))
 	}
+	truncated := nextOffset > 0
 
 	result := map[string]any{
-		"url":       urlStr,
-		"status":    resp.StatusCode,
-		"extractor": extractor,
-		"truncated": truncated,
-		"length":    len(text),
-		"text":      text,
+		"url":          urlStr,
+		"status":       resp.StatusCode,
+		"extractor":    extractor,
+		"truncated":    truncated,
+		"offset":       offset,
+		"length":       utf8.RuneCountInString(page),
+		"total_length": total,
+		"text":         page,
+	}
+	if title != "" {
+		result["title"] = title
+	}
+	if truncated {
+		result["next_offset"] = nextOffset
 	}
 
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
@@ -855,8 +1083,9 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	return &ToolResult{
 		ForLLM: string(resultJSON),
 		ForUser: fmt.Sprintf(
-			"Fetched %d bytes from %s (extractor: %s, truncated: %v)",
-			len(text),
+			"Fetched %d of %d characters from %s (extractor: %s, truncated: %v)",
+			utf8.RuneCountInString(page),
+			total,
 			urlStr,
 			extractor,
 			truncated,
@@ -864,6 +1093,29 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	}
 }
 
+// pageText returns up to maxChars characters of text starting at offset,
+// the offset of the next page or 0 if this is the last one, and the
+// length of text in characters. Pages end at a line break when there is
+// one in their last fifth.
+func pageText(text string, offset, maxChars int) (page string, next, total int) {
+	runes := []rune(text)
+	total = len(runes)
+	if offset >= total {
+		return "", 0, total
+	}
+	end := offset + maxChars
+	if end >= total {
+		return string(runes[offset:]), 0, total
+	}
+	for i := end - 1; i > end-maxChars/5; i-- {
+		if runes[i] == '\n' {
+			end = i + 1
+			break
+		}
+	}
+	return string(runes[offset:end]), end, total
+}
+
 func (t *WebFetchTool) extractText(htmlContent string) string {
 	result := reScript.ReplaceAllLiteralString(htmlContent, "")
 	result = reStyle.ReplaceAllLiteralString(result, "")
@@ -0,0 +1,667 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Class and id patterns used to find the main content of a page, after
+// Mozilla's Readability.
+var (
+	reUnlikelyCandidate = regexp.MustCompile(`(?i)-ad-|ad-break|agegate|banner|breadcrumb|combx|comment|community|cookie|` +
+		`disqus|extra|footer|gdpr|legends|menu|modal|nav|pagination|pager|popup|related|remark|replies|rss|share|` +
+		`shoutbox|sidebar|skyscraper|social|sponsor|subscribe|yom-remote`)
+	reMaybeCandidate = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	rePositiveClass  = regexp.MustCompile(`(?i)article|blog|body|content|entry|h-entry|hentry|main|page|post|story|text`)
+	reNegativeClass  = regexp.MustCompile(`(?i)-ad-|banner|combx|comment|com-|contact|footer|gdpr|hidden|masthead|` +
+		`media|meta|outbrain|promo|related|scroll|share|shopping|shoutbox|sidebar|skyscraper|sponsor|tags|widget`)
+	reSpaces = regexp.MustCompile(`[ \t\r\n\f]+`)
+)
+
+// Elements dropped before scoring: never content, or page chrome.
+var extractDropTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Svg: true, atom.Canvas: true,
+	atom.Form: true, atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true,
+	atom.Nav: true, atom.Aside: true, atom.Footer: true, atom.Dialog: true, atom.Link: true, atom.Meta: true,
+}
+
+// Elements rendered as blocks; everything else is inline.
+var extractBlockTags = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Center: true, atom.Dd: true,
+	atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Figure: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Summary: true, atom.Table: true, atom.Ul: true,
+}
+
+// extractReadable converts an HTML page to Markdown. It returns the page
+// title, the Markdown and whether it was narrowed to the main content;
+// otherwise the whole body is converted. base resolves relative links.
+func extractReadable(htmlContent string, base *url.URL) (title, markdown string, readable bool) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", "", false
+	}
+	title = pageTitle(doc)
+
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	pruneChrome(body, false)
+
+	conv := &markdownConverter{base: base}
+	if content := mainContent(body); content != nil {
+		if markdown = conv.render(content); len(markdown) >= 200 {
+			return title, markdown, true
+		}
+	}
+	return title, conv.render([]*html.Node{body}), false
+}
+
+func pageTitle(doc *html.Node) string {
+	if n := findElement(doc, atom.Title); n != nil {
+		if title := collapseSpaces(textContent(n)); title != "" {
+			return title
+		}
+	}
+	if n := findElement(doc, atom.H1); n != nil {
+		return collapseSpaces(textContent(n))
+	}
+	return ""
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	val, _ := findAttr(n, key)
+	return val
+}
+
+// pruneChrome removes elements that are hidden, never content, or whose
+// class or id marks them as navigation, comments, ads and the like.
+// Site headers are kept inside articles, where they hold the headline.
+func pruneChrome(n *html.Node, inArticle bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			if shouldPrune(c, inArticle) {
+				n.RemoveChild(c)
+			} else {
+				pruneChrome(c, inArticle || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+			}
+		}
+		c = next
+	}
+}
+
+func shouldPrune(n *html.Node, inArticle bool) bool {
+	if extractDropTags[n.DataAtom] || (n.DataAtom == atom.Header && !inArticle) {
+		return true
+	}
+	if _, hidden := findAttr(n, "hidden"); hidden || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+	switch attr(n, "role") {
+	case "navigation", "banner", "complementary", "contentinfo", "dialog", "alertdialog", "menu", "menubar":
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Body, atom.Article, atom.Main, atom.A, atom.Table, atom.Tbody, atom.Thead, atom.Tr, atom.Td, atom.Th:
+		return false
+	}
+	match := attr(n, "class") + " " + attr(n, "id")
+	return reUnlikelyCandidate.MatchString(match) && !reMaybeCandidate.MatchString(match)
+}
+
+func findAttr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// mainContent scores the ancestors of every paragraph by the amount of
+// text and commas it holds, adjusted for tag, class and link density, and
+// returns the best candidate together with related siblings, or nil if
+// the page has no paragraphs of text.
+func mainContent(body *html.Node) []*html.Node {
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	initScore := func(n *html.Node) {
+		if _, ok := scores[n]; ok {
+			return
+		}
+		scores[n] = tagWeight(n) + classWeight(n)
+		candidates = append(candidates, n)
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if !isScorable(c) {
+				walk(c)
+				continue
+			}
+			text := collapseSpaces(textContent(c))
+			if len(text) < 25 {
+				continue
+			}
+			score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+			level := 0
+			for p := c.Parent; p != nil && p.Type == html.ElementNode && level < 3; p = p.Parent {
+				initScore(p)
+				switch level {
+				case 0:
+					scores[p] += score
+				case 1:
+					scores[p] += score / 2
+				default:
+					scores[p] += score / float64(level*3)
+				}
+				level++
+			}
+		}
+	}
+	walk(body)
+
+	var top *html.Node
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		return nil
+	}
+	if top.Parent == nil || top == body {
+		return []*html.Node{top}
+	}
+
+	// Articles are often split across sibling containers; keep the ones
+	// that score well or read like prose.
+	threshold := math.Max(10, scores[top]*0.2)
+	var content []*html.Node
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		if s.Type != html.ElementNode {
+			continue
+		}
+		if s == top {
+			content = append(content, s)
+			continue
+		}
+		bonus := 0.0
+		if attr(s, "class") != "" && attr(s, "class") == attr(top, "class") {
+			bonus = scores[top] * 0.2
+		}
+		if score, ok := scores[s]; ok && score+bonus >= threshold {
+			content = append(content, s)
+			continue
+		}
+		if s.DataAtom == atom.P {
+			text := collapseSpaces(textContent(s))
+			density := linkDensity(s)
+			if (len(text) > 80 && density < 0.25) ||
+				(len(text) > 0 && density == 0 && strings.Contains(text, ". ")) {
+				content = append(content, s)
+			}
+		}
+	}
+	return content
+}
+
+// isScorable reports whether n is a paragraph-like element: a p, pre or
+// td, or a div holding only inline content.
+func isScorable(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td:
+		return true
+	case atom.Div:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && extractBlockTags[c.DataAtom] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func tagWeight(n *html.Node) float64 {
+	switch n.DataAtom {
+	case atom.Article:
+		return 10
+	case atom.Div, atom.Main:
+		return 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		return 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		return -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		return -5
+	}
+	return 0
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, value := range []string{attr(n, "class"), attr(n, "id")} {
+		if value == "" {
+			continue
+		}
+		if reNegativeClass.MatchString(value) {
+			weight -= 25
+		}
+		if rePositiveClass.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity returns the share of the text of n that is link text.
+func linkDensity(n *html.Node) float64 {
+	total := len(collapseSpaces(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.DataAtom == atom.A {
+				links += len(collapseSpaces(textContent(c)))
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(links) / float64(total)
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+func collapseSpaces(s string) string {
+	return strings.TrimSpace(reSpaces.ReplaceAllString(s, " "))
+}
+
+// markdownConverter renders HTML as Markdown, keeping headings, links,
+// emphasis, lists, code, quotes and tables.
+type markdownConverter struct {
+	base *url.URL
+}
+
+func (m *markdownConverter) render(nodes []*html.Node) string {
+	var blocks []string
+	for _, n := range nodes {
+		var b string
+		if n.Type == html.ElementNode && !extractBlockTags[n.DataAtom] {
+			// Containers such as body or td.
+			b = m.blocks(n, "\n\n")
+		} else {
+			b = m.block(n)
+		}
+		if b != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// blocks renders the children of n as block content joined by sep. Runs
+// of inline children become paragraphs.
+func (m *markdownConverter) blocks(n *html.Node, sep string) string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if p := cleanInline(inline.String()); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && extractBlockTags[c.DataAtom] {
+			flush()
+			if b := m.block(c); b != "" {
+				out = append(out, b)
+			}
+			continue
+		}
+		m.inline(&inline, c)
+	}
+	flush()
+	return strings.Join(out, sep)
+}
+
+func (m *markdownConverter) block(n *html.Node) string {
+	if n.Type != html.ElementNode || !extractBlockTags[n.DataAtom] {
+		var sb strings.Builder
+		m.inline(&sb, n)
+		return cleanInline(sb.String())
+	}
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		var sb strings.Builder
+		m.inline(&sb, n)
+		text := strings.ReplaceAll(cleanInline(sb.String()), "\n", " ")
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case atom.Hr:
+		return "---"
+	case atom.Pre:
+		return m.codeBlock(n)
+	case atom.Blockquote:
+		return prefixLines(m.blocks(n, "\n\n"), "> ", ">")
+	case atom.Ul, atom.Ol:
+		return m.list(n)
+	case atom.Table:
+		return m.table(n)
+	case atom.Li:
+		// A list item outside a list.
+		if body := m.blocks(n, "\n"); body != "" {
+			return "- " + prefixLines(body, "  ", "")[2:]
+		}
+		return ""
+	}
+	return m.blocks(n, "\n\n")
+}
+
+func (m *markdownConverter) codeBlock(n *html.Node) string {
+	code := strings.Trim(textContent(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	lang := ""
+	if c := findElement(n, atom.Code); c != nil {
+		for _, class := range strings.Fields(attr(c, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+				break
+			}
+		}
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func (m *markdownConverter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	num := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		num = start
+	}
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		body := m.blocks(c, "\n")
+		if body == "" {
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+prefixLines(body, indent, "")[len(indent):])
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders data tables as Markdown tables with the first row as the
+// header. Layout tables, with nested tables or a single column, are
+// rendered as blocks.
+func (m *markdownConverter) table(n *html.Node) string {
+	var rows [][]string
+	layout := false
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(c)
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+						continue
+					}
+					if findElement(cell, atom.Table) != nil {
+						layout = true
+					}
+					var sb strings.Builder
+					m.inline(&sb, cell)
+					text := strings.ReplaceAll(cleanInline(sb.String()), "\n", " ")
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					if span, err := strconv.Atoi(attr(cell, "colspan")); err == nil {
+						for i := 1; i < span && i < 50; i++ {
+							row = append(row, "")
+						}
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	collect(n)
+
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	if layout || cols < 2 {
+		return m.blocks(n, "\n\n")
+	}
+
+	var sb strings.Builder
+	if caption := findElement(n, atom.Caption); caption != nil {
+		if text := collapseSpaces(textContent(caption)); text != "" {
+			sb.WriteString("**" + text + "**\n\n")
+		}
+	}
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(rows[0])
+	sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (m *markdownConverter) inline(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(reSpaces.ReplaceAllString(n.Data, " "))
+		return
+	case html.ElementNode:
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			m.inline(sb, c)
+		}
+		return
+	}
+
+	wrap := func(mark string) {
+		var inner strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			m.inline(&inner, c)
+		}
+		s := inner.String()
+		text := strings.TrimSpace(s)
+		if text == "" {
+			sb.WriteString(s)
+			return
+		}
+		if strings.HasPrefix(s, " ") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(mark + text + mark)
+		if strings.HasSuffix(s, " ") {
+			sb.WriteString(" ")
+		}
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		sb.WriteString("\n")
+	case atom.Strong, atom.B:
+		wrap("**")
+	case atom.Em, atom.I:
+		wrap("*")
+	case atom.Del, atom.S, atom.Strike:
+		wrap("~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		if code := collapseSpaces(textContent(n)); code != "" {
+			fence := "`"
+			if strings.Contains(code, "`") {
+				fence = "``"
+			}
+			sb.WriteString(fence + code + fence)
+		}
+	case atom.A:
+		var inner strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			m.inline(&inner, c)
+		}
+		text := strings.TrimSpace(strings.ReplaceAll(inner.String(), "\n", " "))
+		href := m.resolve(attr(n, "href"))
+		switch {
+		case text == "":
+		case href == "":
+			sb.WriteString(inner.String())
+		default:
+			fmt.Fprintf(sb, "[%s](%s)", text, href)
+		}
+	case atom.Img:
+		src := m.resolve(attr(n, "src"))
+		if src != "" {
+			fmt.Fprintf(sb, "![%s](%s)", collapseSpaces(attr(n, "alt")), src)
+		}
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && extractBlockTags[c.DataAtom] {
+				// Block content inside inline elements, e.g. <a><div>.
+				sb.WriteString(" " + m.block(c) + " ")
+				continue
+			}
+			m.inline(sb, c)
+		}
+	}
+}
+
+// resolve makes href absolute, dropping fragments, scripts and data URIs.
+func (m *markdownConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if m.base != nil {
+		u = m.base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto", "ftp", "":
+		return u.String()
+	}
+	return ""
+}
+
+// cleanInline trims the lines of an inline run and collapses the spaces
+// left between elements, dropping empty lines.
+func cleanInline(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		line = strings.TrimSpace(reSpaces.ReplaceAllString(line, " "))
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func prefixLines(s, prefix, emptyPrefix string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
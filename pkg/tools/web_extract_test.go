@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testArticlePage = `<!DOCTYPE html>
+<html><head><title>Growing Tomatoes</title><script>var tracking = 1;</script></head>
+<body>
+<header class="site-header"><a href="/">Home</a> <a href="/blog">Blog</a></header>
+<nav><ul><li><a href="/a">Gardening</a></li><li><a href="/b">Cooking</a></li></ul></nav>
+<div class="sidebar"><p>Subscribe to our newsletter for weekly tips, deals, and more, delivered every Monday.</p></div>
+<div id="main-content" class="post">
+  <h1>Growing Tomatoes</h1>
+  <p>Tomatoes need <strong>full sun</strong>, rich soil, and regular watering. Plant them after the last frost,
+     when the soil has warmed, and read our <a href="/guides/soil">soil guide</a> first.</p>
+  <h2>Varieties</h2>
+  <table>
+    <tr><th>Name</th><th>Days</th></tr>
+    <tr><td>Cherry</td><td>60</td></tr>
+    <tr><td>Beefsteak | large</td><td>85</td></tr>
+  </table>
+  <ol><li>Dig a deep hole</li><li>Bury the stem <em>up to the leaves</em></li></ol>
+  <pre><code class="language-sh">water --daily
+</code></pre>
+  <p>Stake the plants early, because, as they grow, the fruit gets heavy and the stems bend or break.</p>
+</div>
+<div class="comments"><p>Great post, thanks, I will try this, really, this weekend!</p></div>
+<footer>Copyright 2026, all rights reserved, no reuse without permission.</footer>
+</body></html>`
+
+func TestExtractReadable(t *testing.T) {
+	base, _ := url.Parse("https://garden.example/posts/tomatoes")
+	title, markdown, readable := extractReadable(testArticlePage, base)
+
+	assert.True(t, readable)
+	assert.Equal(t, "Growing Tomatoes", title)
+	assert.Contains(t, markdown, "# Growing Tomatoes\n\nTomatoes need **full sun**, rich soil")
+	assert.Contains(t, markdown, "[soil guide](https://garden.example/guides/soil) first.")
+	assert.Contains(t, markdown, "## Varieties")
+	assert.Contains(t, markdown, "| Name | Days |\n| --- | --- |\n| Cherry | 60 |\n| Beefsteak \\| large | 85 |")
+	assert.Contains(t, markdown, "1. Dig a deep hole\n2. Bury the stem *up to the leaves*")
+	assert.Contains(t, markdown, "```sh\nwater --daily\n```")
+	assert.Contains(t, markdown, "Stake the plants early")
+
+	for _, junk := range []string{"Gardening", "newsletter", "Great post", "Copyright", "tracking", "Blog"} {
+		assert.NotContains(t, markdown, junk)
+	}
+}
+
+func TestExtractReadable_WholePageFallback(t *testing.T) {
+	_, markdown, readable := extractReadable(`<html><body><h3>Status</h3><ul><li>api: up</li><li>db: up</li></ul></body></html>`, nil)
+	assert.False(t, readable)
+	assert.Equal(t, "### Status\n\n- api: up\n- db: up", markdown)
+}
+
+func TestMarkdownConverter_NestedList(t *testing.T) {
+	_, markdown, _ := extractReadable(`<ul><li>one<ul><li>one.a</li></ul></li><li><p>two</p><pre>x := 1</pre></li></ul>`, nil)
+	assert.Equal(t, "- one\n  - one.a\n- two\n  ```\n  x := 1\n  ```", markdown)
+}
+
+func TestPageText(t *testing.T) {
+	text := strings.Repeat("a", 90) + "\n" + strings.Repeat("b", 50)
+
+	page, next, total := pageText(text, 0, 100)
+	assert.Equal(t, 141, total)
+	assert.Equal(t, 91, next, "page should end at the line break")
+	assert.Equal(t, strings.Repeat("a", 90)+"\n", page)
+
+	page, next, _ = pageText(text, next, 100)
+	assert.Equal(t, strings.Repeat("b", 50), page)
+	assert.Zero(t, next)
+
+	// Offsets count characters, not bytes.
+	page, next, total = pageText("héllo wörld", 6, 3)
+	assert.Equal(t, "wör", page)
+	assert.Equal(t, 9, next)
+	assert.Equal(t, 11, total)
+}
+
+func TestWebFetchTool_Paging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testArticlePage))
+	}))
+	defer server.Close()
+
+	tool, err := newLocalWebFetchTool(200)
+	require.NoError(t, err)
+
+	var pages []string
+	offset := 0.0
+	for i := 0; i < 20; i++ {
+		result := tool.Execute(context.Background(), map[string]any{"url": server.URL, "maxChars": 200.0, "offset": offset})
+		require.False(t, result.IsError, result.ForLLM)
+
+		var got map[string]any
+		require.NoError(t, json.Unmarshal([]byte(result.ForLLM), &got))
+		assert.Equal(t, "readability", got["extractor"])
+		assert.Equal(t, "Growing Tomatoes", got["title"])
+		pages = append(pages, got["text"].(string))
+		next, ok := got["next_offset"].(float64)
+		if !ok {
+			assert.Equal(t, false, got["truncated"])
+			break
+		}
+		offset = next
+	}
+	require.Greater(t, len(pages), 1)
+
+	_, full, _ := extractReadable(testArticlePage, nil)
+	assert.Equal(t, strings.ReplaceAll(full, "(/guides/soil)", "("+server.URL+"/guides/soil)"), strings.Join(pages, ""))
+
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL, "offset": 100000.0})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "past the end")
+}
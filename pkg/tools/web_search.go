@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// searchCacheSize bounds the number of queries AggregateSearchProvider
+// keeps cached.
+const searchCacheSize = 128
+
+// Display names of the search providers, keyed by their config name.
+var searchProviderNames = map[string]string{
+	"brave":      "Brave",
+	"tavily":     "Tavily",
+	"duckduckgo": "DuckDuckGo",
+	"perplexity": "Perplexity",
+	"searxng":    "SearXNG",
+	"glm_search": "GLM Search",
+}
+
+type namedSearchProvider struct {
+	name     string
+	provider resultSearchProvider
+}
+
+type searchCacheEntry struct {
+	result  string
+	expires time.Time
+}
+
+// AggregateSearchProvider queries several search providers in order. Each
+// search merges the results of the first `merge` providers that return
+// any, fails over to the next provider on errors and skips providers that
+// are cooling down after a failure. Duplicate URLs are dropped and merged
+// results are cached for cacheTTL.
+type AggregateSearchProvider struct {
+	providers []namedSearchProvider
+	merge     int
+	cooldown  *providers.CooldownTracker
+	cacheTTL  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]searchCacheEntry
+}
+
+func newAggregateSearchProvider(list []namedSearchProvider, merge int, cacheTTL time.Duration) *AggregateSearchProvider {
+	return &AggregateSearchProvider{
+		providers: list,
+		merge:     max(1, merge),
+		cooldown:  providers.NewCooldownTracker(),
+		cacheTTL:  cacheTTL,
+		cache:     make(map[string]searchCacheEntry),
+	}
+}
+
+func (p *AggregateSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(query), " ")) + "\x00" + strconv.Itoa(count)
+	if result, ok := p.cached(key); ok {
+		return result, nil
+	}
+
+	results, used, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	result := formatWebResults(query, strings.Join(used, ", "), results, count)
+	if len(results) > 0 {
+		p.store(key, result)
+	}
+	return result, nil
+}
+
+// searchResults returns the merged results and the display names of the
+// providers that contributed to them.
+func (p *AggregateSearchProvider) searchResults(
+	ctx context.Context,
+	query string,
+	count int,
+) ([]SearchResult, []string, error) {
+	var lists [][]SearchResult
+	var used, failures []string
+	attempted := false
+
+	next := 0
+	for len(lists) < p.merge && next < len(p.providers) {
+		var batch []namedSearchProvider
+		for next < len(p.providers) && len(batch) < p.merge-len(lists) {
+			np := p.providers[next]
+			next++
+			if p.cooldown.IsAvailable(np.name) {
+				batch = append(batch, np)
+			}
+		}
+		if len(batch) == 0 && !attempted {
+			// Everything is cooling down; try the provider that recovers
+			// first rather than failing without a request.
+			batch = append(batch, p.soonestAvailable())
+		}
+		if len(batch) == 0 {
+			break
+		}
+		attempted = true
+
+		answers := make([][]SearchResult, len(batch))
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i, np := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				answers[i], errs[i] = np.provider.searchResults(ctx, query, count)
+			}()
+		}
+		wg.Wait()
+
+		for i, np := range batch {
+			if err := errs[i]; err != nil {
+				if ctx.Err() != nil {
+					return nil, nil, ctx.Err()
+				}
+				reason := providers.FailoverUnknown
+				if failover := providers.ClassifyError(err, np.name, ""); failover != nil {
+					reason = failover.Reason
+				}
+				p.cooldown.MarkFailure(np.name, reason)
+				logger.WarnCF("tool", "Web search provider failed, failing over",
+					map[string]any{
+						"provider": np.name,
+						"reason":   string(reason),
+						"cooldown": p.cooldown.CooldownRemaining(np.name).String(),
+						"error":    err.Error(),
+					})
+				failures = append(failures, fmt.Sprintf("%s: %v", np.name, err))
+				continue
+			}
+			p.cooldown.MarkSuccess(np.name)
+			if len(answers[i]) > 0 {
+				lists = append(lists, answers[i])
+				used = append(used, searchProviderNames[np.name])
+			}
+		}
+	}
+
+	if len(lists) == 0 && len(failures) > 0 {
+		return nil, nil, fmt.Errorf("all search providers failed: %s", strings.Join(failures, "; "))
+	}
+	return mergeSearchResults(lists), used, nil
+}
+
+func (p *AggregateSearchProvider) soonestAvailable() namedSearchProvider {
+	best := p.providers[0]
+	for _, np := range p.providers[1:] {
+		if p.cooldown.CooldownRemaining(np.name) < p.cooldown.CooldownRemaining(best.name) {
+			best = np
+		}
+	}
+	return best
+}
+
+func (p *AggregateSearchProvider) cached(key string) (string, bool) {
+	if p.cacheTTL <= 0 {
+		return "", false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.result, true
+}
+
+func (p *AggregateSearchProvider) store(key, result string) {
+	if p.cacheTTL <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if len(p.cache) >= searchCacheSize {
+		var oldest string
+		for k, entry := range p.cache {
+			if now.After(entry.expires) {
+				delete(p.cache, k)
+				continue
+			}
+			if oldest == "" || entry.expires.Before(p.cache[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(p.cache) >= searchCacheSize {
+			delete(p.cache, oldest)
+		}
+	}
+	p.cache[key] = searchCacheEntry{result: result, expires: now.Add(p.cacheTTL)}
+}
+
+// mergeSearchResults interleaves the result lists by rank, so the top hit
+// of every provider comes first, and drops results whose URL was already
+// seen. Results without a URL are kept as they are.
+func mergeSearchResults(lists [][]SearchResult) []SearchResult {
+	var merged []SearchResult
+	seen := make(map[string]int)
+	for rank := 0; ; rank++ {
+		added := false
+		for _, list := range lists {
+			if rank >= len(list) {
+				continue
+			}
+			added = true
+			r := list[rank]
+			key := normalizeResultURL(r.URL)
+			if key == "" {
+				merged = append(merged, r)
+				continue
+			}
+			if i, ok := seen[key]; ok {
+				if merged[i].Snippet == "" {
+					merged[i].Snippet = r.Snippet
+				}
+				continue
+			}
+			seen[key] = len(merged)
+			merged = append(merged, r)
+		}
+		if !added {
+			return merged
+		}
+	}
+}
+
+// normalizeResultURL returns the key under which two result URLs count as
+// the same page: scheme, "www." prefix, fragment, trailing slash and
+// tracking parameters are ignored.
+func normalizeResultURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	query := u.Query()
+	for k := range query {
+		if strings.HasPrefix(k, "utm_") || k == "ref" || k == "fbclid" || k == "gclid" {
+			query.Del(k)
+		}
+	}
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSearchProvider struct {
+	results []SearchResult
+	err     error
+	calls   atomic.Int32
+}
+
+func (p *fakeSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
+	results, err := p.searchResults(ctx, query, count)
+	if err != nil {
+		return "", err
+	}
+	return formatWebResults(query, "", results, count), nil
+}
+
+func (p *fakeSearchProvider) searchResults(context.Context, string, int) ([]SearchResult, error) {
+	p.calls.Add(1)
+	return p.results, p.err
+}
+
+func TestAggregateSearchProvider_Failover(t *testing.T) {
+	brave := &fakeSearchProvider{err: errors.New("brave api error (status 429): rate limited")}
+	ddg := &fakeSearchProvider{results: []SearchResult{{Title: "Go", URL: "https://go.dev"}}}
+	p := newAggregateSearchProvider([]namedSearchProvider{
+		{name: "brave", provider: brave},
+		{name: "duckduckgo", provider: ddg},
+	}, 1, 0)
+
+	result, err := p.Search(context.Background(), "golang", 5)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Results for: golang (via DuckDuckGo)")
+	assert.Contains(t, result, "1. Go\n   https://go.dev")
+
+	// Brave is cooling down and is not asked again.
+	_, err = p.Search(context.Background(), "golang", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), brave.calls.Load())
+	assert.Equal(t, int32(2), ddg.calls.Load())
+	assert.False(t, p.cooldown.IsAvailable("brave"))
+}
+
+func TestAggregateSearchProvider_AllFail(t *testing.T) {
+	p := newAggregateSearchProvider([]namedSearchProvider{
+		{name: "brave", provider: &fakeSearchProvider{err: errors.New("status 500")}},
+		{name: "tavily", provider: &fakeSearchProvider{err: errors.New("status 401")}},
+	}, 1, 0)
+
+	_, err := p.Search(context.Background(), "q", 5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "brave: status 500")
+	assert.Contains(t, err.Error(), "tavily: status 401")
+
+	// With every provider cooling down, the one recovering first is tried.
+	_, err = p.Search(context.Background(), "q", 5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "brave: status 500")
+	assert.NotContains(t, err.Error(), "tavily")
+}
+
+func TestAggregateSearchProvider_MergeAndCache(t *testing.T) {
+	brave := &fakeSearchProvider{results: []SearchResult{
+		{Title: "A", URL: "https://www.example.com/a/"},
+		{Title: "B", URL: "https://example.com/b"},
+	}}
+	tavily := &fakeSearchProvider{results: []SearchResult{
+		{Title: "A again", URL: "http://example.com/a?utm_source=x", Snippet: "about a"},
+		{Title: "C", URL: "https://example.com/c"},
+	}}
+	empty := &fakeSearchProvider{}
+	p := newAggregateSearchProvider([]namedSearchProvider{
+		{name: "searxng", provider: empty},
+		{name: "brave", provider: brave},
+		{name: "tavily", provider: tavily},
+	}, 2, time.Minute)
+
+	result, err := p.Search(context.Background(), "Example  Query", 10)
+	require.NoError(t, err)
+	assert.Equal(t, "Results for: Example  Query (via Brave, Tavily)\n"+
+		"1. A\n   https://www.example.com/a/\n   about a\n"+
+		"2. B\n   https://example.com/b\n"+
+		"3. C\n   https://example.com/c", result)
+
+	cached, err := p.Search(context.Background(), "example query", 10)
+	require.NoError(t, err)
+	assert.Equal(t, result, cached)
+	assert.Equal(t, int32(1), brave.calls.Load())
+	assert.Equal(t, int32(1), tavily.calls.Load())
+	assert.Equal(t, int32(1), empty.calls.Load())
+}
+
+func TestParseNumberedResults(t *testing.T) {
+	content := "1. **Go Programming Language**\n   https://go.dev/\n   Build simple, secure software.\n\n" +
+		"2. [Go by Example](https://gobyexample.com)\n   Hands-on introduction.\n" +
+		"3. A result without a link\n"
+	assert.Equal(t, []SearchResult{
+		{Title: "Go Programming Language", URL: "https://go.dev/", Snippet: "Build simple, secure software."},
+		{Title: "Go by Example", URL: "https://gobyexample.com", Snippet: "Hands-on introduction."},
+	}, parseNumberedResults(content))
+}
+
+func TestNewWebSearchTool_Aggregated(t *testing.T) {
+	tool, err := NewWebSearchTool(WebSearchToolOptions{
+		DuckDuckGoEnabled:    true,
+		DuckDuckGoMaxResults: 8,
+		BraveEnabled:         true, // no API key, skipped
+		Providers:            []string{"brave", "DuckDuckGo"},
+		MergeProviders:       2,
+	})
+	require.NoError(t, err)
+	aggregate, ok := tool.provider.(*AggregateSearchProvider)
+	require.True(t, ok, "provider type = %T", tool.provider)
+	require.Len(t, aggregate.providers, 1)
+	assert.Equal(t, "duckduckgo", aggregate.providers[0].name)
+	assert.Equal(t, 2, aggregate.merge)
+	assert.Equal(t, 8, tool.maxResults)
+
+	_, err = NewWebSearchTool(WebSearchToolOptions{Providers: []string{"bing"}})
+	assert.ErrorContains(t, err, `unknown web search provider "bing"`)
+
+	_, err = NewWebSearchTool(WebSearchToolOptions{Providers: []string{"brave"}})
+	assert.ErrorContains(t, err, "is enabled and configured")
+}
@@ -0,0 +1,277 @@
+// Package usage records LLM token usage and cost per agent, session, model
+// and day, persisted as a single JSON file in the workspace state directory.
+// Records of past months are rolled up per agent, model and month.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// saveDelay is how long the tracker batches updates before writing the
+// ledger, so a busy agent does not rewrite it on every LLM call.
+const saveDelay = 30 * time.Second
+
+// Record aggregates the usage of one agent, session and model on one day.
+// Records of past months carry no session and aggregate the whole month.
+type Record struct {
+	Day              string  `json:"day"` // YYYY-MM-DD, or YYYY-MM for past months; local time
+	AgentID          string  `json:"agent_id"`
+	SessionKey       string  `json:"session_key,omitempty"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost,omitempty"` // USD, 0 when the model has no pricing
+}
+
+// Totals is the sum of a set of records.
+type Totals struct {
+	Requests         int
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	Cost             float64
+}
+
+func (t *Totals) add(r *Record) {
+	t.Requests += r.Requests
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.TotalTokens += r.TotalTokens
+	t.Cost += r.Cost
+}
+
+// Filter selects records. Empty fields match everything; DayPrefix matches a
+// day ("2026-01-02") or a month ("2026-01").
+type Filter struct {
+	AgentID    string
+	SessionKey string
+	Model      string
+	DayPrefix  string
+}
+
+func (f Filter) match(r *Record) bool {
+	return (f.AgentID == "" || r.AgentID == f.AgentID) &&
+		(f.SessionKey == "" || r.SessionKey == f.SessionKey) &&
+		(f.Model == "" || r.Model == f.Model) &&
+		strings.HasPrefix(r.Day, f.DayPrefix)
+}
+
+type recordKey struct {
+	day, agentID, sessionKey, model string
+}
+
+// Tracker accumulates usage records in memory and persists them atomically
+// saveDelay after an update, or when flushed.
+type Tracker struct {
+	mu        sync.Mutex
+	path      string
+	records   map[recordKey]*Record
+	now       func() time.Time
+	saveTimer *time.Timer // pending save, nil when the ledger is saved
+}
+
+// DefaultPath returns the usage file location for a workspace.
+func DefaultPath(workspace string) string {
+	return filepath.Join(workspace, "state", "usage.json")
+}
+
+// NewTracker creates a tracker backed by path, loading existing records.
+// A missing or unreadable file starts an empty ledger.
+func NewTracker(path string) *Tracker {
+	t := &Tracker{
+		path:    path,
+		records: make(map[recordKey]*Record),
+		now:     time.Now,
+	}
+
+	records, err := Load(path)
+	if err != nil {
+		logger.WarnCF("usage", "Failed to load usage records", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	for i := range records {
+		r := records[i]
+		t.records[recordKey{r.Day, r.AgentID, r.SessionKey, r.Model}] = &r
+	}
+
+	return t
+}
+
+// Load reads the records stored at path. A missing file yields no records.
+func Load(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage file: %w", err)
+	}
+	return records, nil
+}
+
+// Today returns the day prefix for the current day.
+func (t *Tracker) Today() string {
+	return t.now().Format(dayLayout)
+}
+
+// ThisMonth returns the day prefix for the current month.
+func (t *Tracker) ThisMonth() string {
+	return t.now().Format(monthLayout)
+}
+
+// Record adds one LLM call to today's record for agent, session and model.
+// The ledger is saved saveDelay later, together with the calls recorded in
+// between.
+func (t *Tracker) Record(
+	agentID, sessionKey, model string,
+	promptTokens, completionTokens, totalTokens int,
+	cost float64,
+) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	day := t.now().Format(dayLayout)
+	key := recordKey{day, agentID, sessionKey, model}
+	r, ok := t.records[key]
+	if !ok {
+		r = &Record{Day: day, AgentID: agentID, SessionKey: sessionKey, Model: model}
+		t.records[key] = r
+	}
+
+	if totalTokens == 0 {
+		totalTokens = promptTokens + completionTokens
+	}
+	r.Requests++
+	r.PromptTokens += int64(promptTokens)
+	r.CompletionTokens += int64(completionTokens)
+	r.TotalTokens += int64(totalTokens)
+	r.Cost += cost
+
+	if t.saveTimer == nil {
+		t.saveTimer = time.AfterFunc(saveDelay, func() {
+			if err := t.Flush(); err != nil {
+				logger.WarnCF("usage", "Failed to save usage records", map[string]any{
+					"path":  t.path,
+					"error": err.Error(),
+				})
+			}
+		})
+	}
+}
+
+// Flush saves pending updates now. Call it before the process exits.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.saveTimer == nil {
+		return nil
+	}
+	t.saveTimer.Stop()
+	t.saveTimer = nil
+	return t.save()
+}
+
+// Sum totals the records that match f.
+func (t *Tracker) Sum(f Filter) Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var totals Totals
+	for _, r := range t.records {
+		if f.match(r) {
+			totals.add(r)
+		}
+	}
+	return totals
+}
+
+// Sum totals the records returned by Load that match f.
+func Sum(records []Record, f Filter) Totals {
+	var totals Totals
+	for i := range records {
+		if f.match(&records[i]) {
+			totals.add(&records[i])
+		}
+	}
+	return totals
+}
+
+// rollUp merges the per-day and per-session records of months before
+// month into one record per month, agent and model. Must be called with
+// the lock held.
+func (t *Tracker) rollUp(month string) {
+	for key, r := range t.records {
+		if len(r.Day) < len(monthLayout) || r.Day[:len(monthLayout)] >= month ||
+			(r.SessionKey == "" && len(r.Day) == len(monthLayout)) {
+			continue
+		}
+		delete(t.records, key)
+		m := r.Day[:len(monthLayout)]
+		mkey := recordKey{m, r.AgentID, "", r.Model}
+		total, ok := t.records[mkey]
+		if !ok {
+			total = &Record{Day: m, AgentID: r.AgentID, Model: r.Model}
+			t.records[mkey] = total
+		}
+		total.Requests += r.Requests
+		total.PromptTokens += r.PromptTokens
+		total.CompletionTokens += r.CompletionTokens
+		total.TotalTokens += r.TotalTokens
+		total.Cost += r.Cost
+	}
+}
+
+// save rolls up past months and writes all records sorted by day, agent,
+// session and model. Must be called with the lock held.
+func (t *Tracker) save() error {
+	t.rollUp(t.now().Format(monthLayout))
+
+	records := make([]Record, 0, len(t.records))
+	for _, r := range t.records {
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		if a.SessionKey != b.SessionKey {
+			return a.SessionKey < b.SessionKey
+		}
+		return a.Model < b.Model
+	})
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage records: %w", err)
+	}
+	return fileutil.WriteFileAtomic(t.path, data, 0o600)
+}
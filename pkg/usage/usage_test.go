@@ -0,0 +1,130 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracker_RecordAndSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "usage.json")
+	tr := NewTracker(path)
+	day := time.Date(2026, 3, 14, 10, 0, 0, 0, time.Local)
+	tr.now = func() time.Time { return day }
+
+	tr.Record("main", "s1", "gpt-4o", 100, 20, 120, 0.5)
+	tr.Record("main", "s1", "gpt-4o", 50, 10, 0, 0.25)
+	tr.Record("main", "s2", "gpt-4o-mini", 10, 5, 15, 0)
+	day = day.AddDate(0, 0, 1)
+	tr.Record("other", "s3", "gpt-4o", 1, 1, 2, 0)
+
+	got := tr.Sum(Filter{AgentID: "main", DayPrefix: "2026-03-14"})
+	if got.Requests != 3 || got.TotalTokens != 195 {
+		t.Errorf("main today = %+v, want 3 requests / 195 tokens", got)
+	}
+	if got.Cost != 0.75 {
+		t.Errorf("main today cost = %v, want 0.75", got.Cost)
+	}
+
+	session := tr.Sum(Filter{SessionKey: "s1"})
+	if session.PromptTokens != 150 || session.CompletionTokens != 30 {
+		t.Errorf("session s1 = %+v, want 150 prompt / 30 completion", session)
+	}
+
+	month := tr.Sum(Filter{DayPrefix: tr.ThisMonth()})
+	if month.TotalTokens != 197 {
+		t.Errorf("month total = %d, want 197", month.TotalTokens)
+	}
+}
+
+func TestTracker_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tr := NewTracker(path)
+	tr.Record("main", "s1", "gpt-4o", 100, 20, 120, 0)
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	reloaded := NewTracker(path)
+	if got := reloaded.Sum(Filter{AgentID: "main"}); got.TotalTokens != 120 {
+		t.Fatalf("reloaded total = %d, want 120", got.TotalTokens)
+	}
+	reloaded.Record("main", "s1", "gpt-4o", 1, 1, 2, 0)
+	if err := reloaded.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	records, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(records) != 1 || records[0].Requests != 2 {
+		t.Fatalf("records = %+v, want one record with 2 requests", records)
+	}
+	if got := Sum(records, Filter{}); got.TotalTokens != 122 {
+		t.Fatalf("Sum() total = %d, want 122", got.TotalTokens)
+	}
+}
+
+func TestTracker_SavesAfterDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tr := NewTracker(path)
+	tr.Record("main", "s1", "gpt-4o", 100, 20, 120, 0)
+
+	// Nothing is written until the save delay passes or the ledger is flushed.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("ledger written on Record: %v", err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if records, _ := Load(path); len(records) != 1 {
+		t.Fatalf("records after Flush = %+v, want one", records)
+	}
+}
+
+func TestTracker_RollsUpPastMonths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tr := NewTracker(path)
+	day := time.Date(2026, 3, 14, 10, 0, 0, 0, time.Local)
+	tr.now = func() time.Time { return day }
+
+	tr.Record("main", "s1", "gpt-4o", 100, 20, 120, 0.5)
+	tr.Record("main", "s2", "gpt-4o", 10, 5, 15, 0.25)
+	day = day.AddDate(0, 0, 1)
+	tr.Record("main", "s1", "gpt-4o", 1, 1, 2, 0)
+	day = time.Date(2026, 4, 1, 10, 0, 0, 0, time.Local)
+	tr.Record("main", "s1", "gpt-4o", 2, 2, 4, 0)
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	records, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want a March total and an April record", records)
+	}
+	march := records[0]
+	if march.Day != "2026-03" || march.SessionKey != "" || march.Requests != 3 || march.TotalTokens != 137 {
+		t.Errorf("March total = %+v, want 3 requests / 137 tokens without session", march)
+	}
+	if got := tr.Sum(Filter{AgentID: "main", DayPrefix: "2026-03"}); got.Cost != 0.75 {
+		t.Errorf("March cost = %v, want 0.75", got.Cost)
+	}
+	if records[1].Day != "2026-04-01" || records[1].SessionKey != "s1" {
+		t.Errorf("April record = %+v, want a per-session record", records[1])
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	records, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("Load() = %v, want no records", records)
+	}
+}